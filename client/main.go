@@ -3,13 +3,11 @@ package main
 import (
 	"context"
 	"fmt"
-	"io"
 	"log"
 	"time"
 
 	"github.com/0gener/go-weight-tracker/weighttracker"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
+	"github.com/0gener/go-weight-tracker/wtclient"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -21,27 +19,18 @@ var (
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
-	opts := grpc.WithInsecure()
+	transport := wtclient.WithInsecure()
 
 	if tls {
-		creds, sslErr := credentials.NewClientTLSFromFile(certFile, "")
-
-		if sslErr != nil {
-			log.Fatalf("error while loading CA trust certificate: %v", sslErr)
-		}
-
-		opts = grpc.WithTransportCredentials(creds)
+		transport = wtclient.WithTLSFromFile(certFile, "")
 	}
 
-	conn, err := grpc.Dial("localhost:50051", opts)
-
+	c, err := wtclient.New(wtclient.WithAddress("localhost:50051"), transport)
 	if err != nil {
 		log.Fatalf("could not connect: %v", err)
 	}
 
-	defer conn.Close()
-
-	c := weighttracker.NewWeightTrackerClient(conn)
+	defer c.Close()
 
 	// createRecord(c)
 	// readRecord(c, 2)
@@ -50,7 +39,7 @@ func main() {
 	listRecords(c)
 }
 
-func createRecord(c weighttracker.WeightTrackerClient) {
+func createRecord(c *wtclient.Client) {
 	fmt.Println("calling CreateRecord")
 
 	record := &weighttracker.Record{
@@ -58,7 +47,7 @@ func createRecord(c weighttracker.WeightTrackerClient) {
 		WeightedAt: timestamppb.New(time.Date(2019, 10, 10, 9, 12, 56, 100, time.Local)),
 	}
 
-	res, err := c.CreateRecord(context.Background(), &weighttracker.CreateRecordRequest{Record: record})
+	res, err := c.CreateRecord(context.Background(), record)
 	if err != nil {
 		log.Printf("failed to AddRecord: %v\n", err)
 	}
@@ -66,10 +55,10 @@ func createRecord(c weighttracker.WeightTrackerClient) {
 	fmt.Printf("CreateRecord result: %v\n", res)
 }
 
-func readRecord(c weighttracker.WeightTrackerClient, recordID uint64) {
+func readRecord(c *wtclient.Client, recordID uint64) {
 	fmt.Println("calling ReadRecord")
 
-	res, err := c.ReadRecord(context.Background(), &weighttracker.ReadRecordRequest{RecordId: recordID})
+	res, err := c.ReadRecord(context.Background(), recordID)
 	if err != nil {
 		log.Printf("failed to ReadRecord: %v\n", err)
 	}
@@ -77,14 +66,12 @@ func readRecord(c weighttracker.WeightTrackerClient, recordID uint64) {
 	fmt.Printf("ReadRecord result: %v\n", res)
 }
 
-func updateRecord(c weighttracker.WeightTrackerClient, recordID uint64) {
+func updateRecord(c *wtclient.Client, recordID uint64) {
 	fmt.Println("calling UpdateRecord")
 
-	res, err := c.UpdateRecord(context.Background(), &weighttracker.UpdateRecordRequest{
-		Record: &weighttracker.Record{
-			Id:     recordID,
-			Weight: 63,
-		},
+	res, err := c.UpdateRecord(context.Background(), &weighttracker.Record{
+		Id:     recordID,
+		Weight: 63,
 	})
 	if err != nil {
 		log.Printf("failed to UpdateRecord: %v\n", err)
@@ -93,39 +80,29 @@ func updateRecord(c weighttracker.WeightTrackerClient, recordID uint64) {
 	fmt.Printf("UpdateRecord result: %v\n", res)
 }
 
-func deleteRecord(c weighttracker.WeightTrackerClient, recordID uint64) {
+func deleteRecord(c *wtclient.Client, recordID uint64) {
 	fmt.Println("calling DeleteRecord")
 
-	res, err := c.DeleteRecord(context.Background(), &weighttracker.DeleteRecordRequest{RecordId: recordID})
-	if err != nil {
+	if err := c.DeleteRecord(context.Background(), recordID); err != nil {
 		log.Printf("failed to DeleteRecord: %v\n", err)
 	}
-
-	fmt.Printf("DeleteRecord result: %v\n", res)
 }
 
-func listRecords(c weighttracker.WeightTrackerClient) {
+func listRecords(c *wtclient.Client) {
 	fmt.Println("calling ListRecord")
 
 	// from, _ := time.Parse("2006-01-02", "2020-10-10")
 
-	stream, err := c.ListRecords(context.Background(), &weighttracker.ListRecordsRequest{
+	records := c.Records(context.Background(), &weighttracker.ListRecordsRequest{
 		// WeightedAtFrom: timestamppb.New(from),
 		WeightedAtTo: timestamppb.Now(),
 	})
-	if err != nil {
-		log.Printf("failed to ListRecords: %v\n", err)
-	}
 
-	for {
-		res, err := stream.Recv()
-		if err == io.EOF {
-			break
-		}
+	for record, err := range records {
 		if err != nil {
 			log.Fatalf("something happened: %v", err)
 		}
 
-		fmt.Println(res.GetRecord())
+		fmt.Println(record)
 	}
 }
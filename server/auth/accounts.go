@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/config"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// tokenIssuer is the issuer of the access tokens signed by Accounts, so
+// they are not mistaken for tokens issued by anyone else.
+const tokenIssuer = "weight-tracker"
+
+var (
+	// ErrInvalidCredentials is returned when a username and password do not
+	// match an account.
+	ErrInvalidCredentials = errors.New("invalid username or password")
+
+	// ErrInvalidToken is returned for tokens that are malformed, unknown,
+	// expired or revoked.
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrRegistrationDisabled is returned by Register unless registration
+	// is allowed.
+	ErrRegistrationDisabled = errors.New("registration is disabled")
+)
+
+// Tokens are issued when logging in or refreshing.
+type Tokens struct {
+	AccessToken           string
+	AccessTokenExpiresAt  time.Time
+	RefreshToken          string
+	RefreshTokenExpiresAt time.Time
+}
+
+// Accounts registers and logs in accounts. It issues access tokens, signed
+// with HMAC-SHA256, whose principal is the account id, and refresh tokens,
+// which are kept in the store and rotated on every use.
+type Accounts struct {
+	conf  config.AuthConfig
+	store store.AccountStore
+	now   func() time.Time
+
+	// dummyHash is compared against when the username is unknown, so
+	// failing logins take as long whether the account exists or not
+	dummyHash []byte
+}
+
+var _ Authenticator = (*Accounts)(nil)
+
+// NewAccounts creates accounts kept in st, issuing tokens as configured by
+// authConfig.
+func NewAccounts(authConfig config.AuthConfig, st store.AccountStore, now func() time.Time) *Accounts {
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+
+	return &Accounts{
+		conf:      authConfig,
+		store:     st,
+		now:       now,
+		dummyHash: dummyHash,
+	}
+}
+
+// Register creates an account. The username is expected to be validated
+// already. It returns store.ErrAlreadyExists if the username is taken.
+func (a *Accounts) Register(ctx context.Context, username, password string) (*store.Account, error) {
+	if !a.conf.AllowRegistration {
+		return nil, ErrRegistrationDisabled
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	acc := &store.Account{Username: username, PasswordHash: string(hash)}
+	if err = a.store.CreateAccount(ctx, acc); err != nil {
+		return nil, err
+	}
+
+	return acc, nil
+}
+
+// Login issues tokens for the account with the given username and password,
+// or returns ErrInvalidCredentials.
+func (a *Accounts) Login(ctx context.Context, username, password string) (*Tokens, error) {
+	acc, err := a.store.ReadAccountByUsername(ctx, username)
+	if errors.Is(err, store.ErrNotFound) {
+		bcrypt.CompareHashAndPassword(a.dummyHash, []byte(password))
+
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(acc.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	now := a.now()
+
+	// refresh tokens are otherwise kept forever, a failure only means they
+	// are kept until the next login
+	if _, err = a.store.DeleteRefreshTokens(ctx, now); err != nil {
+		slog.Warn("failed to delete expired refresh tokens", "error", err)
+	}
+
+	return a.issue(ctx, acc.ID, now)
+}
+
+// Refresh revokes refreshToken and issues new tokens for its account. A
+// revoked token is only presented again if it leaked, or was used twice
+// concurrently, so every session of its account is ended too.
+func (a *Accounts) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	hash := hashToken(refreshToken)
+
+	t, err := a.store.ReadRefreshToken(ctx, hash)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := a.now()
+
+	if t.RevokedAt != nil {
+		if err = a.store.RevokeRefreshTokens(ctx, t.AccountID, now); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrInvalidToken
+	}
+
+	if !now.Before(t.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	err = a.store.RevokeRefreshToken(ctx, hash, now)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return a.issue(ctx, t.AccountID, now)
+}
+
+// Logout revokes refreshToken, or every refresh token of its account if
+// all is set. Unknown and revoked tokens are ignored.
+func (a *Accounts) Logout(ctx context.Context, refreshToken string, all bool) error {
+	hash := hashToken(refreshToken)
+
+	t, err := a.store.ReadRefreshToken(ctx, hash)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if all {
+		return a.store.RevokeRefreshTokens(ctx, t.AccountID, a.now())
+	}
+
+	if err = a.store.RevokeRefreshToken(ctx, hash, a.now()); !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// Authenticate implements Authenticator for the access tokens issued by a.
+func (a *Accounts) Authenticate(ctx context.Context, token string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+
+	_, err := jwt.ParseWithClaims(token, claims,
+		func(*jwt.Token) (interface{}, error) { return []byte(a.conf.SigningKey), nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(a.now),
+	)
+	if err != nil {
+		return "", errors.Join(ErrInvalidToken, err)
+	}
+
+	if claims.Subject == "" {
+		return "", ErrInvalidToken
+	}
+
+	return claims.Subject, nil
+}
+
+// issue creates an access token and a refresh token for the account.
+func (a *Accounts) issue(ctx context.Context, accountID uint64, now time.Time) (*Tokens, error) {
+	tokens := &Tokens{
+		AccessTokenExpiresAt:  now.Add(a.conf.AccessTokenTTL),
+		RefreshTokenExpiresAt: now.Add(a.conf.RefreshTokenTTL),
+	}
+
+	access := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    tokenIssuer,
+		Subject:   strconv.FormatUint(accountID, 10),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(tokens.AccessTokenExpiresAt),
+	})
+
+	var err error
+	if tokens.AccessToken, err = access.SignedString([]byte(a.conf.SigningKey)); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	tokens.RefreshToken = base64.RawURLEncoding.EncodeToString(b)
+
+	err = a.store.CreateRefreshToken(ctx, &store.RefreshToken{
+		AccountID: accountID,
+		Hash:      hashToken(tokens.RefreshToken),
+		ExpiresAt: tokens.RefreshTokenExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// hashToken returns the hash refresh tokens are stored by. Unlike passwords
+// they are random and long enough that a fast hash does not make guessing
+// them any easier.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,130 @@
+// Package auth authenticates callers by the bearer token they send and
+// manages the accounts that log in with a password.
+package auth
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Authenticator checks a bearer token and returns the principal it was
+// issued to.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (string, error)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying principal.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal of the caller, or an empty
+// string if the call is not authenticated.
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+
+	return principal
+}
+
+// UnaryServerInterceptor rejects unary calls without a valid bearer token
+// with UNAUTHENTICATED, except for the public methods, given by name, e.g.
+// Login. Must run after the logging interceptor.
+func UnaryServerInterceptor(a Authenticator, public ...string) grpc.UnaryServerInterceptor {
+	skip := methodSet(public)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if skip[path.Base(info.FullMethod)] {
+			return handler(ctx, req)
+		}
+
+		ctx, err := authenticate(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streaming calls without a valid bearer
+// token with UNAUTHENTICATED, except for the public methods. Must run after
+// the logging interceptor.
+func StreamServerInterceptor(a Authenticator, public ...string) grpc.StreamServerInterceptor {
+	skip := methodSet(public)
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if skip[path.Base(info.FullMethod)] {
+			return handler(srv, ss)
+		}
+
+		ctx, err := authenticate(ss.Context(), a)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func methodSet(methods []string) map[string]bool {
+	set := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		set[m] = true
+	}
+
+	return set
+}
+
+// authenticate checks the bearer token sent with the call and returns ctx
+// with its principal.
+func authenticate(ctx context.Context, a Authenticator) (context.Context, error) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	principal, err := a.Authenticate(ctx, token)
+	if err != nil {
+		logging.FromContext(ctx).Debug("rejected bearer token", "error", err)
+
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired access token")
+	}
+
+	return WithPrincipal(ctx, principal), nil
+}
+
+// bearerToken returns the token in the authorization header of the call.
+func bearerToken(ctx context.Context) (string, bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	values := md.Get("authorization")
+	if len(values) != 1 {
+		return "", false
+	}
+
+	scheme, token, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") || token == "" {
+		return "", false
+	}
+
+	return token, true
+}
+
+// authenticatedStream carries the principal in the context of the stream.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
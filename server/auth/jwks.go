@@ -0,0 +1,355 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/config"
+)
+
+const (
+	// minJWKSRefreshInterval limits how often tokens signed by unknown keys
+	// trigger a refresh, so they cannot be used to flood the source.
+	minJWKSRefreshInterval = time.Minute
+
+	jwksFetchTimeout = 10 * time.Second
+	maxJWKSSize      = 1 << 20
+)
+
+// errUnknownKey is returned for tokens signed by a key that is not in the
+// JWKS, even after refreshing it.
+var errUnknownKey = errors.New("unknown signing key")
+
+// JWKS holds the public keys of a JSON Web Key Set, loaded from a file or a
+// URL. The keys are replaced as a whole whenever they are refreshed, so
+// keys can be rotated by publishing the new key before signing with it and
+// removing the old one once its tokens have expired.
+type JWKS struct {
+	file     string
+	url      string
+	interval time.Duration
+	client   *http.Client
+	now      func() time.Time
+
+	mu   sync.RWMutex
+	keys []jwk
+
+	// refreshMu serializes refreshes, lastRefresh is the time the last one
+	// was attempted
+	refreshMu   sync.Mutex
+	lastRefresh time.Time
+}
+
+// jwk is a parsed key of the set. Kid and Alg are empty if the key does not
+// set them.
+type jwk struct {
+	Kid string
+	Alg string
+	Key crypto.PublicKey
+}
+
+// LoadJWKS loads the key set from the file or URL in jwtConfig.
+func LoadJWKS(ctx context.Context, jwtConfig config.JWTConfig, now func() time.Time) (*JWKS, error) {
+	s := &JWKS{
+		file:     jwtConfig.JWKSFile,
+		url:      jwtConfig.JWKSURL,
+		interval: jwtConfig.JWKSRefreshInterval,
+		client:   &http.Client{Timeout: jwksFetchTimeout},
+		now:      now,
+	}
+
+	if err := s.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// File returns the path of the file the keys are loaded from, or an empty
+// string if they are fetched from a URL.
+func (s *JWKS) File() string {
+	return s.file
+}
+
+// Run refreshes the keys every refresh interval until ctx is canceled. The
+// current keys are kept if refreshing fails.
+func (s *JWKS) Run(ctx context.Context) {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		if err := s.Refresh(ctx); err != nil {
+			slog.Warn("failed to refresh JWKS, keeping the current keys", "error", err)
+		}
+	}
+}
+
+// Refresh reloads the keys. The current keys are kept if it fails.
+func (s *JWKS) Refresh(ctx context.Context) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	return s.refresh(ctx)
+}
+
+// refresh must be called with refreshMu held.
+func (s *JWKS) refresh(ctx context.Context) error {
+	s.lastRefresh = s.now()
+
+	data, err := s.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = keys
+
+	return nil
+}
+
+func (s *JWKS) read(ctx context.Context) ([]byte, error) {
+	if s.file != "" {
+		data, err := os.ReadFile(s.file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS: %w", err)
+		}
+
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: %v returned %v", s.url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	return data, nil
+}
+
+// key returns the key a token with the given kid and alg headers is signed
+// with. The keys are refreshed once if there is none, unless they were
+// refreshed recently.
+func (s *JWKS) key(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	if key, ok := s.find(kid, alg); ok {
+		return key, nil
+	}
+
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	// another call may have refreshed while this one waited
+	if key, ok := s.find(kid, alg); ok {
+		return key, nil
+	}
+
+	if s.now().Sub(s.lastRefresh) < minJWKSRefreshInterval {
+		return nil, errUnknownKey
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		slog.Warn("failed to refresh JWKS, keeping the current keys", "error", err)
+
+		return nil, errUnknownKey
+	}
+
+	if key, ok := s.find(kid, alg); ok {
+		return key, nil
+	}
+
+	return nil, errUnknownKey
+}
+
+// find returns the key with the given kid that can verify alg. Tokens
+// without a kid can only be verified if the set has a single key.
+func (s *JWKS) find(kid, alg string) (crypto.PublicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if kid == "" {
+		if len(s.keys) == 1 && (s.keys[0].Alg == "" || s.keys[0].Alg == alg) {
+			return s.keys[0].Key, true
+		}
+
+		return nil, false
+	}
+
+	for _, k := range s.keys {
+		if k.Kid == kid && (k.Alg == "" || k.Alg == alg) {
+			return k.Key, true
+		}
+	}
+
+	return nil, false
+}
+
+// parseJWKS parses the signature keys of a JSON Web Key Set (RFC 7517).
+// Keys of other types or uses, such as encryption keys, are skipped.
+func parseJWKS(data []byte) ([]jwk, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	var keys []jwk
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		var (
+			key crypto.PublicKey
+			err error
+		)
+
+		switch k.Kty {
+		case "RSA":
+			key, err = parseRSAKey(k.N, k.E)
+		case "EC":
+			key, err = parseECKey(k.Crv, k.X, k.Y)
+		case "OKP":
+			key, err = parseOKPKey(k.Crv, k.X)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS: key %d (kid %q): %w", i, k.Kid, err)
+		}
+
+		keys = append(keys, jwk{Kid: k.Kid, Alg: k.Alg, Key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("invalid JWKS: no signature keys")
+	}
+
+	return keys, nil
+}
+
+func parseRSAKey(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, fmt.Errorf("invalid n: %w", err)
+	}
+
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, fmt.Errorf("invalid e: %w", err)
+	}
+
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(new(big.Int).SetBytes(eb).Int64())}
+
+	if key.N.BitLen() < 2048 {
+		return nil, errors.New("RSA keys must be at least 2048 bits long")
+	}
+
+	if key.E < 3 || key.E%2 == 0 || len(eb) > 4 {
+		return nil, errors.New("invalid e")
+	}
+
+	return key, nil
+}
+
+func parseECKey(crv, x, y string) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+
+	xb, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, fmt.Errorf("invalid x: %w", err)
+	}
+
+	yb, err := base64.RawURLEncoding.DecodeString(y)
+	if err != nil {
+		return nil, fmt.Errorf("invalid y: %w", err)
+	}
+
+	size := (curve.Params().BitSize + 7) / 8
+	if len(xb) != size || len(yb) != size {
+		return nil, errors.New("invalid coordinates")
+	}
+
+	// checks that the point is on the curve
+	return ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, xb...), yb...))
+}
+
+func parseOKPKey(crv, x string) (ed25519.PublicKey, error) {
+	if crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+
+	xb, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, fmt.Errorf("invalid x: %w", err)
+	}
+
+	if len(xb) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid x")
+	}
+
+	return ed25519.PublicKey(xb), nil
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwtMethods are the signature algorithms accepted for JWTs. Symmetric
+// algorithms are left out, since the keys of a JWKS are public.
+var jwtMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// JWT authenticates callers by JWTs issued by an identity provider, such as
+// an OpenID Connect provider, and signed with one of the keys of a JWKS.
+// The principal is the value of the configured claim.
+type JWT struct {
+	conf config.JWTConfig
+	keys *JWKS
+	now  func() time.Time
+}
+
+var _ Authenticator = (*JWT)(nil)
+
+// NewJWT creates an authenticator for the JWTs described by jwtConfig and
+// signed with keys.
+func NewJWT(jwtConfig config.JWTConfig, keys *JWKS, now func() time.Time) *JWT {
+	return &JWT{conf: jwtConfig, keys: keys, now: now}
+}
+
+// Authenticate implements Authenticator.
+func (a *JWT) Authenticate(ctx context.Context, token string) (string, error) {
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(token, claims,
+		func(t *jwt.Token) (interface{}, error) {
+			kid, _ := t.Header["kid"].(string)
+
+			return a.keys.key(ctx, kid, t.Method.Alg())
+		},
+		jwt.WithValidMethods(jwtMethods),
+		jwt.WithIssuer(a.conf.Issuer),
+		jwt.WithAudience(a.conf.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(a.conf.Leeway),
+		jwt.WithTimeFunc(a.now),
+		jwt.WithJSONNumber(),
+	)
+	if err != nil {
+		return "", errors.Join(ErrInvalidToken, err)
+	}
+
+	var principal string
+	switch v := claims[a.conf.PrincipalClaim].(type) {
+	case string:
+		principal = v
+	case json.Number:
+		principal = v.String()
+	}
+
+	if principal == "" {
+		return "", fmt.Errorf("%w: missing %v claim", ErrInvalidToken, a.conf.PrincipalClaim)
+	}
+
+	return principal, nil
+}
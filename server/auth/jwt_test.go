@@ -0,0 +1,348 @@
+package auth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/auth"
+	"github.com/0gener/go-weight-tracker/server/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var now = time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+// signer is a private key published in a JWKS under kid.
+type signer struct {
+	kid    string
+	method jwt.SigningMethod
+	key    crypto.Signer
+}
+
+func newSigners(t *testing.T) (rs, es, ed signer) {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer{"rsa", jwt.SigningMethodRS256, rsaKey},
+		signer{"ec", jwt.SigningMethodES256, ecKey},
+		signer{"ed", jwt.SigningMethodEdDSA, edKey}
+}
+
+func (s signer) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(s.method, claims)
+	if s.kid != "" {
+		token.Header["kid"] = s.kid
+	}
+
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+// jwks returns the key set publishing the public keys of signers.
+func jwks(t *testing.T, signers ...signer) []byte {
+	t.Helper()
+
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	var keys []map[string]string
+	for _, s := range signers {
+		k := map[string]string{"kid": s.kid, "use": "sig", "alg": s.method.Alg()}
+
+		switch pub := s.key.Public().(type) {
+		case *rsa.PublicKey:
+			k["kty"], k["n"], k["e"] = "RSA", b64(pub.N.Bytes()), b64(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			raw, err := pub.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			k["kty"], k["crv"], k["x"], k["y"] = "EC", "P-256", b64(raw[1:33]), b64(raw[33:])
+		case ed25519.PublicKey:
+			k["kty"], k["crv"], k["x"] = "OKP", "Ed25519", b64(pub)
+		}
+
+		keys = append(keys, k)
+	}
+
+	// encryption keys are ignored
+	keys = append(keys, map[string]string{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"})
+
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func jwtConfig() config.JWTConfig {
+	conf := config.Default().Auth.JWT
+	conf.Issuer = "https://sso.example.com"
+	conf.Audience = "weight-tracker"
+
+	return conf
+}
+
+func claims(overrides jwt.MapClaims) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"iss": "https://sso.example.com",
+		"aud": "weight-tracker",
+		"sub": "user-1",
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+
+	for k, v := range overrides {
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+	}
+
+	return c
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJWT(t *testing.T) {
+	rs, es, ed := newSigners(t)
+
+	conf := jwtConfig()
+	conf.JWKSFile = filepath.Join(t.TempDir(), "jwks.json")
+	writeFile(t, conf.JWKSFile, jwks(t, rs, es, ed))
+
+	keys, err := auth.LoadJWKS(context.Background(), conf, func() time.Time { return now })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := auth.NewJWT(conf, keys, func() time.Time { return now })
+
+	other, _, _ := newSigners(t)
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		principal string // empty if the token must be rejected
+	}{
+		{"rsa", rs.sign(t, claims(nil)), "user-1"},
+		{"ec", es.sign(t, claims(nil)), "user-1"},
+		{"ed25519", ed.sign(t, claims(nil)), "user-1"},
+		{"audience list", rs.sign(t, claims(jwt.MapClaims{"aud": []string{"other", "weight-tracker"}})), "user-1"},
+		{"expired within leeway", rs.sign(t, claims(jwt.MapClaims{"exp": now.Add(-30 * time.Second).Unix()})), "user-1"},
+		{"expired", rs.sign(t, claims(jwt.MapClaims{"exp": now.Add(-2 * time.Minute).Unix()})), ""},
+		{"not yet valid", rs.sign(t, claims(jwt.MapClaims{"nbf": now.Add(2 * time.Minute).Unix()})), ""},
+		{"without exp", rs.sign(t, claims(jwt.MapClaims{"exp": nil})), ""},
+		{"wrong issuer", rs.sign(t, claims(jwt.MapClaims{"iss": "https://evil.example.com"})), ""},
+		{"wrong audience", rs.sign(t, claims(jwt.MapClaims{"aud": "other"})), ""},
+		{"without subject", rs.sign(t, claims(jwt.MapClaims{"sub": nil})), ""},
+		{"unknown kid", signer{"other", other.method, other.key}.sign(t, claims(nil)), ""},
+		{"wrong key for kid", signer{"rsa", other.method, other.key}.sign(t, claims(nil)), ""},
+		{"without kid", signer{"", rs.method, rs.key}.sign(t, claims(nil)), ""},
+		{"wrong algorithm for kid", signer{"rsa", jwt.SigningMethodPS256, rs.key}.sign(t, claims(nil)), ""},
+		{"unsigned", unsigned, ""},
+		{"malformed", "not.a.token", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := a.Authenticate(context.Background(), tt.token)
+
+			switch {
+			case tt.principal == "" && err == nil:
+				t.Fatalf("got principal %q, want an error", principal)
+			case tt.principal != "" && err != nil:
+				t.Fatalf("got error %v, want principal %q", err, tt.principal)
+			case principal != tt.principal:
+				t.Fatalf("got principal %q, want %q", principal, tt.principal)
+			}
+		})
+	}
+}
+
+func TestJWTPrincipalClaim(t *testing.T) {
+	rs, _, _ := newSigners(t)
+
+	conf := jwtConfig()
+	conf.JWKSFile = filepath.Join(t.TempDir(), "jwks.json")
+	conf.PrincipalClaim = "employee_id"
+	writeFile(t, conf.JWKSFile, jwks(t, rs))
+
+	keys, err := auth.LoadJWKS(context.Background(), conf, func() time.Time { return now })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := auth.NewJWT(conf, keys, func() time.Time { return now })
+
+	principal, err := a.Authenticate(context.Background(), rs.sign(t, claims(jwt.MapClaims{"employee_id": 12345678901})))
+	if err != nil || principal != "12345678901" {
+		t.Fatalf("got principal %q and error %v, want 12345678901", principal, err)
+	}
+
+	if _, err = a.Authenticate(context.Background(), rs.sign(t, claims(nil))); err == nil {
+		t.Fatal("token without the principal claim was accepted")
+	}
+}
+
+func TestJWKSRotation(t *testing.T) {
+	old, _, _ := newSigners(t)
+	next, _, _ := newSigners(t)
+	next.kid = "next"
+
+	conf := jwtConfig()
+	conf.JWKSFile = filepath.Join(t.TempDir(), "jwks.json")
+	writeFile(t, conf.JWKSFile, jwks(t, old))
+
+	keys, err := auth.LoadJWKS(context.Background(), conf, func() time.Time { return now })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := auth.NewJWT(conf, keys, func() time.Time { return now })
+
+	writeFile(t, conf.JWKSFile, jwks(t, next))
+
+	// the file is only read again when refreshed
+	if _, err = a.Authenticate(context.Background(), old.sign(t, claims(nil))); err != nil {
+		t.Fatalf("old key rejected before refreshing: %v", err)
+	}
+
+	if err = keys.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = a.Authenticate(context.Background(), next.sign(t, claims(nil))); err != nil {
+		t.Fatalf("new key rejected: %v", err)
+	}
+
+	if _, err = a.Authenticate(context.Background(), old.sign(t, claims(nil))); err == nil {
+		t.Fatal("removed key accepted")
+	}
+
+	// a broken file keeps the current keys
+	writeFile(t, conf.JWKSFile, []byte("{"))
+
+	if err = keys.Refresh(context.Background()); err == nil {
+		t.Fatal("refreshing from an invalid file succeeded")
+	}
+
+	if _, err = a.Authenticate(context.Background(), next.sign(t, claims(nil))); err != nil {
+		t.Fatalf("key rejected after a failed refresh: %v", err)
+	}
+}
+
+func TestJWKSURL(t *testing.T) {
+	old, _, _ := newSigners(t)
+	next, _, _ := newSigners(t)
+	next.kid = "next"
+
+	var (
+		mu      sync.Mutex
+		served  = jwks(t, old)
+		fetches atomic.Int32
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Write(served)
+	}))
+	defer srv.Close()
+
+	var (
+		clockMu sync.Mutex
+		clock   = now
+	)
+	nowFunc := func() time.Time {
+		clockMu.Lock()
+		defer clockMu.Unlock()
+
+		return clock
+	}
+
+	conf := jwtConfig()
+	conf.JWKSURL = srv.URL
+
+	keys, err := auth.LoadJWKS(context.Background(), conf, nowFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := auth.NewJWT(conf, keys, nowFunc)
+
+	if _, err = a.Authenticate(context.Background(), old.sign(t, claims(nil))); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	served = jwks(t, old, next)
+	mu.Unlock()
+
+	// unknown keys only trigger a fetch once the last one is a minute old
+	if _, err = a.Authenticate(context.Background(), next.sign(t, claims(nil))); err == nil {
+		t.Fatal("new key accepted right after loading the keys")
+	}
+
+	clockMu.Lock()
+	clock = clock.Add(time.Minute)
+	clockMu.Unlock()
+
+	if _, err = a.Authenticate(context.Background(), next.sign(t, claims(nil))); err != nil {
+		t.Fatalf("new key rejected after fetching the keys again: %v", err)
+	}
+
+	if got := fetches.Load(); got != 2 {
+		t.Fatalf("fetched the keys %d times, want 2", got)
+	}
+}
@@ -1,67 +1,275 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
 // Config holds configuration variables
 type Config struct {
-	Server ServerConfig
-	MySQL  struct {
-		Host     string
-		Port     string
-		Schema   string
-		User     string
-		Password string
-	}
+	Server     ServerConfig     `yaml:"server"`
+	Logging    LoggingConfig    `yaml:"logging"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Validation ValidationConfig `yaml:"validation"`
+	RateLimit  RateLimitConfig  `yaml:"rate_limit"`
+	Webhooks   WebhooksConfig   `yaml:"webhooks"`
+	Auth       AuthConfig       `yaml:"auth"`
+
+	File string `yaml:"-"` // path of the config file, if any
 }
 
 // ServerConfig holds server configuration variables
 type ServerConfig struct {
-	Host string
-	Port string
-	TLS  struct {
-		Enabled  bool
-		CertFile string // required if tls enabled
-		KeyFile  string // required if tls enabled
+	Host            string        `yaml:"host"`
+	Port            int           `yaml:"port"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	WatchInterval   time.Duration `yaml:"watch_interval"` // 0 only reloads on SIGHUP
+	TLS             TLSConfig     `yaml:"tls"`
+}
+
+// TLSConfig holds TLS configuration variables
+type TLSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"cert_file"` // required if tls enabled
+	KeyFile  string `yaml:"key_file"`  // required if tls enabled
+}
+
+// LoggingConfig holds logging configuration variables
+type LoggingConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn or error
+	Format string `yaml:"format"` // json or logfmt
+}
+
+// TracingConfig holds tracing configuration variables
+type TracingConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Exporter string `yaml:"exporter"` // stdout or file
+	File     string `yaml:"file"`     // required if exporter is file
+}
+
+// DatabaseConfig holds database configuration variables
+type DatabaseConfig struct {
+	Driver   string `yaml:"driver"` // mysql, postgres, sqlite or file
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"` // 0 uses the driver's default port
+	Name     string `yaml:"name"`
+	User     string `yaml:"user"`
+	Password Secret `yaml:"password"`
+	SSLMode  string `yaml:"ssl_mode"` // postgres only
+	Path     string `yaml:"path"`     // sqlite and file only
+
+	MaxOpenConns    int           `yaml:"max_open_conns"` // 0 means unlimited
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`  // 0 means forever
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"` // 0 means forever
+	QueryTimeout    time.Duration `yaml:"query_timeout"`      // 0 means no timeout
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"`    // how long to retry connecting on startup
+
+	// AutoMigrate applies pending migrations on startup. When disabled the
+	// server refuses to start until they are applied with "migrate up".
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+// ValidationConfig holds the bounds records are validated against
+type ValidationConfig struct {
+	MinWeight     float64       `yaml:"min_weight"`
+	MaxWeight     float64       `yaml:"max_weight"`
+	MaxFutureSkew time.Duration `yaml:"max_future_skew"` // how far in the future weighted_at may be
+	MinWeightedAt time.Time     `yaml:"min_weighted_at"`
+}
+
+// RateLimitConfig holds the limits applied to every client, identified by
+// its principal if it is authenticated and by its IP address otherwise
+type RateLimitConfig struct {
+	Enabled    bool                 `yaml:"enabled"`
+	Rate       float64              `yaml:"rate"`        // calls per second
+	Burst      int                  `yaml:"burst"`       // calls allowed at once before rate applies
+	Methods    map[string]RateLimit `yaml:"methods"`     // per method overrides, e.g. CreateRecord
+	MaxStreams int                  `yaml:"max_streams"` // concurrent streaming calls, 0 means unlimited
+}
+
+// RateLimit holds a token bucket rate and burst
+type RateLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// WebhooksConfig holds the webhook subscriptions and how they are delivered
+type WebhooksConfig struct {
+	Subscriptions []WebhookConfig `yaml:"subscriptions"`
+	Timeout       time.Duration   `yaml:"timeout"`      // for a single delivery attempt
+	MaxAttempts   int             `yaml:"max_attempts"` // before a delivery is marked as failed
+	Retention     time.Duration   `yaml:"retention"`    // how long finished deliveries are kept, 0 means forever
+}
+
+// WebhookConfig holds a webhook subscription
+type WebhookConfig struct {
+	Name   string   `yaml:"name"`
+	URL    string   `yaml:"url"`
+	Secret Secret   `yaml:"secret"` // signs payloads with HMAC-SHA256
+	Events []string `yaml:"events"` // created, updated or deleted, empty means all
+}
+
+// AuthConfig holds how callers are authenticated
+type AuthConfig struct {
+	Mode              string        `yaml:"mode"`               // none, accounts or jwt
+	SigningKey        Secret        `yaml:"signing_key"`        // signs access tokens, at least 32 bytes, required for accounts
+	AccessTokenTTL    time.Duration `yaml:"access_token_ttl"`   // how long an access token is accepted
+	RefreshTokenTTL   time.Duration `yaml:"refresh_token_ttl"`  // how long a refresh token can be used
+	AllowRegistration bool          `yaml:"allow_registration"` // whether anyone can create an account, off by default
+	JWT               JWTConfig     `yaml:"jwt"`
+}
+
+// JWTConfig holds how JWTs issued by an identity provider are validated
+type JWTConfig struct {
+	Issuer              string        `yaml:"issuer"`                // required iss claim
+	Audience            string        `yaml:"audience"`              // required aud claim
+	JWKSFile            string        `yaml:"jwks_file"`             // one of jwks_file and jwks_url is required
+	JWKSURL             string        `yaml:"jwks_url"`              // one of jwks_file and jwks_url is required
+	JWKSRefreshInterval time.Duration `yaml:"jwks_refresh_interval"` // how often the keys are reloaded
+	PrincipalClaim      string        `yaml:"principal_claim"`       // claim identifying the caller, e.g. sub or email
+	Leeway              time.Duration `yaml:"leeway"`                // clock skew allowed when checking exp, nbf and iat
+}
+
+// Limit returns the limit for method, the name of an RPC without its
+// service, e.g. CreateRecord
+func (r RateLimitConfig) Limit(method string) RateLimit {
+	if limit, ok := r.Methods[method]; ok {
+		return limit
 	}
+
+	return RateLimit{Rate: r.Rate, Burst: r.Burst}
 }
 
-// MySQLConfig holds MySQL configuration variables
-type MySQLConfig struct {
-	Host     string
-	Port     string
-	Schema   string
-	User     string
-	Password string
+// Secret is a string that is redacted when printed or logged
+type Secret string
+
+// String implements fmt.Stringer
+func (Secret) String() string {
+	return "[REDACTED]"
 }
 
-// LoadConfig loads configuration variables from environment
-func LoadConfig() *Config {
+// GoString implements fmt.GoStringer
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// MarshalJSON implements json.Marshaler
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+// Default returns the configuration used for settings that are not set
+// anywhere else
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            50051,
+			ShutdownTimeout: 10 * time.Second,
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter: "stdout",
+		},
+		Database: DatabaseConfig{
+			Driver:          "mysql",
+			MaxOpenConns:    20,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			QueryTimeout:    5 * time.Second,
+			ConnectTimeout:  time.Minute,
+			AutoMigrate:     true,
+		},
+		Validation: ValidationConfig{
+			MinWeight:     1,
+			MaxWeight:     650,
+			MaxFutureSkew: 5 * time.Minute,
+			MinWeightedAt: time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:    true,
+			Rate:       20,
+			Burst:      40,
+			MaxStreams: 8,
+		},
+		Webhooks: WebhooksConfig{
+			Timeout:     10 * time.Second,
+			MaxAttempts: 8,
+			Retention:   30 * 24 * time.Hour,
+		},
+		Auth: AuthConfig{
+			Mode:            "none",
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+			JWT: JWTConfig{
+				JWKSRefreshInterval: time.Hour,
+				PrincipalClaim:      "sub",
+				Leeway:              time.Minute,
+			},
+		},
+	}
+}
+
+// LoadConfig loads configuration variables from, in increasing order of
+// precedence, the defaults, the config file, the environment and the command
+// line flags in args. The config file is given by the -config flag or the
+// CONFIG_FILE environment variable.
+func LoadConfig(args []string) (*Config, error) {
 	godotenv.Load()
 
-	config := &Config{}
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	Default().bindFlags(fs)
 
-	config.loadServerConfig()
-	config.loadMySQLConfig()
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
 
-	return config
-}
+	config := Default()
 
-func (c *Config) loadServerConfig() {
-	c.Server.Host = os.Getenv("HOST")
-	c.Server.Port = os.Getenv("PORT")
-	c.Server.TLS.Enabled = os.Getenv("TLS_ENABLED") == "true"
-	c.Server.TLS.CertFile = os.Getenv("TLS_CERT_FILE")
-	c.Server.TLS.KeyFile = os.Getenv("TLS_KEY_FILE")
+	if *configFile != "" {
+		if err := config.loadFile(*configFile); err != nil {
+			return nil, err
+		}
+
+		config.File = *configFile
+	}
+
+	if err := config.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := config.applyFlags(fs); err != nil {
+		return nil, err
+	}
+
+	return config, nil
 }
 
-func (c *Config) loadMySQLConfig() {
-	c.MySQL.Host = os.Getenv("MYSQL_HOST")
-	c.MySQL.Port = os.Getenv("MYSQL_PORT")
-	c.MySQL.Schema = os.Getenv("MYSQL_SCHEMA")
-	c.MySQL.User = os.Getenv("MYSQL_USER")
-	c.MySQL.Password = os.Getenv("MYSQL_PASSWORD")
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %v: %w", path, err)
+	}
+
+	return nil
 }
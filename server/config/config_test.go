@@ -0,0 +1,172 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes content to a config file in a temporary directory and
+// returns its path.
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeFile(t, `
+server:
+  port: 6000
+logging:
+  level: warn
+  format: logfmt
+database:
+  host: file-host
+  name: file-name
+  user: file-user
+`)
+
+	t.Setenv("LOG_LEVEL", "error")
+	t.Setenv("PORT", "7000")
+
+	// DB_ variables win over the legacy MYSQL_ ones, which still apply on
+	// their own
+	t.Setenv("MYSQL_HOST", "mysql-host")
+	t.Setenv("DB_HOST", "db-host")
+	t.Setenv("MYSQL_SCHEMA", "mysql-name")
+
+	conf, err := LoadConfig([]string{"-config", path, "-port", "8000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"port, set everywhere", conf.Server.Port, 8000},
+		{"log level, set in the file and the environment", conf.Logging.Level, "error"},
+		{"log format, set in the file", conf.Logging.Format, "logfmt"},
+		{"database host, set in MYSQL_HOST and DB_HOST", conf.Database.Host, "db-host"},
+		{"database name, set in the file and MYSQL_SCHEMA", conf.Database.Name, "mysql-name"},
+		{"database user, set in the file", conf.Database.User, "file-user"},
+		{"max attempts, set nowhere", conf.Webhooks.MaxAttempts, Default().Webhooks.MaxAttempts},
+		{"config file", conf.File, path},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigDurations(t *testing.T) {
+	path := writeFile(t, `
+server:
+  shutdown_timeout: 1m30s
+database:
+  conn_max_lifetime: 2h
+`)
+
+	t.Setenv("DB_QUERY_TIMEOUT", "250ms")
+
+	conf, err := LoadConfig([]string{"-config", path, "-webhooks-timeout", "3s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		got, want time.Duration
+	}{
+		{"shutdown timeout", conf.Server.ShutdownTimeout, 90 * time.Second},
+		{"connection max lifetime", conf.Database.ConnMaxLifetime, 2 * time.Hour},
+		{"query timeout", conf.Database.QueryTimeout, 250 * time.Millisecond},
+		{"webhooks timeout", conf.Webhooks.Timeout, 3 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigInvalidDurations(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"file", "server:\n  shutdown_timeout: soon\n", nil, nil, "failed to parse config file"},
+		{"environment", "", map[string]string{"WATCH_INTERVAL": "soon"}, nil, `WATCH_INTERVAL: invalid duration "soon"`},
+		{"flag", "", nil, []string{"-shutdown-timeout", "soon"}, `invalid value "soon" for flag -shutdown-timeout`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file)}, args...)
+			}
+
+			_, err := LoadConfig(args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	conf := Default()
+	conf.Server.Port = 0
+	conf.Server.TLS.Enabled = true
+	conf.Logging.Level = "verbose"
+	conf.Database.Driver = "sqlite"
+	conf.RateLimit.Methods = map[string]RateLimit{"CreateRecord": {Rate: 0, Burst: 0}}
+	conf.Auth.Mode = "jwt"
+	conf.Auth.JWT.Issuer = "https://issuer.example.com"
+	conf.Auth.JWT.Audience = "weight-tracker"
+	conf.Auth.JWT.JWKSFile = filepath.Join(t.TempDir(), "missing.json")
+
+	err := conf.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted an invalid configuration")
+	}
+
+	// every error is reported at once, one per line
+	want := []string{
+		"server.port: must be between 1 and 65535, got 0",
+		"server.tls.cert_file: required when tls is enabled",
+		"server.tls.key_file: required when tls is enabled",
+		`logging.level: unknown level "verbose"`,
+		"database.path: required for sqlite",
+		"rate_limit.methods.CreateRecord.rate: must be greater than 0",
+		"rate_limit.methods.CreateRecord.burst: must be at least 1",
+		"auth.jwt.jwks_file: stat " + conf.Auth.JWT.JWKSFile + ": no such file or directory",
+	}
+
+	if got := err.Error(); got != strings.Join(want, "\n") {
+		t.Errorf("got errors\n%v\nwant\n%v", got, strings.Join(want, "\n"))
+	}
+
+	if err = Default().Validate(); err == nil || err.Error() != "database.host: required for mysql\ndatabase.name: required for mysql\ndatabase.user: required for mysql" {
+		t.Errorf("got error %v for the defaults, want the mysql connection settings required", err)
+	}
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// loadEnv overrides configuration variables with the ones set in the
+// environment. Unset variables leave the current value untouched.
+func (c *Config) loadEnv() error {
+	var errs []error
+
+	envString("HOST", &c.Server.Host)
+	errs = append(errs, envInt("PORT", &c.Server.Port))
+	errs = append(errs, envDuration("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout))
+	errs = append(errs, envDuration("WATCH_INTERVAL", &c.Server.WatchInterval))
+	errs = append(errs, envBool("TLS_ENABLED", &c.Server.TLS.Enabled))
+	envString("TLS_CERT_FILE", &c.Server.TLS.CertFile)
+	envString("TLS_KEY_FILE", &c.Server.TLS.KeyFile)
+
+	envString("LOG_LEVEL", &c.Logging.Level)
+	envString("LOG_FORMAT", &c.Logging.Format)
+
+	errs = append(errs, envBool("TRACING_ENABLED", &c.Tracing.Enabled))
+	envString("TRACING_EXPORTER", &c.Tracing.Exporter)
+	envString("TRACING_FILE", &c.Tracing.File)
+
+	// The MYSQL_ variables predate support for other drivers and are still
+	// honored, DB_ variables take precedence over them.
+	envString("MYSQL_HOST", &c.Database.Host)
+	errs = append(errs, envInt("MYSQL_PORT", &c.Database.Port))
+	envString("MYSQL_SCHEMA", &c.Database.Name)
+	envString("MYSQL_USER", &c.Database.User)
+	envSecret("MYSQL_PASSWORD", &c.Database.Password)
+	errs = append(errs, envBool("MYSQL_AUTO_MIGRATE", &c.Database.AutoMigrate))
+
+	envString("DB_DRIVER", &c.Database.Driver)
+	envString("DB_HOST", &c.Database.Host)
+	errs = append(errs, envInt("DB_PORT", &c.Database.Port))
+	envString("DB_NAME", &c.Database.Name)
+	envString("DB_USER", &c.Database.User)
+	envSecret("DB_PASSWORD", &c.Database.Password)
+	envString("DB_SSL_MODE", &c.Database.SSLMode)
+	envString("DB_PATH", &c.Database.Path)
+	errs = append(errs, envInt("DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns))
+	errs = append(errs, envInt("DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns))
+	errs = append(errs, envDuration("DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime))
+	errs = append(errs, envDuration("DB_CONN_MAX_IDLE_TIME", &c.Database.ConnMaxIdleTime))
+	errs = append(errs, envDuration("DB_QUERY_TIMEOUT", &c.Database.QueryTimeout))
+	errs = append(errs, envDuration("DB_CONNECT_TIMEOUT", &c.Database.ConnectTimeout))
+	errs = append(errs, envBool("DB_AUTO_MIGRATE", &c.Database.AutoMigrate))
+
+	errs = append(errs, envFloat("VALIDATION_MIN_WEIGHT", &c.Validation.MinWeight))
+	errs = append(errs, envFloat("VALIDATION_MAX_WEIGHT", &c.Validation.MaxWeight))
+	errs = append(errs, envDuration("VALIDATION_MAX_FUTURE_SKEW", &c.Validation.MaxFutureSkew))
+	errs = append(errs, envTime("VALIDATION_MIN_WEIGHTED_AT", &c.Validation.MinWeightedAt))
+
+	errs = append(errs, envBool("RATE_LIMIT_ENABLED", &c.RateLimit.Enabled))
+	errs = append(errs, envFloat("RATE_LIMIT_RATE", &c.RateLimit.Rate))
+	errs = append(errs, envInt("RATE_LIMIT_BURST", &c.RateLimit.Burst))
+	errs = append(errs, envInt("RATE_LIMIT_MAX_STREAMS", &c.RateLimit.MaxStreams))
+
+	errs = append(errs, envDuration("WEBHOOKS_TIMEOUT", &c.Webhooks.Timeout))
+	errs = append(errs, envInt("WEBHOOKS_MAX_ATTEMPTS", &c.Webhooks.MaxAttempts))
+	errs = append(errs, envDuration("WEBHOOKS_RETENTION", &c.Webhooks.Retention))
+
+	envString("AUTH_MODE", &c.Auth.Mode)
+	envSecret("AUTH_SIGNING_KEY", &c.Auth.SigningKey)
+	errs = append(errs, envDuration("AUTH_ACCESS_TOKEN_TTL", &c.Auth.AccessTokenTTL))
+	errs = append(errs, envDuration("AUTH_REFRESH_TOKEN_TTL", &c.Auth.RefreshTokenTTL))
+	errs = append(errs, envBool("AUTH_ALLOW_REGISTRATION", &c.Auth.AllowRegistration))
+	envString("AUTH_JWT_ISSUER", &c.Auth.JWT.Issuer)
+	envString("AUTH_JWT_AUDIENCE", &c.Auth.JWT.Audience)
+	envString("AUTH_JWT_JWKS_FILE", &c.Auth.JWT.JWKSFile)
+	envString("AUTH_JWT_JWKS_URL", &c.Auth.JWT.JWKSURL)
+	errs = append(errs, envDuration("AUTH_JWT_JWKS_REFRESH_INTERVAL", &c.Auth.JWT.JWKSRefreshInterval))
+	envString("AUTH_JWT_PRINCIPAL_CLAIM", &c.Auth.JWT.PrincipalClaim)
+	errs = append(errs, envDuration("AUTH_JWT_LEEWAY", &c.Auth.JWT.Leeway))
+
+	return errors.Join(errs...)
+}
+
+func envString(name string, dst *string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*dst = v
+	}
+}
+
+func envSecret(name string, dst *Secret) {
+	if v, ok := os.LookupEnv(name); ok {
+		*dst = Secret(v)
+	}
+}
+
+func envInt(name string, dst *int) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%v: invalid integer %q", name, v)
+	}
+
+	*dst = i
+
+	return nil
+}
+
+func envBool(name string, dst *bool) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%v: invalid boolean %q", name, v)
+	}
+
+	*dst = b
+
+	return nil
+}
+
+func envDuration(name string, dst *time.Duration) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%v: invalid duration %q", name, v)
+	}
+
+	*dst = d
+
+	return nil
+}
+
+func envFloat(name string, dst *float64) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("%v: invalid number %q", name, v)
+	}
+
+	*dst = f
+
+	return nil
+}
+
+func envTime(name string, dst *time.Time) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+
+	t, err := parseTime(v)
+	if err != nil {
+		return fmt.Errorf("%v: invalid time %q, expected a date or RFC 3339 timestamp", name, v)
+	}
+
+	*dst = t
+
+	return nil
+}
+
+// parseTime accepts either a date (2006-01-02, taken as UTC midnight) or an
+// RFC 3339 timestamp.
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, v)
+}
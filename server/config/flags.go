@@ -0,0 +1,102 @@
+package config
+
+import (
+	"flag"
+	"time"
+)
+
+// bindFlags registers a command line flag for every configuration variable
+// that can be overridden from the command line. Secrets are left out on
+// purpose so they never show up in the process list.
+func (c *Config) bindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Server.Host, "host", c.Server.Host, "address to listen on")
+	fs.IntVar(&c.Server.Port, "port", c.Server.Port, "port to listen on")
+	fs.DurationVar(&c.Server.ShutdownTimeout, "shutdown-timeout", c.Server.ShutdownTimeout, "time to wait for in-flight calls on shutdown")
+	fs.DurationVar(&c.Server.WatchInterval, "watch-interval", c.Server.WatchInterval, "how often to check config and certificate files for changes (0 only reloads on SIGHUP)")
+	fs.BoolVar(&c.Server.TLS.Enabled, "tls", c.Server.TLS.Enabled, "enable TLS")
+	fs.StringVar(&c.Server.TLS.CertFile, "tls-cert-file", c.Server.TLS.CertFile, "TLS certificate file")
+	fs.StringVar(&c.Server.TLS.KeyFile, "tls-key-file", c.Server.TLS.KeyFile, "TLS key file")
+
+	fs.StringVar(&c.Logging.Level, "log-level", c.Logging.Level, "log level (debug, info, warn or error)")
+	fs.StringVar(&c.Logging.Format, "log-format", c.Logging.Format, "log format (json or logfmt)")
+
+	fs.BoolVar(&c.Tracing.Enabled, "tracing", c.Tracing.Enabled, "enable tracing")
+	fs.StringVar(&c.Tracing.Exporter, "tracing-exporter", c.Tracing.Exporter, "trace exporter (stdout or file)")
+	fs.StringVar(&c.Tracing.File, "tracing-file", c.Tracing.File, "file to write traces to")
+
+	fs.StringVar(&c.Database.Driver, "db-driver", c.Database.Driver, "database driver (mysql, postgres, sqlite or file)")
+	fs.StringVar(&c.Database.Host, "db-host", c.Database.Host, "database host")
+	fs.IntVar(&c.Database.Port, "db-port", c.Database.Port, "database port (0 uses the driver's default)")
+	fs.StringVar(&c.Database.Name, "db-name", c.Database.Name, "database name")
+	fs.StringVar(&c.Database.User, "db-user", c.Database.User, "database user")
+	fs.StringVar(&c.Database.SSLMode, "db-ssl-mode", c.Database.SSLMode, "postgres sslmode")
+	fs.StringVar(&c.Database.Path, "db-path", c.Database.Path, "sqlite database or file store path")
+	fs.IntVar(&c.Database.MaxOpenConns, "db-max-open-conns", c.Database.MaxOpenConns, "maximum number of open database connections (0 means unlimited)")
+	fs.IntVar(&c.Database.MaxIdleConns, "db-max-idle-conns", c.Database.MaxIdleConns, "maximum number of idle database connections")
+	fs.DurationVar(&c.Database.ConnMaxLifetime, "db-conn-max-lifetime", c.Database.ConnMaxLifetime, "maximum time a database connection is reused (0 means forever)")
+	fs.DurationVar(&c.Database.ConnMaxIdleTime, "db-conn-max-idle-time", c.Database.ConnMaxIdleTime, "maximum time a database connection stays idle (0 means forever)")
+	fs.DurationVar(&c.Database.QueryTimeout, "db-query-timeout", c.Database.QueryTimeout, "timeout for a single database query (0 means none)")
+	fs.DurationVar(&c.Database.ConnectTimeout, "db-connect-timeout", c.Database.ConnectTimeout, "how long to keep retrying to connect to the database on startup")
+	fs.BoolVar(&c.Database.AutoMigrate, "db-auto-migrate", c.Database.AutoMigrate, "apply pending schema migrations on startup")
+
+	fs.Float64Var(&c.Validation.MinWeight, "min-weight", c.Validation.MinWeight, "lowest accepted weight")
+	fs.Float64Var(&c.Validation.MaxWeight, "max-weight", c.Validation.MaxWeight, "highest accepted weight")
+	fs.DurationVar(&c.Validation.MaxFutureSkew, "max-future-skew", c.Validation.MaxFutureSkew, "how far in the future weighted_at may be")
+	fs.Var((*timeValue)(&c.Validation.MinWeightedAt), "min-weighted-at", "earliest accepted weighted_at (date or RFC 3339 timestamp)")
+
+	fs.BoolVar(&c.RateLimit.Enabled, "rate-limit", c.RateLimit.Enabled, "enable per client rate limiting")
+	fs.Float64Var(&c.RateLimit.Rate, "rate-limit-rate", c.RateLimit.Rate, "calls per second allowed per client")
+	fs.IntVar(&c.RateLimit.Burst, "rate-limit-burst", c.RateLimit.Burst, "calls a client can make at once before the rate applies")
+	fs.IntVar(&c.RateLimit.MaxStreams, "max-streams", c.RateLimit.MaxStreams, "concurrent streaming calls allowed per client (0 means unlimited)")
+
+	fs.DurationVar(&c.Webhooks.Timeout, "webhooks-timeout", c.Webhooks.Timeout, "timeout for a single webhook delivery attempt")
+	fs.IntVar(&c.Webhooks.MaxAttempts, "webhooks-max-attempts", c.Webhooks.MaxAttempts, "webhook delivery attempts before giving up")
+	fs.DurationVar(&c.Webhooks.Retention, "webhooks-retention", c.Webhooks.Retention, "how long finished webhook deliveries are kept (0 means forever)")
+
+	fs.StringVar(&c.Auth.Mode, "auth-mode", c.Auth.Mode, "how callers are authenticated (none, accounts or jwt)")
+	fs.DurationVar(&c.Auth.AccessTokenTTL, "access-token-ttl", c.Auth.AccessTokenTTL, "how long an access token is accepted")
+	fs.DurationVar(&c.Auth.RefreshTokenTTL, "refresh-token-ttl", c.Auth.RefreshTokenTTL, "how long a refresh token can be used")
+	fs.BoolVar(&c.Auth.AllowRegistration, "allow-registration", c.Auth.AllowRegistration, "allow anyone to create an account")
+	fs.StringVar(&c.Auth.JWT.Issuer, "jwt-issuer", c.Auth.JWT.Issuer, "issuer JWTs must be issued by")
+	fs.StringVar(&c.Auth.JWT.Audience, "jwt-audience", c.Auth.JWT.Audience, "audience JWTs must be issued for")
+	fs.StringVar(&c.Auth.JWT.JWKSFile, "jwt-jwks-file", c.Auth.JWT.JWKSFile, "JWKS file with the keys JWTs are signed with")
+	fs.StringVar(&c.Auth.JWT.JWKSURL, "jwt-jwks-url", c.Auth.JWT.JWKSURL, "URL of the JWKS with the keys JWTs are signed with")
+	fs.DurationVar(&c.Auth.JWT.JWKSRefreshInterval, "jwt-jwks-refresh-interval", c.Auth.JWT.JWKSRefreshInterval, "how often the JWKS is reloaded")
+	fs.StringVar(&c.Auth.JWT.PrincipalClaim, "jwt-principal-claim", c.Auth.JWT.PrincipalClaim, "JWT claim identifying the caller")
+	fs.DurationVar(&c.Auth.JWT.Leeway, "jwt-leeway", c.Auth.JWT.Leeway, "clock skew allowed when checking JWT timestamps")
+}
+
+// timeValue is a flag.Value for dates and timestamps.
+type timeValue time.Time
+
+func (t *timeValue) String() string {
+	return time.Time(*t).Format(time.RFC3339)
+}
+
+func (t *timeValue) Set(v string) error {
+	parsed, err := parseTime(v)
+	if err != nil {
+		return err
+	}
+
+	*t = timeValue(parsed)
+
+	return nil
+}
+
+// applyFlags copies the flags explicitly set on fs over c.
+func (c *Config) applyFlags(fs *flag.FlagSet) error {
+	target := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	c.bindFlags(target)
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		if err != nil || target.Lookup(f.Name) == nil {
+			return
+		}
+
+		err = target.Set(f.Name, f.Value.String())
+	})
+
+	return err
+}
@@ -0,0 +1,349 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+)
+
+// Validate checks the configuration and reports every missing or invalid
+// variable at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	errs = append(errs, c.Server.validate()...)
+	errs = append(errs, c.Logging.validate()...)
+	errs = append(errs, c.Tracing.validate()...)
+	errs = append(errs, c.Database.validate()...)
+	errs = append(errs, c.Validation.validate()...)
+	errs = append(errs, c.RateLimit.validate()...)
+	errs = append(errs, c.Webhooks.validate()...)
+	errs = append(errs, c.Auth.validate()...)
+
+	return errors.Join(errs...)
+}
+
+func (s *ServerConfig) validate() []error {
+	var errs []error
+
+	if err := validatePort("server.port", s.Port); err != nil {
+		errs = append(errs, err)
+	}
+
+	if s.ShutdownTimeout < 0 {
+		errs = append(errs, fmt.Errorf("server.shutdown_timeout: must not be negative"))
+	}
+
+	if s.WatchInterval < 0 {
+		errs = append(errs, fmt.Errorf("server.watch_interval: must not be negative"))
+	}
+
+	if s.TLS.Enabled {
+		if err := validateFile("server.tls.cert_file", s.TLS.CertFile, "when tls is enabled"); err != nil {
+			errs = append(errs, err)
+		}
+
+		if err := validateFile("server.tls.key_file", s.TLS.KeyFile, "when tls is enabled"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+func (l *LoggingConfig) validate() []error {
+	var errs []error
+
+	switch l.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("logging.level: unknown level %q", l.Level))
+	}
+
+	switch l.Format {
+	case "json", "logfmt":
+	default:
+		errs = append(errs, fmt.Errorf("logging.format: unknown format %q", l.Format))
+	}
+
+	return errs
+}
+
+func (t *TracingConfig) validate() []error {
+	if !t.Enabled {
+		return nil
+	}
+
+	switch t.Exporter {
+	case "stdout":
+	case "file":
+		if t.File == "" {
+			return []error{fmt.Errorf("tracing.file: required when exporter is file")}
+		}
+	default:
+		return []error{fmt.Errorf("tracing.exporter: unknown exporter %q", t.Exporter)}
+	}
+
+	return nil
+}
+
+func (d *DatabaseConfig) validate() []error {
+	var errs []error
+
+	switch d.Driver {
+	case "mysql", "postgres":
+		if d.Host == "" {
+			errs = append(errs, fmt.Errorf("database.host: required for %v", d.Driver))
+		}
+
+		if d.Port != 0 {
+			if err := validatePort("database.port", d.Port); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if d.Name == "" {
+			errs = append(errs, fmt.Errorf("database.name: required for %v", d.Driver))
+		}
+
+		if d.User == "" {
+			errs = append(errs, fmt.Errorf("database.user: required for %v", d.Driver))
+		}
+	case "sqlite", "file":
+		if d.Path == "" {
+			errs = append(errs, fmt.Errorf("database.path: required for %v", d.Driver))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("database.driver: unknown driver %q", d.Driver))
+	}
+
+	if d.MaxOpenConns < 0 {
+		errs = append(errs, fmt.Errorf("database.max_open_conns: must not be negative"))
+	}
+
+	if d.MaxIdleConns < 0 {
+		errs = append(errs, fmt.Errorf("database.max_idle_conns: must not be negative"))
+	}
+
+	if d.MaxOpenConns > 0 && d.MaxIdleConns > d.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("database.max_idle_conns: must not exceed max_open_conns"))
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"conn_max_lifetime", d.ConnMaxLifetime},
+		{"conn_max_idle_time", d.ConnMaxIdleTime},
+		{"query_timeout", d.QueryTimeout},
+		{"connect_timeout", d.ConnectTimeout},
+	}
+	for _, duration := range durations {
+		if duration.value < 0 {
+			errs = append(errs, fmt.Errorf("database.%v: must not be negative", duration.name))
+		}
+	}
+
+	return errs
+}
+
+func (v *ValidationConfig) validate() []error {
+	var errs []error
+
+	if v.MinWeight <= 0 {
+		errs = append(errs, fmt.Errorf("validation.min_weight: must be greater than 0"))
+	}
+
+	if v.MaxWeight < v.MinWeight {
+		errs = append(errs, fmt.Errorf("validation.max_weight: must not be lower than min_weight"))
+	}
+
+	// weights are stored as DECIMAL(5,2)
+	if v.MaxWeight >= 1000 {
+		errs = append(errs, fmt.Errorf("validation.max_weight: must be lower than 1000"))
+	}
+
+	if v.MaxFutureSkew < 0 {
+		errs = append(errs, fmt.Errorf("validation.max_future_skew: must not be negative"))
+	}
+
+	return errs
+}
+
+func (r *RateLimitConfig) validate() []error {
+	var errs []error
+
+	errs = append(errs, RateLimit{Rate: r.Rate, Burst: r.Burst}.validate("rate_limit")...)
+
+	methods := make([]string, 0, len(r.Methods))
+	for method := range r.Methods {
+		methods = append(methods, method)
+	}
+
+	// keep the errors in a stable order
+	sort.Strings(methods)
+
+	for _, method := range methods {
+		errs = append(errs, r.Methods[method].validate("rate_limit.methods."+method)...)
+	}
+
+	if r.MaxStreams < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.max_streams: must not be negative"))
+	}
+
+	return errs
+}
+
+func (r RateLimit) validate(prefix string) []error {
+	var errs []error
+
+	if r.Rate <= 0 {
+		errs = append(errs, fmt.Errorf("%v.rate: must be greater than 0", prefix))
+	}
+
+	if r.Burst < 1 {
+		errs = append(errs, fmt.Errorf("%v.burst: must be at least 1", prefix))
+	}
+
+	return errs
+}
+
+func (w *WebhooksConfig) validate() []error {
+	var errs []error
+
+	names := map[string]bool{}
+	for i, sub := range w.Subscriptions {
+		prefix := fmt.Sprintf("webhooks.subscriptions[%d]", i)
+
+		switch {
+		case sub.Name == "":
+			errs = append(errs, fmt.Errorf("%v.name: required", prefix))
+		case names[sub.Name]:
+			errs = append(errs, fmt.Errorf("%v.name: duplicate name %q", prefix, sub.Name))
+		}
+		names[sub.Name] = true
+
+		if u, err := url.Parse(sub.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%v.url: must be an http or https URL", prefix))
+		}
+
+		if sub.Secret == "" {
+			errs = append(errs, fmt.Errorf("%v.secret: required", prefix))
+		}
+
+		for _, event := range sub.Events {
+			switch event {
+			case "created", "updated", "deleted":
+			default:
+				errs = append(errs, fmt.Errorf("%v.events: unknown event %q", prefix, event))
+			}
+		}
+	}
+
+	if w.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("webhooks.timeout: must be greater than 0"))
+	}
+
+	if w.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("webhooks.max_attempts: must be at least 1"))
+	}
+
+	if w.Retention < 0 {
+		errs = append(errs, fmt.Errorf("webhooks.retention: must not be negative"))
+	}
+
+	return errs
+}
+
+func (a *AuthConfig) validate() []error {
+	var errs []error
+
+	switch a.Mode {
+	case "none":
+		return nil
+	case "accounts":
+		if len(a.SigningKey) < 32 {
+			errs = append(errs, fmt.Errorf("auth.signing_key: must be at least 32 bytes long for accounts"))
+		}
+	case "jwt":
+		return a.JWT.validate()
+	default:
+		return []error{fmt.Errorf("auth.mode: unknown mode %q", a.Mode)}
+	}
+
+	if a.AccessTokenTTL <= 0 {
+		errs = append(errs, fmt.Errorf("auth.access_token_ttl: must be greater than 0"))
+	}
+
+	if a.RefreshTokenTTL < a.AccessTokenTTL {
+		errs = append(errs, fmt.Errorf("auth.refresh_token_ttl: must not be lower than access_token_ttl"))
+	}
+
+	return errs
+}
+
+func (j *JWTConfig) validate() []error {
+	var errs []error
+
+	if j.Issuer == "" {
+		errs = append(errs, fmt.Errorf("auth.jwt.issuer: required for jwt"))
+	}
+
+	if j.Audience == "" {
+		errs = append(errs, fmt.Errorf("auth.jwt.audience: required for jwt"))
+	}
+
+	switch {
+	case j.JWKSFile != "" && j.JWKSURL != "":
+		errs = append(errs, fmt.Errorf("auth.jwt.jwks_url: only one of jwks_file and jwks_url can be set"))
+	case j.JWKSFile != "":
+		if err := validateFile("auth.jwt.jwks_file", j.JWKSFile, "for jwt"); err != nil {
+			errs = append(errs, err)
+		}
+	case j.JWKSURL != "":
+		if u, err := url.Parse(j.JWKSURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("auth.jwt.jwks_url: must be an http or https URL"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("auth.jwt.jwks_file: one of jwks_file and jwks_url is required for jwt"))
+	}
+
+	if j.JWKSRefreshInterval <= 0 {
+		errs = append(errs, fmt.Errorf("auth.jwt.jwks_refresh_interval: must be greater than 0"))
+	}
+
+	if j.PrincipalClaim == "" {
+		errs = append(errs, fmt.Errorf("auth.jwt.principal_claim: required for jwt"))
+	}
+
+	if j.Leeway < 0 {
+		errs = append(errs, fmt.Errorf("auth.jwt.leeway: must not be negative"))
+	}
+
+	return errs
+}
+
+func validatePort(name string, port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%v: must be between 1 and 65535, got %d", name, port)
+	}
+
+	return nil
+}
+
+// validateFile checks that path names an existing file. It is required
+// under the condition given by when, e.g. "when tls is enabled".
+func validateFile(name, path, when string) error {
+	if path == "" {
+		return fmt.Errorf("%v: required %v", name, when)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%v: %w", name, err)
+	}
+
+	return nil
+}
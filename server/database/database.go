@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/config"
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"github.com/0gener/go-weight-tracker/server/tracing"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// dialTimeout bounds a single connection attempt so that retries happen even
+// when the database host silently drops packets.
+const dialTimeout = 5 * time.Second
+
+var defaultPorts = map[string]int{
+	"mysql":    3306,
+	"postgres": 5432,
+}
+
+// Open connects to the database described by dbConfig. If the database is
+// not reachable yet, it keeps retrying with exponential backoff for up to
+// dbConfig.ConnectTimeout or until ctx is done.
+func Open(ctx context.Context, dbConfig config.DatabaseConfig) (*gorm.DB, error) {
+	dialector, err := Dialector(dbConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := openWithRetry(ctx, dialector, dbConfig.ConnectTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %v: %w", dbConfig.Driver, err)
+	}
+
+	if err = db.Use(tracing.GormPlugin()); err != nil {
+		return nil, fmt.Errorf("failed to register tracing plugin: %w", err)
+	}
+
+	if err = db.Use(timeoutPlugin{timeout: dbConfig.QueryTimeout}); err != nil {
+		return nil, fmt.Errorf("failed to register timeout plugin: %w", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB.SetMaxOpenConns(dbConfig.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(dbConfig.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(dbConfig.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(dbConfig.ConnMaxIdleTime)
+
+	if dbConfig.Driver == "sqlite" && dbConfig.Path == ":memory:" {
+		// every connection to :memory: opens a new, empty database
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
+
+	return db, nil
+}
+
+const (
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 10 * time.Second
+)
+
+func openWithRetry(ctx context.Context, dialector gorm.Dialector, maxWait time.Duration) (*gorm.DB, error) {
+	deadline := time.Now().Add(maxWait)
+	backoff := initialBackoff
+
+	for attempt := 1; ; attempt++ {
+		db, err := gorm.Open(dialector, &gorm.Config{
+			Logger:         logging.GormLogger(),
+			TranslateError: true,
+			// timestamps are compared as strings by sqlite, keep them all in UTC
+			NowFunc: func() time.Time { return time.Now().UTC() },
+		})
+		if err == nil {
+			return db, nil
+		}
+
+		// jitter avoids several replicas hammering the database in lockstep
+		delay := backoff/2 + time.Duration(rand.Int64N(int64(backoff/2)))
+		if time.Now().Add(delay).After(deadline) {
+			return nil, err
+		}
+
+		slog.Warn("database is not reachable, retrying", "attempt", attempt, "retry_in", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// Dialector returns the gorm dialector for dbConfig.Driver, configured with
+// a DSN built from dbConfig.
+func Dialector(dbConfig config.DatabaseConfig) (gorm.Dialector, error) {
+	switch dbConfig.Driver {
+	case "mysql":
+		return mysql.Open(mysqlDSN(dbConfig)), nil
+	case "postgres":
+		return postgres.Open(postgresDSN(dbConfig)), nil
+	case "sqlite":
+		return sqlite.Open(sqliteDSN(dbConfig)), nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", dbConfig.Driver)
+	}
+}
+
+func port(dbConfig config.DatabaseConfig) int {
+	if dbConfig.Port != 0 {
+		return dbConfig.Port
+	}
+
+	return defaultPorts[dbConfig.Driver]
+}
+
+func mysqlDSN(dbConfig config.DatabaseConfig) string {
+	cfg := mysqldriver.NewConfig()
+	cfg.User = dbConfig.User
+	cfg.Passwd = string(dbConfig.Password)
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(dbConfig.Host, strconv.Itoa(port(dbConfig)))
+	cfg.DBName = dbConfig.Name
+	cfg.ParseTime = true
+	cfg.Timeout = dialTimeout
+	cfg.Params = map[string]string{"charset": "utf8mb4"}
+
+	return cfg.FormatDSN()
+}
+
+func postgresDSN(dbConfig config.DatabaseConfig) string {
+	params := []string{
+		"host=" + quoteKeyword(dbConfig.Host),
+		fmt.Sprintf("port=%d", port(dbConfig)),
+		fmt.Sprintf("connect_timeout=%d", int(dialTimeout.Seconds())),
+		"user=" + quoteKeyword(dbConfig.User),
+		"password=" + quoteKeyword(string(dbConfig.Password)),
+		"dbname=" + quoteKeyword(dbConfig.Name),
+	}
+
+	if dbConfig.SSLMode != "" {
+		params = append(params, "sslmode="+quoteKeyword(dbConfig.SSLMode))
+	}
+
+	return strings.Join(params, " ")
+}
+
+// quoteKeyword quotes a value for a libpq keyword/value connection string.
+func quoteKeyword(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+
+	return "'" + v + "'"
+}
+
+func sqliteDSN(dbConfig config.DatabaseConfig) string {
+	query := url.Values{}
+	query.Set("_busy_timeout", "5000")
+	query.Set("_foreign_keys", "on")
+	// SQLite has no row locks, take the write lock when a transaction begins
+	// so concurrent ones wait for each other instead of failing
+	query.Set("_txlock", "immediate")
+	if dbConfig.Path != ":memory:" {
+		query.Set("_journal_mode", "WAL")
+	}
+
+	return "file:" + dbConfig.Path + "?" + query.Encode()
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	timeoutCancelKey  = "timeout:cancel"
+	timeoutContextKey = "timeout:context"
+)
+
+type noTimeoutKey struct{}
+
+// WithoutQueryTimeout returns a copy of ctx whose queries are not bound by
+// the configured query timeout, e.g. for long running schema migrations.
+func WithoutQueryTimeout(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noTimeoutKey{}, true)
+}
+
+// timeoutPlugin bounds every create, query, update, delete and raw exec with
+// a timeout, on top of whatever deadline the caller's context already has.
+// Row and Rows are left alone since their results are read after the
+// callbacks return.
+type timeoutPlugin struct {
+	timeout time.Duration
+}
+
+func (timeoutPlugin) Name() string {
+	return "timeout"
+}
+
+func (p timeoutPlugin) Initialize(db *gorm.DB) error {
+	if p.timeout <= 0 {
+		return nil
+	}
+
+	cb := db.Callback()
+
+	if err := cb.Create().Before("*").Register("timeout:before_create", p.before); err != nil {
+		return err
+	}
+	if err := cb.Create().After("*").Register("timeout:after_create", p.after); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("*").Register("timeout:before_query", p.before); err != nil {
+		return err
+	}
+	if err := cb.Query().After("*").Register("timeout:after_query", p.after); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("*").Register("timeout:before_update", p.before); err != nil {
+		return err
+	}
+	if err := cb.Update().After("*").Register("timeout:after_update", p.after); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("*").Register("timeout:before_delete", p.before); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("*").Register("timeout:after_delete", p.after); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("*").Register("timeout:before_raw", p.before); err != nil {
+		return err
+	}
+
+	return cb.Raw().After("*").Register("timeout:after_raw", p.after)
+}
+
+func (p timeoutPlugin) before(db *gorm.DB) {
+	if skip, _ := db.Statement.Context.Value(noTimeoutKey{}).(bool); skip {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(db.Statement.Context, p.timeout)
+
+	db.InstanceSet(timeoutContextKey, db.Statement.Context)
+	db.InstanceSet(timeoutCancelKey, cancel)
+	db.Statement.Context = ctx
+}
+
+func (timeoutPlugin) after(db *gorm.DB) {
+	cancel, ok := db.InstanceGet(timeoutCancelKey)
+	if !ok {
+		return
+	}
+
+	cancel.(context.CancelFunc)()
+
+	// restore the caller's context so the statement can be reused
+	if ctx, ok := db.InstanceGet(timeoutContextKey); ok {
+		db.Statement.Context = ctx.(context.Context)
+	}
+}
@@ -0,0 +1,233 @@
+package events
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+)
+
+// historySize is how many past events are kept for subscribers resuming
+// after a reconnect.
+const historySize = 1024
+
+// subscriptionBuffer is how many events a subscriber can fall behind before
+// it is dropped.
+const subscriptionBuffer = 256
+
+var (
+	// ErrResumeUnavailable is returned when the events following a resume
+	// point are no longer, or never were, kept by the broker.
+	ErrResumeUnavailable = errors.New("events after the resume point are no longer available")
+
+	// ErrSlowSubscriber ends subscriptions that fell too far behind.
+	ErrSlowSubscriber = errors.New("subscriber fell behind")
+
+	// ErrClosed ends subscriptions when the broker is closed.
+	ErrClosed = errors.New("broker closed")
+)
+
+// Type is the kind of change an event describes.
+type Type int
+
+// Types of event.
+const (
+	Created Type = iota + 1
+	Updated
+	Deleted
+)
+
+func (t Type) String() string {
+	switch t {
+	case Created:
+		return "created"
+	case Updated:
+		return "updated"
+	case Deleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change to a record. Previous holds the record before an update
+// or delete, Record holds it after a create or update and before a delete.
+type Event struct {
+	Sequence   uint64
+	Type       Type
+	Record     store.Record
+	Previous   *store.Record
+	OccurredAt time.Time
+}
+
+// Broker fans out record changes to in-process subscribers. Sequence
+// numbers start from the broker's creation time in microseconds, so they
+// keep increasing across restarts and resuming from a sequence issued by a
+// previous process is detected as unavailable rather than silently skipping
+// the events in between.
+type Broker struct {
+	mu      sync.Mutex
+	seq     uint64
+	history []Event // ring buffer, oldest first starting at start
+	start   int
+	subs    map[*Subscription]struct{}
+	closed  bool
+	now     func() time.Time
+}
+
+// NewBroker creates a broker. now is used to timestamp events.
+func NewBroker(now func() time.Time) *Broker {
+	return &Broker{
+		seq:  uint64(now().UnixMicro()),
+		subs: map[*Subscription]struct{}{},
+		now:  now,
+	}
+}
+
+// Publish records a change, delivers it to every subscriber and returns it.
+func (b *Broker) Publish(typ Type, rec store.Record, prev *store.Record) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return Event{Type: typ, Record: rec, Previous: prev, OccurredAt: b.now().UTC()}
+	}
+
+	b.seq++
+	e := Event{Sequence: b.seq, Type: typ, Record: rec, Previous: prev, OccurredAt: b.now().UTC()}
+
+	if len(b.history) < historySize {
+		b.history = append(b.history, e)
+	} else {
+		b.history[b.start] = e
+		b.start = (b.start + 1) % historySize
+	}
+
+	for sub := range b.subs {
+		select {
+		case sub.c <- e:
+		default:
+			b.drop(sub, ErrSlowSubscriber)
+		}
+	}
+
+	return e
+}
+
+// Subscribe starts receiving events. If after is 0 only new events are
+// received, otherwise every event with a greater sequence number is
+// replayed first, or ErrResumeUnavailable is returned if some of them are
+// gone.
+func (b *Broker) Subscribe(after uint64) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	var missed []Event
+	if after != 0 {
+		var err error
+		if missed, err = b.since(after); err != nil {
+			return nil, err
+		}
+	}
+
+	sub := &Subscription{
+		b:    b,
+		c:    make(chan Event, len(missed)+subscriptionBuffer),
+		done: make(chan struct{}),
+	}
+
+	for _, e := range missed {
+		sub.c <- e
+	}
+
+	b.subs[sub] = struct{}{}
+
+	return sub, nil
+}
+
+// Close ends every subscription.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		b.drop(sub, ErrClosed)
+	}
+}
+
+// since returns the events after the given sequence number. Must be called
+// with the lock held.
+func (b *Broker) since(after uint64) ([]Event, error) {
+	if after > b.seq {
+		return nil, ErrResumeUnavailable
+	}
+
+	oldest := b.seq + 1 // nothing kept yet
+	if len(b.history) > 0 {
+		oldest = b.history[b.start].Sequence
+	}
+
+	if after+1 < oldest {
+		return nil, ErrResumeUnavailable
+	}
+
+	var missed []Event
+	for i := range b.history {
+		e := b.history[(b.start+i)%len(b.history)]
+		if e.Sequence > after {
+			missed = append(missed, e)
+		}
+	}
+
+	return missed, nil
+}
+
+// drop ends sub with err. Must be called with the lock held.
+func (b *Broker) drop(sub *Subscription, err error) {
+	delete(b.subs, sub)
+	sub.err = err
+	close(sub.done)
+}
+
+// Subscription receives events from a broker.
+type Subscription struct {
+	b    *Broker
+	c    chan Event
+	done chan struct{}
+	err  error
+}
+
+// Events returns the channel events are delivered on.
+func (s *Subscription) Events() <-chan Event {
+	return s.c
+}
+
+// Done is closed when the subscription is ended by the broker, after which
+// Err reports why.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns why the subscription ended, once Done is closed.
+func (s *Subscription) Err() error {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+
+	return s.err
+}
+
+// Close stops receiving events.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+
+	if _, ok := s.b.subs[s]; ok {
+		s.b.drop(s, nil)
+	}
+}
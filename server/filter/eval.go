@@ -0,0 +1,79 @@
+package filter
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Eval reports whether expr matches the fields returned by value, which
+// must return a float64, time.Time, string or []string depending on the
+// type of the field. A nil expr matches everything.
+func Eval(expr Expr, value func(field string) any) bool {
+	switch e := expr.(type) {
+	case nil:
+		return true
+	case And:
+		for _, expr := range e.Exprs {
+			if !Eval(expr, value) {
+				return false
+			}
+		}
+
+		return true
+	case Or:
+		for _, expr := range e.Exprs {
+			if Eval(expr, value) {
+				return true
+			}
+		}
+
+		return false
+	case Not:
+		return !Eval(e.Expr, value)
+	case Comparison:
+		return compare(e, value(e.Field))
+	}
+
+	return false
+}
+
+func compare(c Comparison, v any) bool {
+	var cmp int
+
+	switch v := v.(type) {
+	case []string:
+		return slices.Contains(v, c.Value.(string))
+	case float64:
+		want := c.Value.(float64)
+		switch {
+		case v < want:
+			cmp = -1
+		case v > want:
+			cmp = 1
+		}
+	case time.Time:
+		cmp = v.Compare(c.Value.(time.Time))
+	case string:
+		cmp = strings.Compare(v, c.Value.(string))
+	default:
+		return false
+	}
+
+	switch c.Op {
+	case Equal:
+		return cmp == 0
+	case NotEqual:
+		return cmp != 0
+	case Less:
+		return cmp < 0
+	case LessEqual:
+		return cmp <= 0
+	case Greater:
+		return cmp > 0
+	case GreaterEqual:
+		return cmp >= 0
+	}
+
+	return false
+}
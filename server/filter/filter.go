@@ -0,0 +1,117 @@
+// Package filter parses AIP-160 style filter expressions, such as
+//
+//	weight > 80 AND weighted_at >= "2024-01-01" AND tags:"travel"
+//
+// into an AST checked against a schema of the fields that can be filtered.
+//
+// The grammar is a subset of AIP-160:
+//
+//	filter      = [ expression ]
+//	expression  = sequence { "AND" sequence }
+//	sequence    = factor { factor }
+//	factor      = term { "OR" term }
+//	term        = [ "NOT" | "-" ] simple
+//	simple      = restriction | "(" expression ")"
+//	restriction = field comparator value
+//	comparator  = "=" | "!=" | "<" | "<=" | ">" | ">=" | ":"
+//	value       = string | text
+//
+// As in AIP-160, OR binds tighter than AND and terms in a sequence are
+// joined with AND. Strings are double or single quoted, text is a bare word
+// such as a number or a date.
+package filter
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxLength is the longest filter accepted by Parse.
+const MaxLength = 2048
+
+// maxDepth bounds how deeply expressions can be nested.
+const maxDepth = 32
+
+// Type is the type of a field.
+type Type int
+
+// Field types. Strings support every comparator and compare
+// lexicographically. Lists of strings only support ":", which matches
+// lists containing the value.
+const (
+	String Type = iota
+	Number
+	Timestamp
+	StringList
+)
+
+// Schema maps the name of every field that can be filtered to its type.
+type Schema map[string]Type
+
+// Operator is a comparator.
+type Operator string
+
+// Comparators.
+const (
+	Equal        Operator = "="
+	NotEqual     Operator = "!="
+	Less         Operator = "<"
+	LessEqual    Operator = "<="
+	Greater      Operator = ">"
+	GreaterEqual Operator = ">="
+	Has          Operator = ":"
+)
+
+// Expr is a node of a parsed filter: And, Or, Not or Comparison.
+type Expr interface {
+	isExpr()
+}
+
+// And matches when all of its expressions match.
+type And struct {
+	Exprs []Expr
+}
+
+// Or matches when any of its expressions match.
+type Or struct {
+	Exprs []Expr
+}
+
+// Not matches when its expression does not.
+type Not struct {
+	Expr Expr
+}
+
+// Comparison compares a field to a value, which is a float64 for Number
+// fields, a time.Time for Timestamp fields and a string otherwise.
+type Comparison struct {
+	Field string
+	Op    Operator
+	Value any
+}
+
+func (And) isExpr()        {}
+func (Or) isExpr()         {}
+func (Not) isExpr()        {}
+func (Comparison) isExpr() {}
+
+// Error describes why a filter is invalid. Pos is the byte offset of the
+// offending token.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("at position %d: %v", e.Pos, e.Msg)
+}
+
+// ParseTimestamp parses a timestamp value, either RFC 3339 or a date, which
+// means midnight UTC.
+func ParseTimestamp(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, s)
+}
@@ -0,0 +1,173 @@
+package filter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var schema = Schema{
+	"weight":      Number,
+	"weighted_at": Timestamp,
+	"source":      String,
+	"tags":        StringList,
+}
+
+func cmp(field string, op Operator, value any) Comparison {
+	return Comparison{Field: field, Op: op, Value: value}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Expr
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"weight > 80", cmp("weight", Greater, 80.0)},
+		{"weight>=-1.5", cmp("weight", GreaterEqual, -1.5)},
+		{`weighted_at < "2024-01-01"`, cmp("weighted_at", Less, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))},
+		{`weighted_at <= "2024-01-01T02:00:00+02:00"`, cmp("weighted_at", LessEqual, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))},
+		{"tags:travel", cmp("tags", Has, "travel")},
+
+		// OR binds tighter than AND, and a sequence of terms is an AND
+		{"weight = 1 OR weight = 2 AND source = a", And{[]Expr{
+			Or{[]Expr{cmp("weight", Equal, 1.0), cmp("weight", Equal, 2.0)}},
+			cmp("source", Equal, "a"),
+		}}},
+		{"source = a AND weight = 1 OR weight = 2", And{[]Expr{
+			cmp("source", Equal, "a"),
+			Or{[]Expr{cmp("weight", Equal, 1.0), cmp("weight", Equal, 2.0)}},
+		}}},
+		{"source = a weight = 1 OR weight = 2", And{[]Expr{
+			cmp("source", Equal, "a"),
+			Or{[]Expr{cmp("weight", Equal, 1.0), cmp("weight", Equal, 2.0)}},
+		}}},
+		{"(source = a AND weight = 1) OR weight = 2", Or{[]Expr{
+			And{[]Expr{cmp("source", Equal, "a"), cmp("weight", Equal, 1.0)}},
+			cmp("weight", Equal, 2.0),
+		}}},
+
+		// NOT binds tightest
+		{"NOT source = a OR weight = 1", Or{[]Expr{
+			Not{cmp("source", Equal, "a")},
+			cmp("weight", Equal, 1.0),
+		}}},
+		{"-(source = a AND weight = 1)", Not{And{[]Expr{
+			cmp("source", Equal, "a"),
+			cmp("weight", Equal, 1.0),
+		}}}},
+
+		// quoting
+		{`source = "a b"`, cmp("source", Equal, "a b")},
+		{`source = 'a "b"'`, cmp("source", Equal, `a "b"`)},
+		{`source = "a \"b\" \\"`, cmp("source", Equal, `a "b" \`)},
+		{`source = "AND"`, cmp("source", Equal, "AND")},
+		{`source = ""`, cmp("source", Equal, "")},
+		{"source = café", cmp("source", Equal, "café")},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.input, schema)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.input, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{"weight", 6, `expected a comparator after "weight"`},
+		{"weight >", 8, `expected a value after ">"`},
+		{"weight ! 80", 7, `expected "!="`},
+		{"weight == 80", 8, `expected a value after "="`},
+		{"height = 80", 0, `unknown field "height"`},
+		{"= 80", 0, "expected a field name"},
+		{"weight = heavy", 9, `"weight" expects a number`},
+		{"weighted_at > yesterday", 14, `"weighted_at" expects an RFC 3339 timestamp or a YYYY-MM-DD date`},
+		{"weight : 80", 7, `"weight" does not support ":", use "="`},
+		{"tags = travel", 5, `"tags" only supports ":"`},
+		{`source = "travel`, 9, "unterminated string"},
+		{"source = a #", 11, `unexpected character "#"`},
+		{"(weight = 80", 12, `expected ")"`},
+		{"weight = 80)", 11, `unexpected ")"`},
+		{"weight = 80 AND", 15, "expected a field name"},
+		{"weight = 80 OR OR weight = 81", 15, "expected a field name"},
+		{"NOT", 3, "expected a field name"},
+		{strings.Repeat("(", maxDepth+1) + "weight = 80" + strings.Repeat(")", maxDepth+1), maxDepth + 1, "filter is nested too deeply"},
+		{"source = " + strings.Repeat("a", MaxLength), MaxLength, "filter is longer than 2048 characters"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input, schema)
+
+		e, ok := err.(*Error)
+		if !ok {
+			t.Errorf("Parse(%q) returned error %v, want an *Error", tt.input, err)
+			continue
+		}
+
+		if e.Pos != tt.pos || e.Msg != tt.msg {
+			t.Errorf("Parse(%q) = error at %d %q, want at %d %q", tt.input, e.Pos, e.Msg, tt.pos, tt.msg)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	values := map[string]any{
+		"weight":      80.5,
+		"weighted_at": time.Date(2024, 6, 15, 7, 30, 0, 0, time.UTC),
+		"source":      "scale",
+		"tags":        []string{"morning", "travel"},
+	}
+
+	value := func(field string) any { return values[field] }
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"", true},
+		{"weight = 80.5", true},
+		{"weight != 80.5", false},
+		{"weight > 80", true},
+		{"weight >= 80.5", true},
+		{"weight < 80.5", false},
+		{"weight <= 80.5", true},
+		{"weighted_at >= 2024-06-15", true},
+		{`weighted_at > "2024-06-15T07:30:00Z"`, false},
+		{`weighted_at = "2024-06-15T09:30:00+02:00"`, true},
+		{"source = scale", true},
+		{"source < scalf", true},
+		{"source > scale", false},
+		{"tags:travel", true},
+		{"tags:evening", false},
+		{"NOT tags:evening", true},
+		{"weight > 90 OR tags:travel", true},
+		{"weight > 90 AND tags:travel", false},
+		{"tags:morning tags:travel", true},
+		{"-(weight > 80 AND source = scale)", false},
+		{"source = app OR weight > 80 AND tags:evening", false},
+	}
+
+	for _, tt := range tests {
+		expr, err := Parse(tt.input, schema)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+		}
+
+		if got := Eval(expr, value); got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenText
+	tokenString
+	tokenComparator
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenMinus
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// lex splits input into tokens, ending with tokenEOF.
+func lex(input string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(input); {
+		c := input[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i++
+		case c == '"' || c == '\'':
+			s, n, err := lexString(input[i:], i)
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token{tokenString, s, i})
+			i += n
+		case strings.ContainsRune("=!<>:", rune(c)):
+			op := string(c)
+			if i+1 < len(input) && input[i+1] == '=' && c != '=' && c != ':' {
+				op += "="
+			}
+
+			if op == "!" {
+				return nil, &Error{i, `expected "!="`}
+			}
+
+			tokens = append(tokens, token{tokenComparator, op, i})
+			i += len(op)
+		case c == '-' && (i+1 >= len(input) || !isDigit(input[i+1])):
+			tokens = append(tokens, token{tokenMinus, "-", i})
+			i++
+		default:
+			n := 0
+			for i+n < len(input) && isTextByte(input[i+n]) {
+				n++
+			}
+
+			if n == 0 {
+				return nil, &Error{i, "unexpected character " + strconv.Quote(input[i:i+1])}
+			}
+
+			text := input[i : i+n]
+
+			kind := tokenText
+			switch text {
+			case "AND":
+				kind = tokenAnd
+			case "OR":
+				kind = tokenOr
+			case "NOT":
+				kind = tokenNot
+			}
+
+			tokens = append(tokens, token{kind, text, i})
+			i += n
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "", len(input)}), nil
+}
+
+// lexString reads the quoted string at the start of s, returning it
+// unquoted and how many bytes it took.
+func lexString(s string, pos int) (string, int, error) {
+	quote := s[0]
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case quote:
+			return b.String(), i + 1, nil
+		case '\\':
+			i++
+			if i < len(s) {
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return "", 0, &Error{pos, "unterminated string"}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isTextByte(c byte) bool {
+	return c == '_' || c == '.' || c == '-' || c == '+' || c >= 0x80 ||
+		unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
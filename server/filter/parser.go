@@ -0,0 +1,220 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Parse parses input and checks it against schema. An empty filter returns
+// a nil Expr, which matches everything.
+func Parse(input string, schema Schema) (Expr, error) {
+	if len(input) > MaxLength {
+		return nil, &Error{MaxLength, fmt.Sprintf("filter is longer than %d characters", MaxLength)}
+	}
+
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, schema: schema}
+
+	if p.peek().kind == tokenEOF {
+		return nil, nil
+	}
+
+	expr, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &Error{t.pos, fmt.Sprintf("unexpected %q", t.text)}
+	}
+
+	return expr, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	schema Schema
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+// expression = sequence { "AND" sequence }
+func (p *parser) expression(depth int) (Expr, error) {
+	if depth > maxDepth {
+		return nil, &Error{p.peek().pos, "filter is nested too deeply"}
+	}
+
+	var exprs []Expr
+	for {
+		expr, err := p.sequence(depth)
+		if err != nil {
+			return nil, err
+		}
+
+		exprs = append(exprs, expr)
+
+		if p.peek().kind != tokenAnd {
+			return and(exprs), nil
+		}
+
+		p.next()
+	}
+}
+
+// sequence = factor { factor }
+func (p *parser) sequence(depth int) (Expr, error) {
+	var exprs []Expr
+	for {
+		expr, err := p.factor(depth)
+		if err != nil {
+			return nil, err
+		}
+
+		exprs = append(exprs, expr)
+
+		switch p.peek().kind {
+		case tokenText, tokenLParen, tokenNot, tokenMinus:
+		default:
+			return and(exprs), nil
+		}
+	}
+}
+
+// factor = term { "OR" term }
+func (p *parser) factor(depth int) (Expr, error) {
+	var exprs []Expr
+	for {
+		expr, err := p.term(depth)
+		if err != nil {
+			return nil, err
+		}
+
+		exprs = append(exprs, expr)
+
+		if p.peek().kind != tokenOr {
+			break
+		}
+
+		p.next()
+	}
+
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+
+	return Or{Exprs: exprs}, nil
+}
+
+// term = [ "NOT" | "-" ] simple
+func (p *parser) term(depth int) (Expr, error) {
+	if k := p.peek().kind; k == tokenNot || k == tokenMinus {
+		p.next()
+
+		expr, err := p.simple(depth)
+		if err != nil {
+			return nil, err
+		}
+
+		return Not{Expr: expr}, nil
+	}
+
+	return p.simple(depth)
+}
+
+// simple = restriction | "(" expression ")"
+func (p *parser) simple(depth int) (Expr, error) {
+	if p.peek().kind != tokenLParen {
+		return p.restriction()
+	}
+
+	p.next()
+
+	expr, err := p.expression(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.next(); t.kind != tokenRParen {
+		return nil, &Error{t.pos, `expected ")"`}
+	}
+
+	return expr, nil
+}
+
+// restriction = field comparator value
+func (p *parser) restriction() (Expr, error) {
+	field := p.next()
+	if field.kind != tokenText {
+		return nil, &Error{field.pos, "expected a field name"}
+	}
+
+	typ, ok := p.schema[field.text]
+	if !ok {
+		return nil, &Error{field.pos, fmt.Sprintf("unknown field %q", field.text)}
+	}
+
+	op := p.next()
+	if op.kind != tokenComparator {
+		return nil, &Error{op.pos, fmt.Sprintf("expected a comparator after %q", field.text)}
+	}
+
+	value := p.next()
+	if value.kind != tokenText && value.kind != tokenString {
+		return nil, &Error{value.pos, fmt.Sprintf("expected a value after %q", op.text)}
+	}
+
+	c := Comparison{Field: field.text, Op: Operator(op.text)}
+
+	if (typ == StringList) != (c.Op == Has) {
+		if typ == StringList {
+			return nil, &Error{op.pos, fmt.Sprintf("%q only supports \":\"", field.text)}
+		}
+
+		return nil, &Error{op.pos, fmt.Sprintf("%q does not support \":\", use \"=\"", field.text)}
+	}
+
+	switch typ {
+	case Number:
+		n, err := strconv.ParseFloat(value.text, 64)
+		if err != nil {
+			return nil, &Error{value.pos, fmt.Sprintf("%q expects a number", field.text)}
+		}
+
+		c.Value = n
+	case Timestamp:
+		t, err := ParseTimestamp(value.text)
+		if err != nil {
+			return nil, &Error{value.pos, fmt.Sprintf("%q expects an RFC 3339 timestamp or a YYYY-MM-DD date", field.text)}
+		}
+
+		c.Value = t.UTC()
+	default:
+		c.Value = value.text
+	}
+
+	return c, nil
+}
+
+func and(exprs []Expr) Expr {
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+
+	return And{Exprs: exprs}
+}
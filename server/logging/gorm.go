@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const slowQueryThreshold = 200 * time.Millisecond
+
+// GormLogger adapts the request scoped logger to gorm's logger interface.
+// Failed and slow queries are logged as warnings, everything else at debug.
+func GormLogger() gormlogger.Interface {
+	return gormLogger{}
+}
+
+type gormLogger struct{}
+
+func (l gormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx).InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx).WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx).ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	logger := FromContext(ctx)
+	elapsed := time.Since(begin)
+
+	level := slog.LevelDebug
+	if (err != nil && !errors.Is(err, gorm.ErrRecordNotFound)) || elapsed > slowQueryThreshold {
+		level = slog.LevelWarn
+	}
+
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []any{"sql", sql, "rows", rows, "duration", elapsed}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+
+	logger.Log(ctx, level, "query", attrs...)
+}
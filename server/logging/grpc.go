@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader is the metadata key used to receive and return request IDs.
+const RequestIDHeader = "x-request-id"
+
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID assigned by the interceptors,
+// or an empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// UnaryServerInterceptor assigns a request ID to every unary RPC, attaches a
+// request scoped logger to its context and logs its outcome.
+func UnaryServerInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = newRequestContext(ctx, logger, info.FullMethod)
+
+		resp, err := handler(ctx, req)
+		logResult(ctx, start, err)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor assigns a request ID to every streaming RPC,
+// attaches a request scoped logger to its context and logs its outcome.
+func StreamServerInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := newRequestContext(ss.Context(), logger, info.FullMethod)
+
+		err := handler(srv, &loggedStream{ServerStream: ss, ctx: ctx})
+		logResult(ctx, start, err)
+
+		return err
+	}
+}
+
+type loggedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *loggedStream) Context() context.Context {
+	return s.ctx
+}
+
+func newRequestContext(ctx context.Context, logger *slog.Logger, fullMethod string) context.Context {
+	requestID := incomingRequestID(ctx)
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, requestID))
+
+	attrs := []any{"request_id", requestID, "method", fullMethod}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs, "trace_id", sc.TraceID().String())
+	}
+
+	ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+
+	return NewContext(ctx, logger.With(attrs...))
+}
+
+func logResult(ctx context.Context, start time.Time, err error) {
+	code := status.Code(err)
+
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+	}
+
+	FromContext(ctx).Log(ctx, level, "finished call", "code", code.String(), "duration", time.Since(start))
+}
+
+func incomingRequestID(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get(RequestIDHeader)
+	if len(values) == 0 || len(values[0]) > maxRequestIDLength {
+		return ""
+	}
+
+	return values[0]
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/0gener/go-weight-tracker/server/config"
+)
+
+type loggerKey struct{}
+
+// New creates a structured logger writing to w as described by loggingConfig.
+// The returned LevelVar can be used to change the level at runtime.
+func New(w io.Writer, loggingConfig config.LoggingConfig) (*slog.Logger, *slog.LevelVar, error) {
+	level := &slog.LevelVar{}
+	if err := SetLevel(level, loggingConfig.Level); err != nil {
+		return nil, nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch loggingConfig.Format {
+	case "", "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "logfmt":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, nil, fmt.Errorf("unknown log format %q", loggingConfig.Format)
+	}
+
+	return slog.New(handler), level, nil
+}
+
+// SetLevel parses name (debug, info, warn or error) and sets it on level. An
+// empty name means info.
+func SetLevel(level *slog.LevelVar, name string) error {
+	if name == "" {
+		level.Set(slog.LevelInfo)
+		return nil
+	}
+
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(name))); err != nil {
+		return fmt.Errorf("unknown log level %q", name)
+	}
+
+	level.Set(l)
+
+	return nil
+}
+
+// NewContext returns a copy of ctx carrying logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger if
+// there is none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
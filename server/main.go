@@ -2,237 +2,269 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"os"
 	"os/signal"
 	"time"
 
+	"github.com/0gener/go-weight-tracker/server/auth"
 	"github.com/0gener/go-weight-tracker/server/config"
+	"github.com/0gener/go-weight-tracker/server/database"
+	"github.com/0gener/go-weight-tracker/server/events"
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"github.com/0gener/go-weight-tracker/server/migrations"
+	"github.com/0gener/go-weight-tracker/server/ratelimit"
+	"github.com/0gener/go-weight-tracker/server/reload"
+	"github.com/0gener/go-weight-tracker/server/rpcerror"
+	"github.com/0gener/go-weight-tracker/server/service"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/0gener/go-weight-tracker/server/store/filestore"
+	"github.com/0gener/go-weight-tracker/server/store/sqlstore"
+	"github.com/0gener/go-weight-tracker/server/tracing"
+	"github.com/0gener/go-weight-tracker/server/validation"
+	"github.com/0gener/go-weight-tracker/server/webhook"
 	"github.com/0gener/go-weight-tracker/weighttracker"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/timestamppb"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
-var db2 *gorm.DB
-
-type server struct {
-	weighttracker.UnsafeWeightTrackerServer
-}
-
-// Record is ...
-type Record struct {
-	gorm.Model
-	Weight     float32   `gorm:"type:decimal(4,2);not null"`
-	WeightedAt time.Time `gorm:"not null"`
-}
-
-func (*server) CreateRecord(ctx context.Context, req *weighttracker.CreateRecordRequest) (*weighttracker.CreateRecordResponse, error) {
-	log.Printf("CreateRecord: %v\n", req)
-
-	if req.GetRecord().GetWeight() <= 0 {
-		return nil, status.Errorf(codes.InvalidArgument, "weight must be greater than 0")
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
 	}
 
-	var recordDatetime time.Time
-	if req.GetRecord().GetWeightedAt() != nil {
-		recordDatetime = req.GetRecord().GetWeightedAt().AsTime()
-	} else {
-		recordDatetime = time.Now()
-	}
+	conf, level := setup(os.Args[1:])
 
-	record := Record{
-		Weight:     req.GetRecord().GetWeight(),
-		WeightedAt: recordDatetime,
+	shutdownTracing, err := tracing.Init(conf.Tracing)
+	if err != nil {
+		fatal("failed to initialize tracing", err)
 	}
-
-	res := db2.Create(&record)
-	if res.Error != nil {
-		return nil, status.Errorf(codes.Internal, fmt.Sprintf("error while inserting record on db: %v", res.Error))
+	defer shutdownTracing(context.Background())
+
+	st := openStore(conf.Database)
+	defer st.Close()
+
+	broker := events.NewBroker(time.Now)
+	webhooks := webhook.New(conf.Webhooks, st, time.Now)
+
+	var (
+		accounts      *auth.Accounts
+		jwks          *auth.JWKS
+		authenticator auth.Authenticator
+	)
+
+	switch conf.Auth.Mode {
+	case "accounts":
+		accounts = auth.NewAccounts(conf.Auth, st, time.Now)
+		authenticator = accounts
+	case "jwt":
+		jwks = loadJWKS(conf.Auth.JWT)
+		authenticator = auth.NewJWT(conf.Auth.JWT, jwks, time.Now)
 	}
 
-	return &weighttracker.CreateRecordResponse{
-		Record: dataToRecordPb(record),
-	}, nil
-}
-
-func (*server) ReadRecord(ctx context.Context, req *weighttracker.ReadRecordRequest) (*weighttracker.ReadRecordResponse, error) {
-	log.Printf("ReadRecord: %v\n", req)
-
-	recordID := req.GetRecordId()
-
-	record := Record{}
-	res := db2.First(&record, recordID)
-	if res.Error != nil {
-		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
-			return nil, status.Errorf(codes.NotFound, fmt.Sprintf("no record found with id = %d", record.ID))
-		}
+	srv := service.New(st, validation.New(conf.Validation, time.Now), broker, webhooks, accounts, time.Now)
 
-		return nil, status.Errorf(codes.Internal, fmt.Sprintf("error while reading record from db: %v", res.Error))
+	ctx, stopBackground := context.WithCancel(context.Background())
+	go webhooks.Run(ctx)
+	if jwks != nil {
+		go jwks.Run(ctx)
+	}
+	defer stopBackground()
+
+	r := &reloader{
+		args:     os.Args[1:],
+		conf:     conf,
+		level:    level,
+		limiter:  ratelimit.New(conf.RateLimit, time.Now),
+		webhooks: webhooks,
+		jwks:     jwks,
 	}
 
-	return &weighttracker.ReadRecordResponse{
-		Record: dataToRecordPb(record),
-	}, nil
+	startServer(conf.Server, slog.Default(), srv, authenticator, broker, r)
 }
 
-func (*server) UpdateRecord(ctx context.Context, req *weighttracker.UpdateRecordRequest) (*weighttracker.UpdateRecordResponse, error) {
-	log.Printf("UpdateRecord: %v\n", req)
-
-	var recordDatetime time.Time
-	if req.GetRecord().GetWeightedAt() != nil {
-		recordDatetime = req.GetRecord().GetWeightedAt().AsTime()
+// setup loads and validates the configuration from args and installs the
+// default logger.
+func setup(args []string) (*config.Config, *slog.LevelVar) {
+	conf, err := config.LoadConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v\n", err)
 	}
 
-	record := Record{}
-	res := db2.Model(&record).Where("id = ?", req.GetRecord().GetId()).Updates(Record{
-		Weight:     req.GetRecord().GetWeight(),
-		WeightedAt: recordDatetime,
-	})
-	if res.Error != nil {
-		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
-			return nil, status.Errorf(codes.NotFound, fmt.Sprintf("no record found with id = %d", req.GetRecord().GetId()))
-		}
+	if err = conf.Validate(); err != nil {
+		log.Fatalf("invalid configuration:\n%v\n", err)
+	}
 
-		return nil, status.Errorf(codes.Internal, fmt.Sprintf("error while updating record from db: %v", res.Error))
+	logger, level, err := logging.New(os.Stderr, conf.Logging)
+	if err != nil {
+		log.Fatalf("failed to initialize logging: %v\n", err)
 	}
+	slog.SetDefault(logger)
+
+	slog.Info("loaded configuration", "config", conf)
 
-	return &weighttracker.UpdateRecordResponse{
-		Record: dataToRecordPb(record),
-	}, nil
+	return conf, level
 }
 
-func (*server) DeleteRecord(ctx context.Context, req *weighttracker.DeleteRecordRequest) (*weighttracker.DeleteRecordResponse, error) {
-	log.Printf("DeleteRecord: %v\n", req)
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
 
-	recordID := req.GetRecordId()
+// openStore opens the store selected by dbConfig.Driver. SQL databases are
+// migrated first if needed.
+func openStore(dbConfig config.DatabaseConfig) store.Store {
+	if dbConfig.Driver == "file" {
+		slog.Info("opening file store", "path", dbConfig.Path)
 
-	res := db2.Delete(&Record{}, recordID)
-	if res.Error != nil {
-		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
-			return nil, status.Errorf(codes.NotFound, fmt.Sprintf("no record found with id = %d", recordID))
+		st, err := filestore.Open(dbConfig.Path)
+		if err != nil {
+			fatal("failed to open file store", err)
 		}
 
-		return nil, status.Errorf(codes.Internal, fmt.Sprintf("error while deleting record from db: %v", res.Error))
+		return st
 	}
 
-	return &weighttracker.DeleteRecordResponse{}, nil
-}
+	db := openDatabase(dbConfig)
 
-func (*server) ListRecords(req *weighttracker.ListRecordsRequest, stream weighttracker.WeightTracker_ListRecordsServer) error {
-	log.Printf("ListRecords: %v\n", req)
+	ctx := database.WithoutQueryTimeout(context.Background())
 
-	whereQueryStr := "1"
-	whereQueryArgs := make([]interface{}, 0)
-	if req.GetWeightedAtFrom() != nil {
-		whereQueryStr += " AND weighted_at > ?"
-		whereQueryArgs = append(whereQueryArgs, req.GetWeightedAtFrom().AsTime())
-	}
-
-	if req.GetWeightedAtTo() != nil {
-		whereQueryStr += " AND weighted_at < ?"
-		whereQueryArgs = append(whereQueryArgs, req.GetWeightedAtTo().AsTime())
+	migrator, err := migrations.New(ctx, db)
+	if err != nil {
+		fatal("failed to initialize migrations", err)
 	}
 
-	records := []Record{}
-	var res *gorm.DB
-	if len(whereQueryArgs) == 0 {
-		res = db2.Find(&records)
-	} else {
-		res = db2.Where(whereQueryStr, whereQueryArgs...).Find(&records)
+	err = migrator.Check(ctx)
+	if errors.Is(err, migrations.ErrPendingMigrations) && dbConfig.AutoMigrate {
+		var applied int
+		applied, err = migrator.Up(ctx)
+		slog.Info("applied migrations", "count", applied, "version", migrator.Latest())
 	}
-	if res.Error != nil {
-		return status.Errorf(codes.Internal, fmt.Sprintf("error while listing records from db: %v", res.Error))
-	}
-
-	for _, record := range records {
-		stream.Send(&weighttracker.ListRecordsResponse{
-			Record: dataToRecordPb(record),
-		})
+	if err != nil {
+		fatal("database schema is not usable", err)
 	}
 
-	return nil
+	return sqlstore.New(db)
 }
 
-func main() {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+func loadJWKS(jwtConfig config.JWTConfig) *auth.JWKS {
+	slog.Info("loading JWKS", "file", jwtConfig.JWKSFile, "url", jwtConfig.JWKSURL)
 
-	conf := config.LoadConfig()
-
-	fmt.Println(conf)
-
-	connectMySQL(conf.MySQL)
+	jwks, err := auth.LoadJWKS(context.Background(), jwtConfig, time.Now)
+	if err != nil {
+		fatal("failed to load JWKS", err)
+	}
 
-	startServer(conf.Server)
+	return jwks
 }
 
-func connectMySQL(mysqlConfig config.MySQLConfig) {
-	log.Println("connecting to mysql...")
-
-	dbURL := fmt.Sprintf("%v:%v@tcp(%v:%v)/%v?charset=utf8mb4&parseTime=True", mysqlConfig.User, mysqlConfig.Password, mysqlConfig.Host, mysqlConfig.Port, mysqlConfig.Schema)
+func openDatabase(dbConfig config.DatabaseConfig) *gorm.DB {
+	slog.Info("connecting to database", "driver", dbConfig.Driver, "host", dbConfig.Host, "name", dbConfig.Name, "path", dbConfig.Path)
 
-	db, err := gorm.Open(mysql.Open(dbURL), &gorm.Config{})
+	db, err := database.Open(context.Background(), dbConfig)
 	if err != nil {
-		log.Fatalf("failed connect to mysql: %v\n", err)
+		fatal("failed to connect to database", err)
 	}
 
-	db2 = db
-
-	db2.AutoMigrate(&Record{})
+	return db
 }
 
-func startServer(serverConfig config.ServerConfig) {
-	log.Printf("starting server on port %v...\n", serverConfig.Port)
+// startServer serves srv until interrupted. Calls are authenticated by
+// authenticator unless it is nil.
+func startServer(serverConfig config.ServerConfig, logger *slog.Logger, srv *service.Server, authenticator auth.Authenticator, broker *events.Broker, r *reloader) {
+	slog.Info("starting server", "host", serverConfig.Host, "port", serverConfig.Port)
 
-	opts := []grpc.ServerOption{}
+	unary := []grpc.UnaryServerInterceptor{
+		tracing.UnaryServerInterceptor(),
+		logging.UnaryServerInterceptor(logger),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		tracing.StreamServerInterceptor(),
+		logging.StreamServerInterceptor(logger),
+	}
+
+	// rate limits apply after authenticating, so they are kept per principal.
+	// Calls with a missing or invalid token never get that far, they are
+	// limited per IP address before. Passwords can only be guessed through
+	// Login, which is public and limited per IP address as well.
+	if authenticator != nil {
+		unary = append(unary, r.limiter.UnauthenticatedUnaryServerInterceptor(), auth.UnaryServerInterceptor(authenticator, service.PublicMethods...))
+		stream = append(stream, r.limiter.UnauthenticatedStreamServerInterceptor(), auth.StreamServerInterceptor(authenticator, service.PublicMethods...))
+	}
+
+	unary = append(unary, r.limiter.UnaryServerInterceptor(), service.ActorInterceptor(), rpcerror.UnaryServerInterceptor())
+	stream = append(stream, r.limiter.StreamServerInterceptor(), rpcerror.StreamServerInterceptor())
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
 
 	if serverConfig.TLS.Enabled {
-		creds, sslErr := credentials.NewServerTLSFromFile(serverConfig.TLS.CertFile, serverConfig.TLS.KeyFile)
+		cert, sslErr := reload.LoadCertificate(serverConfig.TLS.CertFile, serverConfig.TLS.KeyFile)
 
 		if sslErr != nil {
-			log.Fatalf("failed to load certificates: %v\n", sslErr)
+			fatal("failed to load certificates", sslErr)
 		}
 
-		opts = append(opts, grpc.Creds(creds))
+		r.cert = cert
+
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{GetCertificate: cert.GetCertificate})))
 	}
 
 	lis, err := net.Listen("tcp", fmt.Sprintf("%v:%v", serverConfig.Host, serverConfig.Port))
 	if err != nil {
-		log.Fatalf("failed to listen: %v\n", err)
+		fatal("failed to listen", err)
 	}
 
 	sv := grpc.NewServer(opts...)
 
-	weighttracker.RegisterWeightTrackerServer(sv, &server{})
+	weighttracker.RegisterWeightTrackerServer(sv, srv)
 
 	go func() {
 		if err = sv.Serve(lis); err != nil {
-			log.Fatalf("failed to serve: %v\n", err)
+			fatal("failed to serve", err)
 		}
 	}()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	go reload.Notify(ctx, serverConfig.WatchInterval, r.files, r.reload)
+
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, os.Interrupt)
 
 	<-ch
 
-	log.Println("stopping server...")
+	slog.Info("stopping server")
 
-	sv.Stop()
-	lis.Close()
-}
+	cancel()
 
-func dataToRecordPb(rec Record) *weighttracker.Record {
-	return &weighttracker.Record{
-		Id:         uint64(rec.ID),
-		Weight:     rec.Weight,
-		WeightedAt: timestamppb.New(rec.WeightedAt),
+	// end WatchRecords streams, which would otherwise hold up the graceful stop
+	broker.Close()
+
+	stopped := make(chan struct{})
+	go func() {
+		sv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(serverConfig.ShutdownTimeout):
+		slog.Warn("graceful shutdown timed out, closing remaining connections")
+		sv.Stop()
 	}
+
+	lis.Close()
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/database"
+	"github.com/0gener/go-weight-tracker/server/migrations"
+)
+
+const migrateUsage = `usage: server migrate [up | down [steps] | status] [flags]
+
+  up       apply every pending migration (default)
+  down     revert the last steps applied migrations (default 1)
+  status   list migrations and whether they are applied`
+
+// runMigrate implements the migrate subcommand. Configuration flags come
+// after the action, e.g. "server migrate down 2 -config server.yaml".
+func runMigrate(args []string) {
+	action := "up"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	steps := 1
+	if action == "down" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			os.Exit(2)
+		}
+
+		steps, args = n, args[1:]
+	}
+
+	if action != "up" && action != "down" && action != "status" {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	conf, _ := setup(args)
+
+	if conf.Database.Driver == "file" {
+		fmt.Fprintln(os.Stderr, "the file store has no schema to migrate")
+		os.Exit(1)
+	}
+
+	ctx := database.WithoutQueryTimeout(context.Background())
+
+	migrator, err := migrations.New(ctx, openDatabase(conf.Database))
+	if err != nil {
+		fatal("failed to initialize migrations", err)
+	}
+
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			fatal("failed to apply migrations", err)
+		}
+
+		fmt.Printf("applied %d migrations\n", applied)
+	case "down":
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			fatal("failed to revert migrations", err)
+		}
+
+		fmt.Printf("reverted %d migrations\n", reverted)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fatal("failed to read migration status", err)
+		}
+
+		printMigrationStatus(statuses)
+	}
+}
+
+func printMigrationStatus(statuses []migrations.Status) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statuses {
+		name := s.Name
+		if s.Unknown {
+			name = "(unknown to this binary)"
+		}
+
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "%d\t%v\t%v\n", s.Version, name, appliedAt)
+	}
+}
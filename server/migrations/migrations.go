@@ -0,0 +1,356 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var scripts embed.FS
+
+// ErrSchemaTooNew is returned when the database has migrations applied that
+// this binary does not know about.
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary supports")
+
+// ErrPendingMigrations is returned when the database is missing migrations
+// this binary needs.
+var ErrPendingMigrations = errors.New("database schema has pending migrations")
+
+// Migration is a single versioned schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes a migration and whether it has been applied. Unknown is
+// set for versions found in the database but not embedded in the binary.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	Unknown   bool
+}
+
+type schemaVersion struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// Migrator applies and reverts the migrations embedded in the binary.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New creates a migrator for db, creating the schema_version table if it
+// does not exist yet.
+func New(ctx context.Context, db *gorm.DB) (*Migrator, error) {
+	migrations, err := load(scripts, db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.WithContext(ctx).Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS schema_version (version BIGINT NOT NULL PRIMARY KEY, applied_at %v NOT NULL)",
+		timestampType(db.Dialector.Name()),
+	)).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Latest returns the version of the newest embedded migration.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Check returns ErrSchemaTooNew if the database has migrations applied that
+// are unknown to this binary and ErrPendingMigrations if some embedded
+// migrations have not been applied.
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, s := range statuses {
+		if s.Unknown {
+			return fmt.Errorf("%w: version %d is applied, latest known is %d", ErrSchemaTooNew, s.Version, m.Latest())
+		}
+
+		if s.AppliedAt == nil {
+			pending++
+		}
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("%w: %d not applied", ErrPendingMigrations, pending)
+	}
+
+	return nil
+}
+
+// Status lists every embedded migration along with the unknown versions
+// found in the database, ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if v, ok := applied[mig.Version]; ok {
+			s.AppliedAt = &v.AppliedAt
+			delete(applied, mig.Version)
+		}
+
+		statuses = append(statuses, s)
+	}
+
+	for _, v := range applied {
+		appliedAt := v.AppliedAt
+		statuses = append(statuses, Status{Version: v.Version, AppliedAt: &appliedAt, Unknown: true})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+
+	return statuses, nil
+}
+
+// Up applies every pending migration in order and returns how many were
+// applied. It refuses to run against a schema newer than the binary.
+//
+// Migrations are only atomic on Postgres and SQLite. MySQL commits every DDL
+// statement on its own, so a migration that fails there keeps the statements
+// before the failure. Running Up again skips the statements that fail because
+// their change is already there, see alreadyApplied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	if err := m.Check(ctx); err != nil && !errors.Is(err, ErrPendingMigrations) {
+		return 0, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+
+		err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, mig.Up); err != nil {
+				return err
+			}
+
+			return tx.Create(&schemaVersion{Version: mig.Version, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("failed to apply migration %d_%v: %w", mig.Version, mig.Name, err)
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// how many were reverted. As with Up, migrations are only atomic on Postgres
+// and SQLite.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if err := m.Check(ctx); err != nil && !errors.Is(err, ErrPendingMigrations) {
+		return 0, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+
+		err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, mig.Down); err != nil {
+				return err
+			}
+
+			return tx.Delete(&schemaVersion{Version: mig.Version}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("failed to revert migration %d_%v: %w", mig.Version, mig.Name, err)
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]schemaVersion, error) {
+	var versions []schemaVersion
+	if err := m.db.WithContext(ctx).Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_version: %w", err)
+	}
+
+	applied := make(map[int]schemaVersion, len(versions))
+	for _, v := range versions {
+		applied[v.Version] = v
+	}
+
+	return applied, nil
+}
+
+func timestampType(dialect string) string {
+	if dialect == "postgres" {
+		return "TIMESTAMPTZ"
+	}
+
+	return "DATETIME"
+}
+
+// execScript runs every statement in script. Statements are separated by a
+// semicolon at the end of a line.
+func execScript(tx *gorm.DB, script string) error {
+	for _, stmt := range strings.Split(script, ";\n") {
+		stmt = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+		if stmt == "" || isComment(stmt) {
+			continue
+		}
+
+		if err := tx.Exec(stmt).Error; err != nil && !alreadyApplied(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// alreadyApplied reports whether err is a MySQL error for a DDL statement
+// whose change is already in the schema, e.g. adding a column that exists.
+// MySQL commits DDL statements one by one, so these are left behind by a
+// migration that failed halfway and must not stop it from being run again.
+func alreadyApplied(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+
+	switch mysqlErr.Number {
+	case 1050, // table already exists
+		1051, // unknown table
+		1060, // duplicate column name
+		1061, // duplicate key name
+		1091: // can't drop column or key, it does not exist
+		return true
+	}
+
+	return false
+}
+
+func isComment(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// load reads the migrations for dialect from fsys. Scripts are named
+// <version>_<name>.up.sql and <version>_<name>.down.sql.
+func load(fsys fs.FS, dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %v: %w", dialect, err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		i := strings.Index(base, "_")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid migration file name %v", name)
+		}
+
+		version, err := strconv.Atoi(base[:i])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %v", name)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dialect, name))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: base[i+1:]}
+			byVersion[version] = mig
+		}
+
+		if direction == "up" {
+			mig.Up = string(content)
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%v must have both up and down scripts", mig.Version, mig.Name)
+		}
+
+		migrations = append(migrations, *mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
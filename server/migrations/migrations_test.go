@@ -0,0 +1,194 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/config"
+	"github.com/0gener/go-weight-tracker/server/database"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dbConfig := config.Default().Database
+	dbConfig.Driver = "sqlite"
+	dbConfig.Path = filepath.Join(t.TempDir(), "wt.db")
+
+	db, err := database.Open(context.Background(), dbConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return db
+}
+
+// pending returns the versions Status reports as not applied.
+func pending(t *testing.T, m *Migrator) []int {
+	t.Helper()
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var versions []int
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			versions = append(versions, s.Version)
+		}
+	}
+
+	return versions
+}
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+
+	m, err := New(ctx, openDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	latest := m.Latest()
+	if got := len(pending(t, m)); got != latest {
+		t.Fatalf("%d migrations pending on an empty database, want %d", got, latest)
+	}
+
+	if err = m.Check(ctx); !errors.Is(err, ErrPendingMigrations) {
+		t.Errorf("Check returned %v on an empty database, want ErrPendingMigrations", err)
+	}
+
+	if n, err := m.Up(ctx); err != nil || n != latest {
+		t.Fatalf("Up = %d, %v, want %d", n, err, latest)
+	}
+
+	if err = m.Check(ctx); err != nil {
+		t.Errorf("Check returned %v after Up", err)
+	}
+
+	if n, err := m.Up(ctx); err != nil || n != 0 {
+		t.Errorf("Up = %d, %v once up to date, want 0", n, err)
+	}
+
+	if n, err := m.Down(ctx, 2); err != nil || n != 2 {
+		t.Fatalf("Down(2) = %d, %v, want 2", n, err)
+	}
+
+	if got := pending(t, m); len(got) != 2 || got[0] != latest-1 || got[1] != latest {
+		t.Errorf("versions %v pending after Down(2), want the last two", got)
+	}
+
+	// every down script runs against the schema its up script left
+	if n, err := m.Down(ctx, latest); err != nil || n != latest-2 {
+		t.Fatalf("Down = %d, %v, want %d", n, err, latest-2)
+	}
+
+	for _, table := range []string{"records", "audit_events", "accounts"} {
+		if m.db.Migrator().HasTable(table) {
+			t.Errorf("table %v left after reverting everything", table)
+		}
+	}
+
+	if n, err := m.Up(ctx); err != nil || n != latest {
+		t.Errorf("Up = %d, %v after reverting everything, want %d", n, err, latest)
+	}
+}
+
+func TestSchemaTooNew(t *testing.T) {
+	ctx := context.Background()
+
+	m, err := New(ctx, openDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// applied by a newer binary
+	newer := schemaVersion{Version: m.Latest() + 1, AppliedAt: time.Now().UTC()}
+	if err = m.db.Create(&newer).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err = m.Check(ctx); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Check returned %v, want ErrSchemaTooNew", err)
+	}
+
+	if _, err = m.Up(ctx); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Up returned %v, want ErrSchemaTooNew", err)
+	}
+
+	if _, err = m.Down(ctx, 1); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Down returned %v, want ErrSchemaTooNew", err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if last := statuses[len(statuses)-1]; last.Version != newer.Version || !last.Unknown || last.AppliedAt == nil {
+		t.Errorf("got status %+v for the newer version, want it applied and unknown", last)
+	}
+}
+
+func TestFailedMigrationIsRolledBack(t *testing.T) {
+	ctx := context.Background()
+
+	m, err := New(ctx, openDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.migrations = []Migration{
+		{Version: 1, Name: "create_a", Up: "CREATE TABLE a (id INT);\n", Down: "DROP TABLE a;\n"},
+		{Version: 2, Name: "create_b", Up: "CREATE TABLE b (id INT);\nINSERT INTO missing VALUES (1);\n", Down: "DROP TABLE b;\n"},
+	}
+
+	if n, err := m.Up(ctx); err == nil || n != 1 {
+		t.Fatalf("Up = %d, %v, want 1 and an error", n, err)
+	}
+
+	if m.db.Migrator().HasTable("b") {
+		t.Error("the failed migration left table b behind")
+	}
+
+	if got := pending(t, m); len(got) != 1 || got[0] != 2 {
+		t.Errorf("versions %v pending, want only the failed one", got)
+	}
+}
+
+func TestAlreadyApplied(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&mysql.MySQLError{Number: 1060, Message: "Duplicate column name 'owner'"}, true},
+		{fmt.Errorf("exec: %w", &mysql.MySQLError{Number: 1061, Message: "Duplicate key name 'idx_records_owner_client_id'"}), true},
+		{&mysql.MySQLError{Number: 1091, Message: "Can't DROP 'idx_records_client_id'; check that column/key exists"}, true},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"}, false},
+		{&mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}, false},
+		{errors.New("duplicate column name: owner"), false},
+	}
+
+	for _, tt := range tests {
+		if got := alreadyApplied(tt.err); got != tt.want {
+			t.Errorf("alreadyApplied(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+-- Matches the table previously created by gorm's AutoMigrate, so existing
+-- databases adopt it without changes.
+CREATE TABLE IF NOT EXISTS records (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    deleted_at DATETIME(3) NULL,
+    weight DECIMAL(4,2) NOT NULL,
+    weighted_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (id),
+    INDEX idx_records_deleted_at (deleted_at)
+);
@@ -0,0 +1 @@
+ALTER TABLE records MODIFY weight DECIMAL(4,2) NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX idx_records_updated_at ON records;
+DROP INDEX idx_records_client_id ON records;
+ALTER TABLE records DROP COLUMN client_id;
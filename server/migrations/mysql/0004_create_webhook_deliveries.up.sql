@@ -0,0 +1,16 @@
+CREATE TABLE webhook_deliveries (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    webhook VARCHAR(255) NOT NULL,
+    event VARCHAR(64) NOT NULL,
+    record_id BIGINT UNSIGNED NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME(3) NOT NULL,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    last_status_code INT NOT NULL DEFAULT 0,
+    created_at DATETIME(3) NOT NULL,
+    updated_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (id),
+    INDEX idx_webhook_deliveries_due (status, next_attempt_at)
+);
@@ -0,0 +1,15 @@
+-- Append-only, the server never updates or deletes rows.
+CREATE TABLE audit_events (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    action VARCHAR(16) NOT NULL,
+    record_id BIGINT UNSIGNED NOT NULL,
+    before_value TEXT NULL,
+    after_value TEXT NULL,
+    principal VARCHAR(255) NOT NULL DEFAULT '',
+    method VARCHAR(255) NOT NULL DEFAULT '',
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    created_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (id),
+    INDEX idx_audit_events_record_id (record_id),
+    INDEX idx_audit_events_created_at (created_at)
+);
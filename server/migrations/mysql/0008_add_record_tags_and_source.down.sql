@@ -0,0 +1,3 @@
+DROP TABLE record_tags;
+DROP INDEX idx_records_source ON records;
+ALTER TABLE records DROP COLUMN source;
@@ -0,0 +1,9 @@
+ALTER TABLE records ADD COLUMN source VARCHAR(64) NOT NULL DEFAULT '';
+CREATE INDEX idx_records_source ON records (source);
+
+CREATE TABLE record_tags (
+    record_id BIGINT UNSIGNED NOT NULL,
+    tag VARCHAR(32) NOT NULL,
+    PRIMARY KEY (record_id, tag),
+    INDEX idx_record_tags_tag (tag)
+);
@@ -0,0 +1,7 @@
+-- Records belong to the principal that created them. Records created before
+-- belong to no one, which is the principal of unauthenticated calls. Client
+-- ids only need to be unique per owner.
+ALTER TABLE records ADD COLUMN owner VARCHAR(255) NOT NULL DEFAULT '';
+DROP INDEX idx_records_client_id ON records;
+CREATE UNIQUE INDEX idx_records_owner_client_id ON records (owner, client_id);
+CREATE INDEX idx_records_owner_weighted_at ON records (owner, weighted_at);
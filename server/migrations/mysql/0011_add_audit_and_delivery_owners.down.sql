@@ -0,0 +1,4 @@
+DROP INDEX idx_webhook_deliveries_owner ON webhook_deliveries;
+DROP INDEX idx_audit_events_owner ON audit_events;
+ALTER TABLE webhook_deliveries DROP COLUMN owner;
+ALTER TABLE audit_events DROP COLUMN owner;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS records (
+    id BIGSERIAL NOT NULL,
+    created_at TIMESTAMPTZ NULL,
+    updated_at TIMESTAMPTZ NULL,
+    deleted_at TIMESTAMPTZ NULL,
+    weight DECIMAL(4,2) NOT NULL,
+    weighted_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_records_deleted_at ON records (deleted_at);
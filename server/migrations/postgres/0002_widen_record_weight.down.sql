@@ -0,0 +1 @@
+ALTER TABLE records ALTER COLUMN weight TYPE DECIMAL(4,2);
@@ -0,0 +1,2 @@
+-- DECIMAL(4,2) cannot store weights of 100 or more.
+ALTER TABLE records ALTER COLUMN weight TYPE DECIMAL(5,2);
@@ -0,0 +1,17 @@
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL NOT NULL,
+    webhook VARCHAR(255) NOT NULL,
+    event VARCHAR(64) NOT NULL,
+    record_id BIGINT NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    last_status_code INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
@@ -0,0 +1,2 @@
+DROP TABLE record_revisions;
+ALTER TABLE records DROP COLUMN revision;
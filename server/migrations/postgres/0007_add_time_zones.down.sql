@@ -0,0 +1,4 @@
+DROP TABLE user_settings;
+ALTER TABLE record_revisions DROP COLUMN time_zone, DROP COLUMN utc_offset;
+DROP INDEX idx_records_local_date;
+ALTER TABLE records DROP COLUMN time_zone, DROP COLUMN utc_offset, DROP COLUMN local_date;
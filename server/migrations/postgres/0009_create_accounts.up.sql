@@ -0,0 +1,24 @@
+CREATE TABLE accounts (
+    id BIGSERIAL NOT NULL,
+    username VARCHAR(64) NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX idx_accounts_username ON accounts (username);
+
+-- Only a hash of each token is stored.
+CREATE TABLE refresh_tokens (
+    id BIGSERIAL NOT NULL,
+    account_id BIGINT NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX idx_refresh_tokens_account_id ON refresh_tokens (account_id);
+CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens (expires_at);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS records (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NULL,
+    updated_at DATETIME NULL,
+    deleted_at DATETIME NULL,
+    weight DECIMAL(4,2) NOT NULL,
+    weighted_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_records_deleted_at ON records (deleted_at);
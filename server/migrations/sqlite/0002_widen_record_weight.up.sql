@@ -0,0 +1 @@
+-- SQLite does not enforce DECIMAL precision, nothing to change.
@@ -0,0 +1,3 @@
+DROP INDEX idx_records_updated_at;
+DROP INDEX idx_records_client_id;
+ALTER TABLE records DROP COLUMN client_id;
@@ -0,0 +1,5 @@
+-- client_id lets offline clients retry a sync without creating duplicates,
+-- updated_at is scanned by the change feed.
+ALTER TABLE records ADD COLUMN client_id VARCHAR(64) NULL;
+CREATE UNIQUE INDEX idx_records_client_id ON records (client_id);
+CREATE INDEX idx_records_updated_at ON records (updated_at);
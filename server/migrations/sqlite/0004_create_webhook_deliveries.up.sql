@@ -0,0 +1,16 @@
+CREATE TABLE webhook_deliveries (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    webhook VARCHAR(255) NOT NULL,
+    event VARCHAR(64) NOT NULL,
+    record_id INTEGER NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    last_status_code INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
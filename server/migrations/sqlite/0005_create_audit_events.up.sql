@@ -0,0 +1,15 @@
+-- Append-only, the server never updates or deletes rows.
+CREATE TABLE audit_events (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    action VARCHAR(16) NOT NULL,
+    record_id INTEGER NOT NULL,
+    before_value TEXT NULL,
+    after_value TEXT NULL,
+    principal VARCHAR(255) NOT NULL DEFAULT '',
+    method VARCHAR(255) NOT NULL DEFAULT '',
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_audit_events_record_id ON audit_events (record_id);
+CREATE INDEX idx_audit_events_created_at ON audit_events (created_at);
@@ -0,0 +1,15 @@
+-- Every version of a record's values, the current one included. revision is
+-- bumped whenever weight or weighted_at change.
+ALTER TABLE records ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE record_revisions (
+    record_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    weight DECIMAL(5,2) NOT NULL,
+    weighted_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (record_id, revision)
+);
+
+INSERT INTO record_revisions (record_id, revision, weight, weighted_at, created_at)
+SELECT id, 1, weight, weighted_at, updated_at FROM records;
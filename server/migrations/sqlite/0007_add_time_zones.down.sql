@@ -0,0 +1,7 @@
+DROP TABLE user_settings;
+ALTER TABLE record_revisions DROP COLUMN utc_offset;
+ALTER TABLE record_revisions DROP COLUMN time_zone;
+DROP INDEX idx_records_local_date;
+ALTER TABLE records DROP COLUMN local_date;
+ALTER TABLE records DROP COLUMN utc_offset;
+ALTER TABLE records DROP COLUMN time_zone;
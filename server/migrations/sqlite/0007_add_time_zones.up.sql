@@ -0,0 +1,17 @@
+-- The zone a record was taken in decides which day it belongs to. local_date
+-- is derived from weighted_at and utc_offset so it can be queried. Existing
+-- records have no known zone and stay in UTC.
+ALTER TABLE records ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE records ADD COLUMN utc_offset INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE records ADD COLUMN local_date CHAR(10) NOT NULL DEFAULT '';
+UPDATE records SET local_date = strftime('%Y-%m-%d', weighted_at);
+CREATE INDEX idx_records_local_date ON records (local_date);
+
+ALTER TABLE record_revisions ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE record_revisions ADD COLUMN utc_offset INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE user_settings (
+    principal VARCHAR(255) NOT NULL PRIMARY KEY,
+    time_zone VARCHAR(64) NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL
+);
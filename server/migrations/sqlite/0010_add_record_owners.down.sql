@@ -0,0 +1,4 @@
+DROP INDEX idx_records_owner_weighted_at;
+DROP INDEX idx_records_owner_client_id;
+CREATE UNIQUE INDEX idx_records_client_id ON records (client_id);
+ALTER TABLE records DROP COLUMN owner;
@@ -0,0 +1,6 @@
+-- Audit events and webhook deliveries belong to the owner of their record.
+-- Records created before 0010 belong to no one, and so do their events.
+ALTER TABLE audit_events ADD COLUMN owner VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE webhook_deliveries ADD COLUMN owner VARCHAR(255) NOT NULL DEFAULT '';
+CREATE INDEX idx_audit_events_owner ON audit_events (owner);
+CREATE INDEX idx_webhook_deliveries_owner ON webhook_deliveries (owner);
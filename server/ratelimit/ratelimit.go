@@ -0,0 +1,254 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/auth"
+	"github.com/0gener/go-weight-tracker/server/config"
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// sweepInterval is how often buckets that have refilled completely are
+// dropped, since a new bucket behaves the same.
+const sweepInterval = time.Minute
+
+type bucketKey struct {
+	client string
+	method string
+}
+
+// Limiter applies a token bucket per client and method, and caps the number
+// of concurrent streaming calls per client. Its interceptors must run after
+// the auth interceptor, if any, to tell authenticated clients apart, and its
+// Unauthenticated interceptors before it, to limit the calls it rejects.
+type Limiter struct {
+	mu        sync.Mutex
+	conf      config.RateLimitConfig
+	buckets   map[bucketKey]*rate.Limiter
+	streams   map[string]int
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New creates a limiter enforcing rateLimitConfig.
+func New(rateLimitConfig config.RateLimitConfig, now func() time.Time) *Limiter {
+	return &Limiter{
+		conf:      rateLimitConfig,
+		buckets:   map[bucketKey]*rate.Limiter{},
+		streams:   map[string]int{},
+		lastSweep: now(),
+		now:       now,
+	}
+}
+
+// Update replaces the limits. Buckets start over full with the new limits,
+// calls in progress are not affected.
+func (l *Limiter) Update(rateLimitConfig config.RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.conf = rateLimitConfig
+	l.buckets = map[bucketKey]*rate.Limiter{}
+}
+
+// allow takes a token from the bucket of client for method, or only checks
+// that there is one unless take is set. If there is none, it returns how
+// long to wait before retrying.
+func (l *Limiter) allow(client, method string, take bool) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.conf.Enabled {
+		return true, 0
+	}
+
+	now := l.now()
+	l.sweep(now)
+
+	key := bucketKey{client, method}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		limit := l.conf.Limit(method)
+		b = rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+		l.buckets[key] = b
+	}
+
+	r := b.ReserveN(now, 1)
+	if !r.OK() {
+		return false, 0
+	}
+
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+
+	if !take {
+		r.CancelAt(now)
+	}
+
+	return true, 0
+}
+
+// acquireStream reserves a streaming slot for client. The returned function
+// releases it.
+func (l *Limiter) acquireStream(client string) (func(), bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conf.Enabled && l.conf.MaxStreams > 0 && l.streams[client] >= l.conf.MaxStreams {
+		return nil, false
+	}
+
+	l.streams[client]++
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.streams[client]--; l.streams[client] <= 0 {
+			delete(l.streams, client)
+		}
+	}, true
+}
+
+// sweep drops full buckets. Must be called with the lock held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if b.TokensAt(now) >= float64(b.Burst()) {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
+
+// UnaryServerInterceptor rejects unary calls over the limit with
+// RESOURCE_EXHAUSTED.
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if ok, delay := l.allow(clientKey(ctx), path.Base(info.FullMethod), true); !ok {
+			return nil, exhausted("rate limit exceeded", delay)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streaming calls over the limit, or over
+// the number of concurrent streams, with RESOURCE_EXHAUSTED.
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		client := clientKey(ss.Context())
+
+		if ok, delay := l.allow(client, path.Base(info.FullMethod), true); !ok {
+			return exhausted("rate limit exceeded", delay)
+		}
+
+		release, ok := l.acquireStream(client)
+		if !ok {
+			return exhausted("too many concurrent streams", 0)
+		}
+		defer release()
+
+		return handler(srv, ss)
+	}
+}
+
+// UnauthenticatedUnaryServerInterceptor limits the unary calls that fail
+// with UNAUTHENTICATED per IP address. It must run before the auth
+// interceptor, which rejects them before UnaryServerInterceptor sees them.
+// Calls that authenticate are left to UnaryServerInterceptor, so they are
+// not limited by the address of a NAT or proxy they share with others.
+func (l *Limiter) UnauthenticatedUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		client, method := ipKey(ctx), path.Base(info.FullMethod)
+
+		if ok, delay := l.allow(client, method, false); !ok {
+			return nil, exhausted("rate limit exceeded", delay)
+		}
+
+		resp, err := handler(ctx, req)
+		if status.Code(err) == codes.Unauthenticated {
+			l.allow(client, method, true)
+		}
+
+		return resp, err
+	}
+}
+
+// UnauthenticatedStreamServerInterceptor limits the streaming calls that
+// fail with UNAUTHENTICATED per IP address, see
+// UnauthenticatedUnaryServerInterceptor.
+func (l *Limiter) UnauthenticatedStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		client, method := ipKey(ss.Context()), path.Base(info.FullMethod)
+
+		if ok, delay := l.allow(client, method, false); !ok {
+			return exhausted("rate limit exceeded", delay)
+		}
+
+		err := handler(srv, ss)
+		if status.Code(err) == codes.Unauthenticated {
+			l.allow(client, method, true)
+		}
+
+		return err
+	}
+}
+
+// clientKey identifies the caller by its principal, so its limits follow it
+// across hosts and are not shared with others behind the same NAT or proxy,
+// or by its IP address if the call is not authenticated.
+func clientKey(ctx context.Context) string {
+	if principal := auth.PrincipalFromContext(ctx); principal != "" {
+		return "principal:" + principal
+	}
+
+	return ipKey(ctx)
+}
+
+// ipKey identifies the caller by its IP address.
+func ipKey(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "ip:"
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return "ip:" + p.Addr.String()
+	}
+
+	return "ip:" + host
+}
+
+// exhausted returns a RESOURCE_EXHAUSTED status, with a google.rpc.RetryInfo
+// detail if delay is known.
+func exhausted(msg string, delay time.Duration) error {
+	st := status.New(codes.ResourceExhausted, msg)
+	if delay <= 0 {
+		return st.Err()
+	}
+
+	if withDetails, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)}); err == nil {
+		st = withDetails
+	}
+
+	return st.Err()
+}
@@ -0,0 +1,282 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/auth"
+	"github.com/0gener/go-weight-tracker/server/config"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// clock is a settable time source for limiters.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)}
+}
+
+// from returns the context of a call from ip, authenticated as principal
+// unless it is empty.
+func from(ip, principal string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}})
+	if principal != "" {
+		ctx = auth.WithPrincipal(ctx, principal)
+	}
+
+	return ctx
+}
+
+func info(method string) *grpc.UnaryServerInfo {
+	return &grpc.UnaryServerInfo{FullMethod: "/weighttracker.WeightTracker/" + method}
+}
+
+func ok(ctx context.Context, req interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+// allowed makes a unary call to method from ctx and reports whether it got
+// past l.
+func allowed(t *testing.T, l *Limiter, ctx context.Context, method string) bool {
+	t.Helper()
+
+	_, err := l.UnaryServerInterceptor()(ctx, nil, info(method), ok)
+	switch status.Code(err) {
+	case codes.OK:
+		return true
+	case codes.ResourceExhausted:
+		return false
+	}
+
+	t.Fatalf("%v failed: %v", method, err)
+
+	return false
+}
+
+// serverStream is a grpc.ServerStream of a call made from ctx.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestClientKey(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	l := New(config.RateLimitConfig{Enabled: true, Rate: 1, Burst: 1}, func() time.Time { return now })
+	call := l.UnaryServerInterceptor()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		allowed bool
+	}{
+		{"anonymous", from("10.0.0.1", ""), true},
+		{"anonymous from the same address", from("10.0.0.1", ""), false},
+		{"principal behind the same address", from("10.0.0.1", "ana"), true},
+		{"another principal behind the same address", from("10.0.0.1", "bob"), true},
+		{"principal from another address", from("10.0.0.2", "ana"), false},
+	}
+
+	for _, tt := range tests {
+		_, err := call(tt.ctx, nil, info("ReadRecord"), ok)
+		if allowed := status.Code(err) != codes.ResourceExhausted; allowed != tt.allowed {
+			t.Errorf("%v: got allowed = %v, want %v", tt.name, allowed, tt.allowed)
+		}
+	}
+}
+
+func TestRetryInfo(t *testing.T) {
+	clk := newClock()
+	l := New(config.RateLimitConfig{Enabled: true, Rate: 2, Burst: 1}, clk.Now)
+	ctx := from("10.0.0.1", "")
+
+	if !allowed(t, l, ctx, "ReadRecord") {
+		t.Fatal("first call was rejected")
+	}
+
+	_, err := l.UnaryServerInterceptor()(ctx, nil, info("ReadRecord"), ok)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("got %v over the limit, want RESOURCE_EXHAUSTED", err)
+	}
+
+	var delay time.Duration
+	for _, detail := range status.Convert(err).Details() {
+		if retryInfo, ok := detail.(*errdetails.RetryInfo); ok {
+			delay = retryInfo.GetRetryDelay().AsDuration()
+		}
+	}
+
+	if delay != 500*time.Millisecond {
+		t.Errorf("got a retry delay of %v, want 500ms", delay)
+	}
+
+	clk.now = clk.now.Add(delay)
+	if !allowed(t, l, ctx, "ReadRecord") {
+		t.Error("call after the retry delay was rejected")
+	}
+}
+
+func TestMethodLimits(t *testing.T) {
+	l := New(config.RateLimitConfig{
+		Enabled: true,
+		Rate:    1,
+		Burst:   1,
+		Methods: map[string]config.RateLimit{"CreateRecord": {Rate: 1, Burst: 2}},
+	}, newClock().Now)
+	ctx := from("10.0.0.1", "ana")
+
+	// every method has a bucket of its own
+	for i, want := range []bool{true, true, false} {
+		if got := allowed(t, l, ctx, "CreateRecord"); got != want {
+			t.Errorf("CreateRecord call %d allowed = %v, want %v", i+1, got, want)
+		}
+	}
+
+	for i, want := range []bool{true, false} {
+		if got := allowed(t, l, ctx, "ReadRecord"); got != want {
+			t.Errorf("ReadRecord call %d allowed = %v, want %v", i+1, got, want)
+		}
+	}
+}
+
+func TestMaxStreams(t *testing.T) {
+	l := New(config.RateLimitConfig{Enabled: true, Rate: 100, Burst: 100, MaxStreams: 1}, newClock().Now)
+	call := l.StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/weighttracker.WeightTracker/ListRecords", IsServerStream: true}
+	ss := serverStream{ctx: from("10.0.0.1", "ana")}
+
+	started, done := make(chan struct{}), make(chan struct{})
+	errs := make(chan error, 1)
+	go func() {
+		errs <- call(nil, ss, info, func(srv interface{}, ss grpc.ServerStream) error {
+			close(started)
+			<-done
+			return nil
+		})
+	}()
+	<-started
+
+	err := call(nil, ss, info, func(srv interface{}, ss grpc.ServerStream) error { return nil })
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("got %v for a second concurrent stream, want RESOURCE_EXHAUSTED", err)
+	}
+
+	// another client has streams of its own
+	other := serverStream{ctx: from("10.0.0.1", "bob")}
+	if err = call(nil, other, info, func(srv interface{}, ss grpc.ServerStream) error { return nil }); err != nil {
+		t.Errorf("got %v for a stream of another client", err)
+	}
+
+	close(done)
+	if err = <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	// the slot is released when the stream ends
+	if err = call(nil, ss, info, func(srv interface{}, ss grpc.ServerStream) error { return nil }); err != nil {
+		t.Errorf("got %v once the first stream ended", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	l := New(config.RateLimitConfig{Enabled: true, Rate: 1, Burst: 1}, newClock().Now)
+	ctx := from("10.0.0.1", "")
+
+	if !allowed(t, l, ctx, "ReadRecord") || allowed(t, l, ctx, "ReadRecord") {
+		t.Fatal("a burst of 1 did not allow exactly one call")
+	}
+
+	// buckets start over full with the new limits
+	l.Update(config.RateLimitConfig{Enabled: true, Rate: 1, Burst: 2})
+
+	for i, want := range []bool{true, true, false} {
+		if got := allowed(t, l, ctx, "ReadRecord"); got != want {
+			t.Errorf("call %d after Update allowed = %v, want %v", i+1, got, want)
+		}
+	}
+
+	l.Update(config.RateLimitConfig{Enabled: false, Rate: 1, Burst: 1})
+
+	if !allowed(t, l, ctx, "ReadRecord") || !allowed(t, l, ctx, "ReadRecord") {
+		t.Error("calls were rejected once rate limiting was disabled")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	clk := newClock()
+	l := New(config.RateLimitConfig{
+		Enabled: true,
+		Rate:    1,
+		Burst:   1,
+		Methods: map[string]config.RateLimit{"CreateRecord": {Rate: 0.001, Burst: 1}},
+	}, clk.Now)
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		allowed(t, l, from(ip, ""), "ReadRecord")
+	}
+
+	allowed(t, l, from("10.0.0.1", ""), "CreateRecord")
+
+	// the next call after the sweep interval drops the buckets that refilled
+	clk.now = clk.now.Add(sweepInterval)
+	allowed(t, l, from("10.0.0.4", ""), "ReadRecord")
+
+	want := map[bucketKey]bool{
+		{"ip:10.0.0.1", "CreateRecord"}: true,
+		{"ip:10.0.0.4", "ReadRecord"}:   true,
+	}
+
+	if len(l.buckets) != len(want) {
+		t.Errorf("%d buckets left after sweeping, want %d", len(l.buckets), len(want))
+	}
+
+	for key := range l.buckets {
+		if !want[key] {
+			t.Errorf("bucket %v was not swept", key)
+		}
+	}
+}
+
+func TestUnauthenticated(t *testing.T) {
+	l := New(config.RateLimitConfig{Enabled: true, Rate: 1, Burst: 1}, newClock().Now)
+	call := l.UnauthenticatedUnaryServerInterceptor()
+
+	rejected := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	// calls that authenticate are not counted here
+	for i := 0; i < 3; i++ {
+		if _, err := call(from("10.0.0.1", ""), nil, info("ReadRecord"), ok); err != nil {
+			t.Fatalf("authenticated call %d failed: %v", i+1, err)
+		}
+	}
+
+	if _, err := call(from("10.0.0.1", ""), nil, info("ReadRecord"), rejected); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("got %v for a call with an invalid token, want UNAUTHENTICATED", err)
+	}
+
+	if _, err := call(from("10.0.0.1", ""), nil, info("ReadRecord"), rejected); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("got %v for another call with an invalid token, want RESOURCE_EXHAUSTED", err)
+	}
+
+	if _, err := call(from("10.0.0.2", ""), nil, info("ReadRecord"), rejected); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("got %v for a call with an invalid token from another address, want UNAUTHENTICATED", err)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+
+	"github.com/0gener/go-weight-tracker/server/auth"
+	"github.com/0gener/go-weight-tracker/server/config"
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"github.com/0gener/go-weight-tracker/server/ratelimit"
+	"github.com/0gener/go-weight-tracker/server/reload"
+	"github.com/0gener/go-weight-tracker/server/webhook"
+)
+
+// reloader re-reads the configuration and applies the settings that can be
+// changed while the server is running. Everything else is only picked up on
+// restart.
+type reloader struct {
+	args    []string
+	conf    *config.Config
+	level   *slog.LevelVar
+	cert    *reload.Certificate // nil if tls is disabled
+	limiter *ratelimit.Limiter
+
+	webhooks *webhook.Dispatcher
+	jwks     *auth.JWKS // nil unless auth mode is jwt
+}
+
+// files returns the files whose changes should trigger a reload.
+func (r *reloader) files() []string {
+	files := []string{r.conf.File}
+	if r.cert != nil {
+		files = append(files, r.cert.Files()...)
+	}
+
+	if r.jwks != nil && r.jwks.File() != "" {
+		files = append(files, r.jwks.File())
+	}
+
+	return files
+}
+
+func (r *reloader) reload() {
+	slog.Info("reloading configuration")
+
+	conf, err := config.LoadConfig(r.args)
+	if err == nil {
+		err = conf.Validate()
+	}
+	if err != nil {
+		slog.Error("failed to reload configuration, keeping the current one", "error", err)
+		return
+	}
+
+	if err = logging.SetLevel(r.level, conf.Logging.Level); err != nil {
+		slog.Error("failed to reload log level", "error", err)
+	}
+
+	if r.cert != nil {
+		if err = r.cert.Reload(conf.Server.TLS.CertFile, conf.Server.TLS.KeyFile); err != nil {
+			slog.Error("failed to reload certificates, keeping the current ones", "error", err)
+		}
+	}
+
+	// the keys are reloaded from where they were loaded on startup, a
+	// different source requires a restart
+	if r.jwks != nil {
+		if err = r.jwks.Refresh(context.Background()); err != nil {
+			slog.Error("failed to reload JWKS, keeping the current keys", "error", err)
+		}
+	}
+
+	r.limiter.Update(conf.RateLimit)
+	r.webhooks.Update(conf.Webhooks)
+
+	if restartRequired(*r.conf, *conf) {
+		slog.Warn("configuration changes other than log level, certificates, rate limits and webhooks require a restart")
+	}
+
+	r.conf = conf
+
+	slog.Info("reloaded configuration", "config", conf)
+}
+
+// restartRequired reports whether prev and next differ in settings that cannot
+// be reloaded.
+func restartRequired(prev, next config.Config) bool {
+	for _, c := range []*config.Config{&prev, &next} {
+		c.File = ""
+		c.Logging.Level = ""
+		c.Server.TLS.CertFile = ""
+		c.Server.TLS.KeyFile = ""
+		c.RateLimit = config.RateLimitConfig{}
+		c.Webhooks = config.WebhooksConfig{}
+	}
+
+	return !reflect.DeepEqual(prev, next)
+}
@@ -0,0 +1,61 @@
+package reload
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync"
+)
+
+// Certificate holds a TLS key pair that can be replaced while the server is
+// running. Handshakes started after a reload use the new key pair, existing
+// connections are left untouched.
+type Certificate struct {
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	certFile string
+	keyFile  string
+}
+
+// LoadCertificate loads the key pair from certFile and keyFile.
+func LoadCertificate(certFile, keyFile string) (*Certificate, error) {
+	c := &Certificate{}
+	if err := c.Reload(certFile, keyFile); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Reload loads the key pair from certFile and keyFile. The current key pair
+// is kept if loading fails.
+func (c *Certificate) Reload(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load key pair: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cert = &cert
+	c.certFile = certFile
+	c.keyFile = keyFile
+
+	return nil
+}
+
+// Files returns the paths the current key pair was loaded from.
+func (c *Certificate) Files() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return []string{c.certFile, c.keyFile}
+}
+
+// GetCertificate can be used as tls.Config.GetCertificate.
+func (c *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cert, nil
+}
@@ -0,0 +1,75 @@
+package reload
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Notify calls fn every time the process receives SIGHUP and, if interval is
+// positive, every time the modification time of one of the paths returned by
+// files changes. Paths that are empty or cannot be read are ignored. Notify
+// blocks until ctx is done.
+func Notify(ctx context.Context, interval time.Duration, files func() []string, fn func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	mtimes := modTimes(files())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			fn()
+			mtimes = modTimes(files())
+		case <-tick:
+			current := modTimes(files())
+			if changed(mtimes, current) {
+				fn()
+				current = modTimes(files())
+			}
+			mtimes = current
+		}
+	}
+}
+
+func modTimes(paths []string) map[string]time.Time {
+	mtimes := make(map[string]time.Time, len(paths))
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+
+		if fi, err := os.Stat(path); err == nil {
+			mtimes[path] = fi.ModTime()
+		}
+	}
+
+	return mtimes
+}
+
+func changed(before, after map[string]time.Time) bool {
+	if len(before) != len(after) {
+		return true
+	}
+
+	for path, mtime := range after {
+		if !before[path].Equal(mtime) {
+			return true
+		}
+	}
+
+	return false
+}
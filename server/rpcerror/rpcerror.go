@@ -0,0 +1,83 @@
+package rpcerror
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FromStore converts an error returned by the store while handling a request
+// for the resource, e.g. "record", with the given id, or 0 if the request is
+// not for a single one. Errors without a more specific code are left for
+// Sanitize.
+func FromStore(err error, resource string, id uint64) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, store.ErrNotFound) && id != 0:
+		return status.Errorf(codes.NotFound, "no %v found with id = %d", resource, id)
+	case errors.Is(err, store.ErrNotFound):
+		return status.Errorf(codes.NotFound, "%v not found", resource)
+	case errors.Is(err, store.ErrAlreadyExists):
+		return status.Errorf(codes.AlreadyExists, "%v already exists", resource)
+	}
+
+	return err
+}
+
+// Sanitize converts err to a status that is safe to return to clients.
+// Errors that already carry a status are returned unchanged. Anything else
+// is logged with the request scoped logger and replaced by a generic message
+// that only refers to the request ID, so internal details never leak.
+func Sanitize(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		return err
+	}
+
+	code, msg, level := codes.Internal, "internal error", slog.LevelError
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		code, msg, level = codes.DeadlineExceeded, "deadline exceeded", slog.LevelWarn
+	case errors.Is(err, context.Canceled):
+		code, msg, level = codes.Canceled, "request canceled", slog.LevelDebug
+	case errors.Is(err, store.ErrUnavailable):
+		code, msg, level = codes.Unavailable, "storage is temporarily unavailable, try again later", slog.LevelWarn
+	}
+
+	logging.FromContext(ctx).Log(ctx, level, "request failed", "code", code.String(), "error", err)
+
+	if requestID := logging.RequestIDFromContext(ctx); requestID != "" {
+		return status.Errorf(code, "%v (request id = %v)", msg, requestID)
+	}
+
+	return status.Error(code, msg)
+}
+
+// UnaryServerInterceptor applies Sanitize to the errors returned by unary
+// handlers. It must run after the logging interceptor.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+
+		return resp, Sanitize(ctx, err)
+	}
+}
+
+// StreamServerInterceptor applies Sanitize to the errors returned by
+// streaming handlers. It must run after the logging interceptor.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return Sanitize(ss.Context(), handler(srv, ss))
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/0gener/go-weight-tracker/server/auth"
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/0gener/go-weight-tracker/server/validation"
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// PublicMethods are the RPCs that can be called without an access token.
+var PublicMethods = []string{"Register", "Login", "RefreshToken", "Logout"}
+
+// Passwords are limited to what bcrypt hashes, longer ones would be
+// silently truncated.
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9._-]{3,64}$`)
+
+func (s *Server) Register(ctx context.Context, req *weighttracker.RegisterRequest) (*weighttracker.RegisterResponse, error) {
+	username := strings.ToLower(req.GetUsername())
+
+	// requests carry passwords and tokens, which must never be logged
+	logging.FromContext(ctx).Debug("Register", "username", username)
+
+	if s.accounts == nil {
+		return nil, errAccountsDisabled
+	}
+
+	var violations []validation.FieldViolation
+
+	if !usernamePattern.MatchString(username) {
+		violations = append(violations, validation.FieldViolation{Field: "username", Description: "must be 3 to 64 letters, digits, dots, dashes or underscores"})
+	}
+
+	if n := len(req.GetPassword()); n < minPasswordLength || n > maxPasswordLength {
+		violations = append(violations, validation.FieldViolation{Field: "password", Description: "must be 8 to 72 bytes long"})
+	}
+
+	if len(violations) > 0 {
+		return nil, &validation.Error{Violations: violations}
+	}
+
+	acc, err := s.accounts.Register(ctx, username, req.GetPassword())
+	switch {
+	case errors.Is(err, auth.ErrRegistrationDisabled):
+		return nil, status.Error(codes.PermissionDenied, "registration is disabled")
+	case errors.Is(err, store.ErrAlreadyExists):
+		return nil, status.Errorf(codes.AlreadyExists, "username %q is taken", username)
+	case err != nil:
+		return nil, err
+	}
+
+	return &weighttracker.RegisterResponse{
+		Account: &weighttracker.Account{
+			Id:        acc.ID,
+			Username:  acc.Username,
+			CreatedAt: timestamppb.New(acc.CreatedAt),
+		},
+	}, nil
+}
+
+func (s *Server) Login(ctx context.Context, req *weighttracker.LoginRequest) (*weighttracker.LoginResponse, error) {
+	username := strings.ToLower(req.GetUsername())
+
+	logging.FromContext(ctx).Debug("Login", "username", username)
+
+	if s.accounts == nil {
+		return nil, errAccountsDisabled
+	}
+
+	tokens, err := s.accounts.Login(ctx, username, req.GetPassword())
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		return nil, status.Error(codes.Unauthenticated, "invalid username or password")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &weighttracker.LoginResponse{Tokens: tokensToPb(tokens)}, nil
+}
+
+func (s *Server) RefreshToken(ctx context.Context, req *weighttracker.RefreshTokenRequest) (*weighttracker.RefreshTokenResponse, error) {
+	logging.FromContext(ctx).Debug("RefreshToken")
+
+	if s.accounts == nil {
+		return nil, errAccountsDisabled
+	}
+
+	tokens, err := s.accounts.Refresh(ctx, req.GetRefreshToken())
+	if errors.Is(err, auth.ErrInvalidToken) {
+		return nil, status.Error(codes.Unauthenticated, "invalid, expired or revoked refresh token")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &weighttracker.RefreshTokenResponse{Tokens: tokensToPb(tokens)}, nil
+}
+
+func (s *Server) Logout(ctx context.Context, req *weighttracker.LogoutRequest) (*weighttracker.LogoutResponse, error) {
+	logging.FromContext(ctx).Debug("Logout", "all_sessions", req.GetAllSessions())
+
+	if s.accounts == nil {
+		return nil, errAccountsDisabled
+	}
+
+	if err := s.accounts.Logout(ctx, req.GetRefreshToken(), req.GetAllSessions()); err != nil {
+		return nil, err
+	}
+
+	return &weighttracker.LogoutResponse{}, nil
+}
+
+var errAccountsDisabled = status.Error(codes.FailedPrecondition, "accounts are disabled, the server does not authenticate callers with them")
+
+func tokensToPb(tokens *auth.Tokens) *weighttracker.Tokens {
+	return &weighttracker.Tokens{
+		AccessToken:           tokens.AccessToken,
+		AccessTokenExpiresAt:  timestamppb.New(tokens.AccessTokenExpiresAt),
+		RefreshToken:          tokens.RefreshToken,
+		RefreshTokenExpiresAt: timestamppb.New(tokens.RefreshTokenExpiresAt),
+	}
+}
@@ -0,0 +1,318 @@
+package service_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/config"
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"github.com/0gener/go-weight-tracker/wttest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func register(t *testing.T, c weighttracker.WeightTrackerClient, username string) *weighttracker.Account {
+	t.Helper()
+
+	resp, err := c.Register(context.Background(), &weighttracker.RegisterRequest{Username: username, Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Register(%q) failed: %v", username, err)
+	}
+
+	return resp.GetAccount()
+}
+
+func login(t *testing.T, c weighttracker.WeightTrackerClient, username string) *weighttracker.Tokens {
+	t.Helper()
+
+	resp, err := c.Login(context.Background(), &weighttracker.LoginRequest{Username: username, Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Login(%q) failed: %v", username, err)
+	}
+
+	return resp.GetTokens()
+}
+
+func refresh(c weighttracker.WeightTrackerClient, tokens *weighttracker.Tokens) (*weighttracker.Tokens, error) {
+	resp, err := c.RefreshToken(context.Background(), &weighttracker.RefreshTokenRequest{RefreshToken: tokens.GetRefreshToken()})
+
+	return resp.GetTokens(), err
+}
+
+// as returns a context sending the access token of tokens.
+func as(tokens *weighttracker.Tokens) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+tokens.GetAccessToken())
+}
+
+func TestAccountsDisabled(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		_, err := c.Login(context.Background(), &weighttracker.LoginRequest{Username: "ana", Password: "correct horse"})
+		checkCode(t, err, codes.FailedPrecondition)
+	})
+}
+
+func TestRegister(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		acc := register(t, c, "Ana")
+		if acc.GetUsername() != "ana" || acc.GetId() == 0 {
+			t.Errorf("got account %v, want a lowercased username and an id", acc)
+		}
+
+		_, err := c.Register(context.Background(), &weighttracker.RegisterRequest{Username: "ANA", Password: "another password"})
+		checkCode(t, err, codes.AlreadyExists)
+
+		_, err = c.Register(context.Background(), &weighttracker.RegisterRequest{Username: "a b", Password: "short"})
+		checkViolations(t, err, "username", "password")
+
+		_, err = c.Login(context.Background(), &weighttracker.LoginRequest{Username: "ana", Password: "wrong horse"})
+		checkCode(t, err, codes.Unauthenticated)
+
+		_, err = c.Login(context.Background(), &weighttracker.LoginRequest{Username: "bob", Password: "correct horse"})
+		checkCode(t, err, codes.Unauthenticated)
+	}, wttest.WithAccounts())
+}
+
+func TestAccessToken(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		ana := register(t, c, "ana")
+		register(t, c, "bob")
+
+		_, err := c.GetSettings(context.Background(), &weighttracker.GetSettingsRequest{})
+		checkCode(t, err, codes.Unauthenticated)
+
+		_, err = c.GetSettings(as(&weighttracker.Tokens{AccessToken: "not.a.token"}), &weighttracker.GetSettingsRequest{})
+		checkCode(t, err, codes.Unauthenticated)
+
+		_, _, err = list(t, c, &weighttracker.ListRecordsRequest{})
+		checkCode(t, err, codes.Unauthenticated)
+
+		anaTokens, bobTokens := login(t, c, "ana"), login(t, c, "bob")
+
+		// settings and audit events belong to the account
+		_, err = c.UpdateSettings(as(anaTokens), &weighttracker.UpdateSettingsRequest{Settings: &weighttracker.Settings{TimeZone: "Europe/Lisbon"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		settings, err := c.GetSettings(as(bobTokens), &weighttracker.GetSettingsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tz := settings.GetSettings().GetTimeZone(); tz != "" {
+			t.Errorf("got time zone %q for bob, want the default", tz)
+		}
+
+		resp, err := c.CreateRecord(as(anaTokens), &weighttracker.CreateRecordRequest{Record: &weighttracker.Record{Weight: 80}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tz := resp.GetRecord().GetTimeZone(); tz != "Europe/Lisbon" {
+			t.Errorf("got time zone %q for ana's record, want Europe/Lisbon", tz)
+		}
+
+		events, err := c.ListAuditEvents(as(anaTokens), &weighttracker.ListAuditEventsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := events.GetEvents()[0].GetPrincipal(), strconv.FormatUint(ana.GetId(), 10); got != want {
+			t.Errorf("got principal %q, want %q", got, want)
+		}
+
+		// access tokens expire on their own
+		clk.Advance(15 * time.Minute)
+
+		_, err = c.GetSettings(as(anaTokens), &weighttracker.GetSettingsRequest{})
+		checkCode(t, err, codes.Unauthenticated)
+	}, wttest.WithAccounts())
+}
+
+func TestRefreshToken(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		register(t, c, "ana")
+		first := login(t, c, "ana")
+
+		clk.Advance(time.Hour)
+
+		second, err := refresh(c, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = c.GetSettings(as(second), &weighttracker.GetSettingsRequest{}); err != nil {
+			t.Fatalf("GetSettings with a refreshed token failed: %v", err)
+		}
+
+		// reusing a refresh token ends every session of the account
+		other := login(t, c, "ana")
+
+		_, err = refresh(c, first)
+		checkCode(t, err, codes.Unauthenticated)
+
+		for _, tokens := range []*weighttracker.Tokens{second, other} {
+			_, err = refresh(c, tokens)
+			checkCode(t, err, codes.Unauthenticated)
+		}
+
+		third := login(t, c, "ana")
+
+		clk.Advance(30 * 24 * time.Hour)
+
+		_, err = refresh(c, third)
+		checkCode(t, err, codes.Unauthenticated)
+	}, wttest.WithAccounts())
+}
+
+func TestLogout(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		register(t, c, "ana")
+		first, second, third := login(t, c, "ana"), login(t, c, "ana"), login(t, c, "ana")
+
+		if _, err := c.Logout(context.Background(), &weighttracker.LogoutRequest{RefreshToken: first.GetRefreshToken()}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := refresh(c, first)
+		checkCode(t, err, codes.Unauthenticated)
+
+		if _, err = c.Logout(context.Background(), &weighttracker.LogoutRequest{RefreshToken: second.GetRefreshToken(), AllSessions: true}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = refresh(c, third)
+		checkCode(t, err, codes.Unauthenticated)
+
+		// logging out with an unknown token is not an error
+		if _, err = c.Logout(context.Background(), &weighttracker.LogoutRequest{RefreshToken: "unknown"}); err != nil {
+			t.Fatal(err)
+		}
+	}, wttest.WithAccounts())
+}
+
+func TestRecordsBelongToTheirOwner(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		register(t, c, "ana")
+		register(t, c, "bob")
+
+		ana, bob := as(login(t, c, "ana")), as(login(t, c, "bob"))
+
+		// client ids only need to be unique per owner
+		created := make(map[context.Context]*weighttracker.Record)
+		for _, ctx := range []context.Context{ana, bob} {
+			resp, err := c.CreateRecord(ctx, &weighttracker.CreateRecordRequest{Record: &weighttracker.Record{Weight: 80, ClientId: "scale-1"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			created[ctx] = resp.GetRecord()
+		}
+
+		anas, bobs := created[ana].GetId(), created[bob].GetId()
+
+		_, err := c.ReadRecord(bob, &weighttracker.ReadRecordRequest{RecordId: anas})
+		checkCode(t, err, codes.NotFound)
+
+		_, err = c.UpdateRecord(bob, &weighttracker.UpdateRecordRequest{Record: &weighttracker.Record{Id: anas, Weight: 90}})
+		checkCode(t, err, codes.NotFound)
+
+		_, err = c.ListRecordRevisions(bob, &weighttracker.ListRecordRevisionsRequest{RecordId: anas})
+		checkCode(t, err, codes.NotFound)
+
+		_, err = c.RevertRecord(bob, &weighttracker.RevertRecordRequest{RecordId: anas, Target: &weighttracker.RevertRecordRequest_Revision{Revision: 1}})
+		checkCode(t, err, codes.NotFound)
+
+		_, err = c.DeleteRecord(bob, &weighttracker.DeleteRecordRequest{RecordId: anas})
+		checkCode(t, err, codes.NotFound)
+
+		stream, err := c.ListRecords(bob, &weighttracker.ListRecordsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.GetRecord().GetId() != bobs || resp.GetTotal() != 1 {
+			t.Errorf("bob listed record %v out of %d, want only his own", resp.GetRecord(), resp.GetTotal())
+		}
+
+		days, err := c.ListDailySummaries(bob, &weighttracker.ListDailySummariesRequest{LocalDateFrom: "2024-06-15", LocalDateTo: "2024-06-15"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(days.GetDays()) != 1 || days.GetDays()[0].GetCount() != 1 {
+			t.Errorf("got daily summaries %v for bob, want a single record", days.GetDays())
+		}
+
+		// pushing a change to a record of someone else is a conflict, as if
+		// it had been deleted
+		sync, err := c.SyncRecords(bob, &weighttracker.SyncRecordsRequest{Changes: []*weighttracker.LocalChange{{
+			Record:     &weighttracker.Record{Id: anas, Weight: 90},
+			ModifiedAt: timestamppb.New(start),
+		}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result := sync.GetResults()[0]; result.GetStatus() != weighttracker.ChangeResult_CONFLICT || !result.GetRecord().GetDeleted() {
+			t.Errorf("got result %v pushing a change to ana's record", result)
+		}
+
+		if len(sync.GetChanges()) != 1 || sync.GetChanges()[0].GetRecord().GetId() != bobs {
+			t.Errorf("bob pulled changes %v, want only his own", sync.GetChanges())
+		}
+
+		// events replayed from the start only include the caller's records
+		watch, err := c.WatchRecords(bob, &weighttracker.WatchRecordsRequest{ResumeAfter: uint64(start.UnixMicro())})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		event, err := watch.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if event.GetRecord().GetId() != bobs {
+			t.Errorf("bob watched record %v, want his own", event.GetRecord())
+		}
+
+		read, err := c.ReadRecord(ana, &weighttracker.ReadRecordRequest{RecordId: anas})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if read.GetRecord().GetWeight() != 80 || read.GetRecord().GetRevision() != 1 {
+			t.Errorf("ana's record was changed by bob: %v", read.GetRecord())
+		}
+
+		// so are the audit events and webhook deliveries of their records
+		events, err := c.ListAuditEvents(bob, &weighttracker.ListAuditEventsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(events.GetEvents()) != 1 || events.GetEvents()[0].GetRecordId() != bobs {
+			t.Errorf("bob listed audit events %v, want only those of his record", events.GetEvents())
+		}
+
+		deliveries, err := c.ListWebhookDeliveries(bob, &weighttracker.ListWebhookDeliveriesRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(deliveries.GetDeliveries()) != 1 || deliveries.GetDeliveries()[0].GetRecordId() != bobs {
+			t.Errorf("bob listed webhook deliveries %v, want only those of his record", deliveries.GetDeliveries())
+		}
+	}, wttest.WithAccounts(), wttest.WithWebhooks(config.WebhooksConfig{Subscriptions: []config.WebhookConfig{
+		{Name: "all", URL: "http://127.0.0.1:1/all"},
+	}}))
+}
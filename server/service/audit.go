@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+
+	"github.com/0gener/go-weight-tracker/server/auth"
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"github.com/0gener/go-weight-tracker/server/rpcerror"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/0gener/go-weight-tracker/server/validation"
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ActorInterceptor attaches the store.Actor audited with every change made by
+// the call. Only unary RPCs change records. Must run after the logging
+// interceptor, which assigns the request ID, and the auth interceptor, if
+// any, which identifies the caller.
+func ActorInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = store.WithActor(ctx, store.Actor{
+			Principal: auth.PrincipalFromContext(ctx),
+			Method:    info.FullMethod,
+			RequestID: logging.RequestIDFromContext(ctx),
+		})
+
+		return handler(ctx, req)
+	}
+}
+
+func (s *Server) ListAuditEvents(ctx context.Context, req *weighttracker.ListAuditEventsRequest) (*weighttracker.ListAuditEventsResponse, error) {
+	logging.FromContext(ctx).Debug("ListAuditEvents", "request", req)
+
+	var violations []validation.FieldViolation
+
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize < 0 || pageSize > maxPageSize:
+		violations = append(violations, validation.FieldViolation{Field: "page_size", Description: "must be between 0 and 500"})
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	}
+
+	beforeID, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		violations = append(violations, validation.FieldViolation{Field: "page_token", Description: "invalid page token"})
+	}
+
+	if req.GetFrom() != nil && req.GetTo() != nil && !req.GetFrom().AsTime().Before(req.GetTo().AsTime()) {
+		violations = append(violations, validation.FieldViolation{Field: "to", Description: "must be after from"})
+	}
+
+	if len(violations) > 0 {
+		return nil, &validation.Error{Violations: violations}
+	}
+
+	opts := store.AuditListOptions{
+		Owner:     auth.PrincipalFromContext(ctx),
+		RecordID:  req.GetRecordId(),
+		Principal: req.GetPrincipal(),
+		Action:    auditActionFromPb(req.GetAction()),
+		BeforeID:  beforeID,
+		Limit:     pageSize + 1, // one more than requested tells whether there is another page
+	}
+
+	if req.GetFrom() != nil {
+		t := req.GetFrom().AsTime()
+		opts.From = &t
+	}
+
+	if req.GetTo() != nil {
+		t := req.GetTo().AsTime()
+		opts.To = &t
+	}
+
+	auditEvents, err := s.store.ListAuditEvents(ctx, opts)
+	if err != nil {
+		return nil, rpcerror.FromStore(err, "audit event", 0)
+	}
+
+	resp := &weighttracker.ListAuditEventsResponse{}
+
+	if len(auditEvents) > pageSize {
+		auditEvents = auditEvents[:pageSize]
+		resp.NextPageToken = encodePageToken(auditEvents[pageSize-1].ID)
+	}
+
+	for _, e := range auditEvents {
+		resp.Events = append(resp.Events, auditEventToPb(e))
+	}
+
+	return resp, nil
+}
+
+func auditActionFromPb(action weighttracker.AuditEvent_Action) string {
+	switch action {
+	case weighttracker.AuditEvent_CREATED:
+		return store.AuditCreated
+	case weighttracker.AuditEvent_UPDATED:
+		return store.AuditUpdated
+	case weighttracker.AuditEvent_DELETED:
+		return store.AuditDeleted
+	default:
+		return ""
+	}
+}
+
+func auditEventToPb(e store.AuditEvent) *weighttracker.AuditEvent {
+	action := weighttracker.AuditEvent_ACTION_UNSPECIFIED
+	switch e.Action {
+	case store.AuditCreated:
+		action = weighttracker.AuditEvent_CREATED
+	case store.AuditUpdated:
+		action = weighttracker.AuditEvent_UPDATED
+	case store.AuditDeleted:
+		action = weighttracker.AuditEvent_DELETED
+	}
+
+	pb := &weighttracker.AuditEvent{
+		Id:        e.ID,
+		Action:    action,
+		RecordId:  e.RecordID,
+		Principal: e.Principal,
+		Method:    e.Method,
+		RequestId: e.RequestID,
+		CreatedAt: timestamppb.New(e.CreatedAt),
+	}
+
+	if e.Before != nil {
+		pb.Before = dataToRecordPb(*e.Before)
+	}
+
+	if e.After != nil {
+		pb.After = dataToRecordPb(*e.After)
+	}
+
+	return pb
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+
+	"github.com/0gener/go-weight-tracker/server/auth"
+	"github.com/0gener/go-weight-tracker/server/events"
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"github.com/0gener/go-weight-tracker/server/rpcerror"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/0gener/go-weight-tracker/server/validation"
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) ListRecordRevisions(ctx context.Context, req *weighttracker.ListRecordRevisionsRequest) (*weighttracker.ListRecordRevisionsResponse, error) {
+	logging.FromContext(ctx).Debug("ListRecordRevisions", "request", req)
+
+	var violations []validation.FieldViolation
+
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize < 0 || pageSize > maxPageSize:
+		violations = append(violations, validation.FieldViolation{Field: "page_size", Description: "must be between 0 and 500"})
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	}
+
+	beforeRevision, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		violations = append(violations, validation.FieldViolation{Field: "page_token", Description: "invalid page token"})
+	}
+
+	if len(violations) > 0 {
+		return nil, &validation.Error{Violations: violations}
+	}
+
+	// one more than requested tells whether there is another page
+	revisions, err := s.store.ListRevisions(ctx, auth.PrincipalFromContext(ctx), req.GetRecordId(), store.RevisionListOptions{
+		BeforeRevision: beforeRevision,
+		Limit:          pageSize + 1,
+	})
+	if err != nil {
+		return nil, rpcerror.FromStore(err, "record", req.GetRecordId())
+	}
+
+	resp := &weighttracker.ListRecordRevisionsResponse{}
+
+	if len(revisions) > pageSize {
+		revisions = revisions[:pageSize]
+		resp.NextPageToken = encodePageToken(revisions[pageSize-1].Revision)
+	}
+
+	for _, rev := range revisions {
+		resp.Revisions = append(resp.Revisions, revisionToPb(rev))
+	}
+
+	return resp, nil
+}
+
+func (s *Server) RevertRecord(ctx context.Context, req *weighttracker.RevertRecordRequest) (*weighttracker.RevertRecordResponse, error) {
+	logging.FromContext(ctx).Debug("RevertRecord", "request", req)
+
+	recordID := req.GetRecordId()
+
+	opts := store.RevisionListOptions{Limit: 1}
+	switch target := req.GetTarget().(type) {
+	case *weighttracker.RevertRecordRequest_Revision:
+		if target.Revision == 0 {
+			return nil, &validation.Error{Violations: []validation.FieldViolation{{Field: "revision", Description: "must be greater than 0"}}}
+		}
+
+		opts.BeforeRevision = target.Revision + 1
+	case *weighttracker.RevertRecordRequest_At:
+		if err := target.At.CheckValid(); err != nil {
+			return nil, &validation.Error{Violations: []validation.FieldViolation{{Field: "at", Description: "invalid timestamp"}}}
+		}
+
+		at := target.At.AsTime()
+		opts.At = &at
+	default:
+		return nil, &validation.Error{Violations: []validation.FieldViolation{{Field: "target", Description: "revision or at is required"}}}
+	}
+
+	revisions, err := s.store.ListRevisions(ctx, auth.PrincipalFromContext(ctx), recordID, opts)
+	if err != nil {
+		return nil, rpcerror.FromStore(err, "record", recordID)
+	}
+
+	if len(revisions) == 0 || (req.GetRevision() != 0 && revisions[0].Revision != req.GetRevision()) {
+		return nil, status.Errorf(codes.NotFound, "no matching revision found for record with id = %d", recordID)
+	}
+
+	target := revisions[0]
+
+	var prev store.Record
+
+	record, err := s.store.UpdateRecord(s.changing(ctx), auth.PrincipalFromContext(ctx), recordID, func(rec *store.Record) error {
+		prev = *rec
+		rec.Weight = target.Weight
+		rec.WeightedAt = target.WeightedAt
+		rec.TimeZone = target.TimeZone
+		rec.UTCOffset = target.UTCOffset
+
+		return nil
+	})
+	if err != nil {
+		return nil, rpcerror.FromStore(err, "record", recordID)
+	}
+
+	s.recordChanged(events.Updated, *record, &prev)
+
+	return &weighttracker.RevertRecordResponse{
+		Record: dataToRecordPb(*record),
+	}, nil
+}
+
+func revisionToPb(rev store.Revision) *weighttracker.RecordRevision {
+	return &weighttracker.RecordRevision{
+		RecordId:   rev.RecordID,
+		Revision:   rev.Revision,
+		Weight:     rev.Weight,
+		WeightedAt: timestamppb.New(rev.WeightedAt),
+		CreatedAt:  timestamppb.New(rev.CreatedAt),
+		TimeZone:   rev.TimeZone,
+		UtcOffset:  rev.UTCOffset,
+	}
+}
@@ -0,0 +1,402 @@
+// Package service implements the WeightTracker gRPC service.
+package service
+
+import (
+	"context"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/auth"
+	"github.com/0gener/go-weight-tracker/server/events"
+	"github.com/0gener/go-weight-tracker/server/filter"
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"github.com/0gener/go-weight-tracker/server/rpcerror"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/0gener/go-weight-tracker/server/validation"
+	"github.com/0gener/go-weight-tracker/server/webhook"
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements weighttracker.WeightTrackerServer on top of a store.
+// ActorInterceptor must run before its unary handlers, so that changes are
+// audited with the caller, and after the auth interceptor, if any.
+type Server struct {
+	weighttracker.UnsafeWeightTrackerServer
+	store     store.Store
+	validator *validation.Validator
+	events    *events.Broker
+	webhooks  *webhook.Dispatcher
+	accounts  *auth.Accounts // nil unless callers log in with accounts
+	now       func() time.Time
+}
+
+// New creates a server storing records in st. Changes are published to
+// broker and queued for delivery by webhooks. accounts serves the account
+// RPCs, which fail if it is nil. now is used for records created without
+// weighted_at and by SyncRecords.
+func New(st store.Store, validator *validation.Validator, broker *events.Broker, webhooks *webhook.Dispatcher, accounts *auth.Accounts, now func() time.Time) *Server {
+	return &Server{
+		store:     st,
+		validator: validator,
+		events:    broker,
+		webhooks:  webhooks,
+		accounts:  accounts,
+		now:       now,
+	}
+}
+
+// changing returns ctx for changing records, which makes the store queue
+// the webhook deliveries for every change along with it.
+func (s *Server) changing(ctx context.Context) context.Context {
+	return store.WithDeliveries(ctx, s.webhooks.Deliveries)
+}
+
+// recordChanged notifies watchers and the webhook dispatcher of a change
+// that has been stored.
+func (s *Server) recordChanged(typ events.Type, rec store.Record, prev *store.Record) {
+	s.events.Publish(typ, rec, prev)
+	s.webhooks.Wake()
+}
+
+func (s *Server) CreateRecord(ctx context.Context, req *weighttracker.CreateRecordRequest) (*weighttracker.CreateRecordResponse, error) {
+	logging.FromContext(ctx).Debug("CreateRecord", "request", req)
+
+	if err := s.validator.ValidateNewRecord("record", req.GetRecord()); err != nil {
+		return nil, err
+	}
+
+	record, err := s.newRecord(ctx, req.GetRecord())
+	if err != nil {
+		return nil, rpcerror.FromStore(err, "settings", 0)
+	}
+
+	if err = s.store.CreateRecord(s.changing(ctx), &record); err != nil {
+		return nil, rpcerror.FromStore(err, "record", record.ID)
+	}
+
+	s.recordChanged(events.Created, record, nil)
+
+	return &weighttracker.CreateRecordResponse{
+		Record: dataToRecordPb(record),
+	}, nil
+}
+
+func (s *Server) ReadRecord(ctx context.Context, req *weighttracker.ReadRecordRequest) (*weighttracker.ReadRecordResponse, error) {
+	logging.FromContext(ctx).Debug("ReadRecord", "request", req)
+
+	recordID := req.GetRecordId()
+
+	record, err := s.store.ReadRecord(ctx, auth.PrincipalFromContext(ctx), recordID)
+	if err != nil {
+		return nil, rpcerror.FromStore(err, "record", recordID)
+	}
+
+	return &weighttracker.ReadRecordResponse{
+		Record: dataToRecordPb(*record),
+	}, nil
+}
+
+func (s *Server) UpdateRecord(ctx context.Context, req *weighttracker.UpdateRecordRequest) (*weighttracker.UpdateRecordResponse, error) {
+	logging.FromContext(ctx).Debug("UpdateRecord", "request", req)
+
+	if err := s.validator.ValidateRecordUpdate("record", req.GetRecord()); err != nil {
+		return nil, err
+	}
+
+	var prev store.Record
+
+	record, err := s.store.UpdateRecord(s.changing(ctx), auth.PrincipalFromContext(ctx), req.GetRecord().GetId(), func(rec *store.Record) error {
+		prev = *rec
+
+		return applyUpdate(rec, req.GetRecord())
+	})
+	if err != nil {
+		return nil, rpcerror.FromStore(err, "record", req.GetRecord().GetId())
+	}
+
+	s.recordChanged(events.Updated, *record, &prev)
+
+	return &weighttracker.UpdateRecordResponse{
+		Record: dataToRecordPb(*record),
+	}, nil
+}
+
+func (s *Server) DeleteRecord(ctx context.Context, req *weighttracker.DeleteRecordRequest) (*weighttracker.DeleteRecordResponse, error) {
+	logging.FromContext(ctx).Debug("DeleteRecord", "request", req)
+
+	recordID := req.GetRecordId()
+
+	record, err := s.store.DeleteRecord(s.changing(ctx), auth.PrincipalFromContext(ctx), recordID)
+	if err != nil {
+		return nil, rpcerror.FromStore(err, "record", recordID)
+	}
+
+	s.recordChanged(events.Deleted, *record, record)
+
+	return &weighttracker.DeleteRecordResponse{}, nil
+}
+
+// maxListBatchSize bounds how many records ListRecords sends per message,
+// and is how many it reads from the store at a time when sending them one by
+// one.
+const maxListBatchSize = 500
+
+func (s *Server) ListRecords(req *weighttracker.ListRecordsRequest, stream weighttracker.WeightTracker_ListRecordsServer) error {
+	ctx := stream.Context()
+	logging.FromContext(ctx).Debug("ListRecords", "request", req)
+
+	opts, err := listRecordsOptions(req)
+	if err != nil {
+		return err
+	}
+
+	opts.Owner = auth.PrincipalFromContext(ctx)
+
+	total, err := s.store.CountRecords(ctx, opts)
+	if err != nil {
+		return rpcerror.FromStore(err, "record", 0)
+	}
+
+	if opts.Limit > 0 {
+		total = min(total, opts.Limit)
+	}
+
+	batchSize := int(req.GetBatchSize())
+	pageSize := batchSize
+	if batchSize == 0 {
+		batchSize, pageSize = 1, maxListBatchSize
+	}
+
+	// read the records a page at a time, so only one page is ever held in
+	// memory, continuing after the last record of the previous page
+	page := opts
+	sent := 0
+	for opts.Limit == 0 || sent < opts.Limit {
+		page.Limit = pageSize
+		if opts.Limit > 0 {
+			page.Limit = min(pageSize, opts.Limit-sent)
+		}
+
+		records, err := s.store.ListRecords(ctx, page)
+		if err != nil {
+			return rpcerror.FromStore(err, "record", 0)
+		}
+
+		for i := 0; i < len(records); i += batchSize {
+			batch := records[i:min(i+batchSize, len(records))]
+
+			// records created since counting them are sent as well
+			total = max(total, sent+len(batch))
+
+			// Send blocks while the client is not reading, so check before
+			// every batch that it has not gone away in the meantime
+			err := ctx.Err()
+			if err == nil {
+				err = stream.Send(listRecordsResponse(batch, sent+len(batch), total))
+			}
+			if err != nil {
+				logging.FromContext(ctx).Debug("ListRecords stopped", "sent", sent, "total", total)
+				return err
+			}
+
+			sent += len(batch)
+		}
+
+		if len(records) < page.Limit {
+			break
+		}
+
+		page.After = &records[len(records)-1]
+	}
+
+	return nil
+}
+
+// listRecordsResponse returns the message carrying batch, after which sent
+// out of total records have been sent.
+func listRecordsResponse(batch []store.Record, sent, total int) *weighttracker.ListRecordsResponse {
+	resp := &weighttracker.ListRecordsResponse{
+		Records: make([]*weighttracker.Record, 0, len(batch)),
+		Sent:    int32(sent),
+		Total:   int32(total),
+	}
+
+	for _, record := range batch {
+		resp.Records = append(resp.Records, dataToRecordPb(record))
+	}
+
+	if len(resp.Records) == 1 {
+		resp.Record = resp.Records[0]
+	}
+
+	return resp
+}
+
+// recordFilter is implemented by the requests that filter records like
+// ListRecords does, so the same filters always select the same records.
+type recordFilter interface {
+	GetWeightedAtFrom() *timestamppb.Timestamp
+	GetWeightedAtTo() *timestamppb.Timestamp
+	GetWeightedAtFromInclusive() bool
+	GetWeightedAtToInclusive() bool
+	GetLocalDateFrom() string
+	GetLocalDateTo() string
+	GetMinWeight() float32
+	GetMaxWeight() float32
+	GetTags() []string
+	GetSource() string
+	GetFilter() string
+}
+
+// filterOptions validates the filters of req and converts them.
+func filterOptions(req recordFilter) (store.ListOptions, []validation.FieldViolation) {
+	violations := validateLocalDates(req.GetLocalDateFrom(), req.GetLocalDateTo())
+
+	opts := store.ListOptions{
+		WeightedAtFromInclusive: req.GetWeightedAtFromInclusive(),
+		WeightedAtToInclusive:   req.GetWeightedAtToInclusive(),
+		LocalDateFrom:           req.GetLocalDateFrom(),
+		LocalDateTo:             req.GetLocalDateTo(),
+		Tags:                    req.GetTags(),
+		Source:                  req.GetSource(),
+	}
+
+	if from := req.GetWeightedAtFrom(); from != nil {
+		t := from.AsTime()
+		opts.WeightedAtFrom = &t
+	}
+
+	if to := req.GetWeightedAtTo(); to != nil {
+		t := to.AsTime()
+		opts.WeightedAtTo = &t
+	}
+
+	if minWeight := req.GetMinWeight(); minWeight != 0 {
+		opts.MinWeight = &minWeight
+	}
+
+	if maxWeight := req.GetMaxWeight(); maxWeight != 0 {
+		opts.MaxWeight = &maxWeight
+	}
+
+	for _, w := range []struct {
+		field string
+		value float32
+	}{{"min_weight", req.GetMinWeight()}, {"max_weight", req.GetMaxWeight()}} {
+		if v := float64(w.value); v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			violations = append(violations, validation.FieldViolation{Field: w.field, Description: "must be a positive number"})
+		}
+	}
+
+	if opts.MinWeight != nil && opts.MaxWeight != nil && *opts.MaxWeight < *opts.MinWeight {
+		violations = append(violations, validation.FieldViolation{Field: "max_weight", Description: "must not be lower than min_weight"})
+	}
+
+	expr, err := filter.Parse(req.GetFilter(), store.RecordFilterSchema)
+	if err != nil {
+		violations = append(violations, validation.FieldViolation{Field: "filter", Description: err.Error()})
+	}
+
+	opts.Filter = expr
+
+	return opts, violations
+}
+
+// listRecordsOptions validates req and converts its filters.
+func listRecordsOptions(req *weighttracker.ListRecordsRequest) (store.ListOptions, error) {
+	opts, violations := filterOptions(req)
+	opts.Latest = req.GetLatest()
+	opts.Limit = int(req.GetLimit())
+
+	if opts.Limit < 0 {
+		violations = append(violations, validation.FieldViolation{Field: "limit", Description: "must not be negative"})
+	}
+
+	if batchSize := req.GetBatchSize(); batchSize < 0 || batchSize > maxListBatchSize {
+		violations = append(violations, validation.FieldViolation{Field: "batch_size", Description: "must be between 0 and 500"})
+	}
+
+	if len(violations) > 0 {
+		return opts, &validation.Error{Violations: violations}
+	}
+
+	return opts, nil
+}
+
+// newRecord returns the record to create from pb, which must be valid.
+func (s *Server) newRecord(ctx context.Context, pb *weighttracker.Record) (store.Record, error) {
+	rec := store.Record{
+		Owner:      auth.PrincipalFromContext(ctx),
+		ClientID:   pb.GetClientId(),
+		Weight:     pb.GetWeight(),
+		WeightedAt: s.now().UTC(),
+		Tags:       normalizeTags(pb.GetTags()),
+		Source:     pb.GetSource(),
+	}
+
+	if pb.GetWeightedAt() != nil {
+		rec.WeightedAt = pb.GetWeightedAt().AsTime()
+	}
+
+	zone, err := s.defaultTimeZone(ctx)
+	if err != nil {
+		return rec, err
+	}
+
+	rec.TimeZone = zone
+
+	return rec, updateTimeZone(&rec, pb)
+}
+
+// applyUpdate applies the non-zero fields of pb, which must be valid, to
+// rec.
+func applyUpdate(rec *store.Record, pb *weighttracker.Record) error {
+	if pb.GetWeight() != 0 {
+		rec.Weight = pb.GetWeight()
+	}
+
+	if pb.GetWeightedAt() != nil {
+		rec.WeightedAt = pb.GetWeightedAt().AsTime()
+	}
+
+	if len(pb.GetTags()) > 0 {
+		rec.Tags = normalizeTags(pb.GetTags())
+	}
+
+	if pb.GetSource() != "" {
+		rec.Source = pb.GetSource()
+	}
+
+	return updateTimeZone(rec, pb)
+}
+
+// normalizeTags returns tags sorted and without duplicates.
+func normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+
+	return slices.Compact(tags)
+}
+
+func dataToRecordPb(rec store.Record) *weighttracker.Record {
+	return &weighttracker.Record{
+		Id:         rec.ID,
+		Weight:     rec.Weight,
+		WeightedAt: timestamppb.New(rec.WeightedAt),
+		CreatedAt:  timestamppb.New(rec.CreatedAt),
+		UpdatedAt:  timestamppb.New(rec.UpdatedAt),
+		ClientId:   rec.ClientID,
+		Revision:   rec.Revision,
+		TimeZone:   rec.TimeZone,
+		UtcOffset:  rec.UTCOffset,
+		LocalDate:  rec.LocalDate(),
+		Tags:       rec.Tags,
+		Source:     rec.Source,
+	}
+}
@@ -0,0 +1,749 @@
+package service_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/config"
+	"github.com/0gener/go-weight-tracker/server/database"
+	"github.com/0gener/go-weight-tracker/server/migrations"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/0gener/go-weight-tracker/server/store/filestore"
+	"github.com/0gener/go-weight-tracker/server/store/sqlstore"
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"github.com/0gener/go-weight-tracker/wttest"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var start = time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+// clock is a manually advanced clock.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// stores lists the stores every test runs against.
+var stores = []struct {
+	name string
+	open func(t *testing.T) store.Store
+}{
+	{"memory", func(t *testing.T) store.Store {
+		return filestore.NewMemory()
+	}},
+	{"sqlite", func(t *testing.T) store.Store {
+		ctx := context.Background()
+
+		dbConfig := config.Default().Database
+		dbConfig.Driver = "sqlite"
+		dbConfig.Path = filepath.Join(t.TempDir(), "wt.db")
+
+		db, err := database.Open(ctx, dbConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		migrator, err := migrations.New(ctx, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = migrator.Up(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		return sqlstore.New(db)
+	}},
+}
+
+// run runs test against a server backed by every store and configured by
+// opts.
+func run(t *testing.T, test func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock), opts ...wttest.Option) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			st := s.open(t)
+			t.Cleanup(func() { st.Close() })
+
+			clk := &clock{now: start}
+			opts := append([]wttest.Option{wttest.WithStore(st), wttest.WithClock(clk.Now)}, opts...)
+			srv := wttest.NewServer(t, opts...)
+
+			test(t, weighttracker.NewWeightTrackerClient(srv.Conn()), clk)
+		})
+	}
+}
+
+func create(t *testing.T, c weighttracker.WeightTrackerClient, rec *weighttracker.Record) *weighttracker.Record {
+	t.Helper()
+
+	resp, err := c.CreateRecord(context.Background(), &weighttracker.CreateRecordRequest{Record: rec})
+	if err != nil {
+		t.Fatalf("CreateRecord(%v) failed: %v", rec, err)
+	}
+
+	return resp.GetRecord()
+}
+
+// list returns every record streamed by ListRecords and the number of
+// messages they came in.
+func list(t *testing.T, c weighttracker.WeightTrackerClient, req *weighttracker.ListRecordsRequest) ([]*weighttracker.Record, int, error) {
+	t.Helper()
+
+	stream, err := c.ListRecords(context.Background(), req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		records  []*weighttracker.Record
+		messages int
+	)
+
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return records, messages, nil
+		}
+		if err != nil {
+			return records, messages, err
+		}
+
+		messages++
+		records = append(records, resp.GetRecords()...)
+
+		if int(resp.GetSent()) != len(records) {
+			t.Errorf("sent = %d after %d records", resp.GetSent(), len(records))
+		}
+	}
+}
+
+func weights(records []*weighttracker.Record) []float32 {
+	ws := make([]float32, 0, len(records))
+	for _, rec := range records {
+		ws = append(ws, rec.GetWeight())
+	}
+
+	return ws
+}
+
+// checkCode fails the test unless err has the given status code.
+func checkCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+
+	if got := status.Code(err); got != code {
+		t.Fatalf("got code %v (%v), want %v", got, err, code)
+	}
+}
+
+// checkViolations fails the test unless err is INVALID_ARGUMENT with
+// violations for exactly the given fields.
+func checkViolations(t *testing.T, err error, fields ...string) {
+	t.Helper()
+
+	checkCode(t, err, codes.InvalidArgument)
+
+	var got []string
+	for _, detail := range status.Convert(err).Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				got = append(got, v.GetField())
+			}
+		}
+	}
+
+	if !slices.Equal(got, fields) {
+		t.Fatalf("got violations for %v, want %v", got, fields)
+	}
+}
+
+func TestCreateRecord(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		rec := create(t, c, &weighttracker.Record{Weight: 80.5, Tags: []string{"morning", "gym", "morning"}, Source: "scale"})
+
+		if rec.GetId() == 0 || rec.GetRevision() != 1 {
+			t.Errorf("got id %d and revision %d", rec.GetId(), rec.GetRevision())
+		}
+		if got := rec.GetWeightedAt().AsTime(); !got.Equal(start) {
+			t.Errorf("weighted_at defaulted to %v, want %v", got, start)
+		}
+		if rec.GetTimeZone() != "UTC" || rec.GetLocalDate() != "2024-06-15" {
+			t.Errorf("got time zone %q and local date %q", rec.GetTimeZone(), rec.GetLocalDate())
+		}
+		if !slices.Equal(rec.GetTags(), []string{"gym", "morning"}) || rec.GetSource() != "scale" {
+			t.Errorf("got tags %v and source %q", rec.GetTags(), rec.GetSource())
+		}
+		if rec.GetCreatedAt() == nil || rec.GetUpdatedAt() == nil {
+			t.Error("created_at and updated_at are not set")
+		}
+
+		clk.Advance(time.Hour)
+
+		rec = create(t, c, &weighttracker.Record{Weight: 81})
+		if got := rec.GetWeightedAt().AsTime(); !got.Equal(start.Add(time.Hour)) {
+			t.Errorf("weighted_at defaulted to %v, want %v", got, start.Add(time.Hour))
+		}
+
+		// local date of the zone the record was taken in
+		at := time.Date(2024, 6, 14, 23, 30, 0, 0, time.UTC)
+		rec = create(t, c, &weighttracker.Record{Weight: 82, WeightedAt: timestamppb.New(at), TimeZone: "Europe/Lisbon"})
+		if rec.GetUtcOffset() != 3600 || rec.GetLocalDate() != "2024-06-15" {
+			t.Errorf("got offset %d and local date %q", rec.GetUtcOffset(), rec.GetLocalDate())
+		}
+	})
+}
+
+func TestCreateRecordValidation(t *testing.T) {
+	now := timestamppb.New(start)
+
+	tests := []struct {
+		name   string
+		record *weighttracker.Record
+		fields []string // nil if the record is valid
+	}{
+		{"missing record", nil, []string{"record"}},
+		{"minimum weight", &weighttracker.Record{Weight: 1, WeightedAt: now}, nil},
+		{"maximum weight", &weighttracker.Record{Weight: 650, WeightedAt: now}, nil},
+		{"weight too low", &weighttracker.Record{Weight: 0.99, WeightedAt: now}, []string{"record.weight"}},
+		{"weight too high", &weighttracker.Record{Weight: 650.01, WeightedAt: now}, []string{"record.weight"}},
+		{"latest weighted_at", &weighttracker.Record{Weight: 80, WeightedAt: timestamppb.New(start.Add(5 * time.Minute))}, nil},
+		{"weighted_at in the future", &weighttracker.Record{Weight: 80, WeightedAt: timestamppb.New(start.Add(5*time.Minute + time.Second))}, []string{"record.weighted_at"}},
+		{"earliest weighted_at", &weighttracker.Record{Weight: 80, WeightedAt: timestamppb.New(time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC))}, nil},
+		{"weighted_at too old", &weighttracker.Record{Weight: 80, WeightedAt: timestamppb.New(time.Date(1899, 12, 31, 23, 59, 59, 0, time.UTC))}, []string{"record.weighted_at"}},
+		{"invalid time zone", &weighttracker.Record{Weight: 80, TimeZone: "Mars/Olympus"}, []string{"record.time_zone"}},
+		{"time zone and offset", &weighttracker.Record{Weight: 80, TimeZone: "UTC", UtcOffset: 3600}, []string{"record.utc_offset"}},
+		{"offset too large", &weighttracker.Record{Weight: 80, UtcOffset: 19 * 3600}, []string{"record.utc_offset"}},
+		{"empty tag", &weighttracker.Record{Weight: 80, Tags: []string{"ok", ""}}, []string{"record.tags[1]"}},
+		{"every violation", &weighttracker.Record{Weight: 0, WeightedAt: timestamppb.New(start.AddDate(1, 0, 0)), TimeZone: "Nowhere"}, []string{"record.weight", "record.weighted_at", "record.time_zone"}},
+	}
+
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := c.CreateRecord(context.Background(), &weighttracker.CreateRecordRequest{Record: tt.record})
+				if tt.fields == nil {
+					if err != nil {
+						t.Fatalf("CreateRecord failed: %v", err)
+					}
+					return
+				}
+
+				checkViolations(t, err, tt.fields...)
+			})
+		}
+	})
+}
+
+func TestReadRecord(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		ctx := context.Background()
+
+		created := create(t, c, &weighttracker.Record{Weight: 72.25, Tags: []string{"a"}})
+
+		resp, err := c.ReadRecord(ctx, &weighttracker.ReadRecordRequest{RecordId: created.GetId()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := resp.GetRecord()
+		if got.GetWeight() != 72.25 || !got.GetWeightedAt().AsTime().Equal(start) || !slices.Equal(got.GetTags(), []string{"a"}) {
+			t.Errorf("got %v, want %v", got, created)
+		}
+
+		_, err = c.ReadRecord(ctx, &weighttracker.ReadRecordRequest{RecordId: created.GetId() + 1})
+		checkCode(t, err, codes.NotFound)
+
+		if got, want := status.Convert(err).Message(), fmt.Sprintf("no record found with id = %d", created.GetId()+1); got != want {
+			t.Errorf("got message %q, want %q", got, want)
+		}
+	})
+}
+
+func TestUpdateRecord(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		ctx := context.Background()
+
+		created := create(t, c, &weighttracker.Record{Weight: 80, Tags: []string{"a"}, Source: "scale"})
+
+		clk.Advance(time.Minute)
+
+		// only the fields set are changed
+		resp, err := c.UpdateRecord(ctx, &weighttracker.UpdateRecordRequest{Record: &weighttracker.Record{Id: created.GetId(), Weight: 79.5}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := resp.GetRecord()
+		if got.GetWeight() != 79.5 || got.GetRevision() != 2 || !got.GetWeightedAt().AsTime().Equal(start) ||
+			!slices.Equal(got.GetTags(), []string{"a"}) || got.GetSource() != "scale" {
+			t.Errorf("got %v after updating the weight", got)
+		}
+
+		// tags and source do not add revisions
+		resp, err = c.UpdateRecord(ctx, &weighttracker.UpdateRecordRequest{Record: &weighttracker.Record{Id: created.GetId(), Tags: []string{"c", "b"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got = resp.GetRecord(); got.GetRevision() != 2 || !slices.Equal(got.GetTags(), []string{"b", "c"}) {
+			t.Errorf("got revision %d and tags %v after updating the tags", got.GetRevision(), got.GetTags())
+		}
+
+		moved := start.Add(-24 * time.Hour)
+		resp, err = c.UpdateRecord(ctx, &weighttracker.UpdateRecordRequest{Record: &weighttracker.Record{Id: created.GetId(), WeightedAt: timestamppb.New(moved)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got = resp.GetRecord(); !got.GetWeightedAt().AsTime().Equal(moved) || got.GetLocalDate() != "2024-06-14" || got.GetRevision() != 3 {
+			t.Errorf("got %v after moving the record", got)
+		}
+
+		read, err := c.ReadRecord(ctx, &weighttracker.ReadRecordRequest{RecordId: created.GetId()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if read.GetRecord().GetWeight() != 79.5 || read.GetRecord().GetRevision() != 3 {
+			t.Errorf("read %v after updating", read.GetRecord())
+		}
+
+		_, err = c.UpdateRecord(ctx, &weighttracker.UpdateRecordRequest{Record: &weighttracker.Record{Id: created.GetId() + 1, Weight: 70}})
+		checkCode(t, err, codes.NotFound)
+
+		_, err = c.UpdateRecord(ctx, &weighttracker.UpdateRecordRequest{Record: &weighttracker.Record{Weight: 700}})
+		checkViolations(t, err, "record.id", "record.weight")
+
+		_, err = c.UpdateRecord(ctx, &weighttracker.UpdateRecordRequest{Record: &weighttracker.Record{Id: created.GetId(), WeightedAt: timestamppb.New(start.Add(time.Hour))}})
+		checkViolations(t, err, "record.weighted_at")
+
+		if _, err = c.DeleteRecord(ctx, &weighttracker.DeleteRecordRequest{RecordId: created.GetId()}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.UpdateRecord(ctx, &weighttracker.UpdateRecordRequest{Record: &weighttracker.Record{Id: created.GetId(), Weight: 70}})
+		checkCode(t, err, codes.NotFound)
+	})
+}
+
+func TestUpdateRecordConcurrently(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		ctx := context.Background()
+
+		created := create(t, c, &weighttracker.Record{Weight: 80})
+
+		const updates = 10
+
+		var wg sync.WaitGroup
+		for i := range updates {
+			wg.Go(func() {
+				_, err := c.UpdateRecord(ctx, &weighttracker.UpdateRecordRequest{Record: &weighttracker.Record{Id: created.GetId(), Weight: float32(90 + i)}})
+				if err != nil {
+					t.Error(err)
+				}
+			})
+		}
+
+		wg.Wait()
+
+		// no update is lost and every one gets its own revision
+		resp, err := c.ListRecordRevisions(ctx, &weighttracker.ListRecordRevisionsRequest{RecordId: created.GetId()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		revs := resp.GetRevisions()
+		if len(revs) != updates+1 {
+			t.Fatalf("got %d revisions, want %d", len(revs), updates+1)
+		}
+
+		for i, rev := range revs {
+			if want := uint64(updates + 1 - i); rev.GetRevision() != want {
+				t.Fatalf("got revision %d at position %d, want %d", rev.GetRevision(), i, want)
+			}
+		}
+	})
+}
+
+func TestDeleteRecord(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		ctx := context.Background()
+
+		kept := create(t, c, &weighttracker.Record{Weight: 80})
+		deleted := create(t, c, &weighttracker.Record{Weight: 81})
+
+		if _, err := c.DeleteRecord(ctx, &weighttracker.DeleteRecordRequest{RecordId: deleted.GetId()}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := c.ReadRecord(ctx, &weighttracker.ReadRecordRequest{RecordId: deleted.GetId()})
+		checkCode(t, err, codes.NotFound)
+
+		_, err = c.DeleteRecord(ctx, &weighttracker.DeleteRecordRequest{RecordId: deleted.GetId()})
+		checkCode(t, err, codes.NotFound)
+
+		_, err = c.DeleteRecord(ctx, &weighttracker.DeleteRecordRequest{RecordId: 999})
+		checkCode(t, err, codes.NotFound)
+
+		records, _, err := list(t, c, &weighttracker.ListRecordsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(records) != 1 || records[0].GetId() != kept.GetId() {
+			t.Errorf("listed %v after deleting", records)
+		}
+	})
+}
+
+func TestListRecords(t *testing.T) {
+	at := func(day int) *timestamppb.Timestamp {
+		return timestamppb.New(time.Date(2024, 6, day, 8, 0, 0, 0, time.UTC))
+	}
+
+	tests := []struct {
+		name string
+		req  *weighttracker.ListRecordsRequest
+		want []float32
+	}{
+		{"everything", &weighttracker.ListRecordsRequest{}, []float32{80, 81, 82, 83, 84}},
+		{"exclusive range", &weighttracker.ListRecordsRequest{WeightedAtFrom: at(2), WeightedAtTo: at(4)}, []float32{82}},
+		{"inclusive range", &weighttracker.ListRecordsRequest{WeightedAtFrom: at(2), WeightedAtTo: at(4), WeightedAtFromInclusive: true, WeightedAtToInclusive: true}, []float32{81, 82, 83}},
+		{"open ended range", &weighttracker.ListRecordsRequest{WeightedAtFrom: at(4), WeightedAtFromInclusive: true}, []float32{83, 84}},
+		{"local dates", &weighttracker.ListRecordsRequest{LocalDateFrom: "2024-06-02", LocalDateTo: "2024-06-03"}, []float32{81, 82}},
+		{"weight range", &weighttracker.ListRecordsRequest{MinWeight: 81, MaxWeight: 83}, []float32{81, 82, 83}},
+		{"tags", &weighttracker.ListRecordsRequest{Tags: []string{"even", "gym"}}, []float32{80, 84}},
+		{"source", &weighttracker.ListRecordsRequest{Source: "app"}, []float32{84}},
+		{"filter", &weighttracker.ListRecordsRequest{Filter: `weight >= 82 AND NOT tags:gym`}, []float32{82, 83}},
+		{"filter with or", &weighttracker.ListRecordsRequest{Filter: `source = "app" OR weighted_at < "2024-06-02"`}, []float32{80, 84}},
+		{"latest", &weighttracker.ListRecordsRequest{Latest: true, Limit: 2}, []float32{84, 83}},
+		{"limit", &weighttracker.ListRecordsRequest{Limit: 2}, []float32{80, 81}},
+		{"nothing", &weighttracker.ListRecordsRequest{MinWeight: 100}, nil},
+	}
+
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		for i := range 5 {
+			rec := &weighttracker.Record{Weight: float32(80 + i), WeightedAt: at(i + 1), Tags: []string{"odd"}, Source: "scale"}
+			if i%2 == 0 {
+				rec.Tags = []string{"even"}
+			}
+			if i%4 == 0 {
+				rec.Tags = append(rec.Tags, "gym")
+			}
+			if i == 4 {
+				rec.Source = "app"
+			}
+
+			create(t, c, rec)
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				records, _, err := list(t, c, tt.req)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got := weights(records); !slices.Equal(got, tt.want) {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestListRecordsBatches(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		for i := range 5 {
+			create(t, c, &weighttracker.Record{Weight: float32(80 + i)})
+		}
+
+		for _, tt := range []struct {
+			batchSize int32
+			messages  int
+		}{{0, 5}, {1, 5}, {2, 3}, {5, 1}, {500, 1}} {
+			records, messages, err := list(t, c, &weighttracker.ListRecordsRequest{BatchSize: tt.batchSize})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(records) != 5 || messages != tt.messages {
+				t.Errorf("batch size %d: got %d records in %d messages, want 5 in %d", tt.batchSize, len(records), messages, tt.messages)
+			}
+		}
+
+		// older clients only read record
+		stream, err := c.ListRecords(context.Background(), &weighttracker.ListRecordsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.GetRecord().GetWeight() != 80 || resp.GetTotal() != 5 {
+			t.Errorf("got record %v out of %d", resp.GetRecord(), resp.GetTotal())
+		}
+	})
+}
+
+// pagingStore records the limit of every ListRecords call.
+type pagingStore struct {
+	store.Store
+
+	mu     sync.Mutex
+	limits []int
+}
+
+func (s *pagingStore) ListRecords(ctx context.Context, opts store.ListOptions) ([]store.Record, error) {
+	s.mu.Lock()
+	s.limits = append(s.limits, opts.Limit)
+	s.mu.Unlock()
+
+	return s.Store.ListRecords(ctx, opts)
+}
+
+func TestListRecordsPages(t *testing.T) {
+	for _, st := range stores {
+		t.Run(st.name, func(t *testing.T) {
+			ps := &pagingStore{Store: st.open(t)}
+			t.Cleanup(func() { ps.Close() })
+
+			clk := &clock{now: start}
+			c := weighttracker.NewWeightTrackerClient(wttest.NewServer(t, wttest.WithStore(ps), wttest.WithClock(clk.Now)).Conn())
+
+			// records weighted at the same time are ordered by id, even
+			// across pages
+			for i, hours := range []int{5, 3, 3, 3, 4, 1, 3} {
+				create(t, c, &weighttracker.Record{Weight: float32(80 + i), WeightedAt: timestamppb.New(start.Add(-time.Duration(hours) * time.Hour))})
+			}
+
+			tests := []struct {
+				name   string
+				req    *weighttracker.ListRecordsRequest
+				want   []float32
+				limits []int
+			}{
+				{"by id", &weighttracker.ListRecordsRequest{BatchSize: 2}, []float32{80, 81, 82, 83, 84, 85, 86}, []int{2, 2, 2, 2}},
+				{"latest", &weighttracker.ListRecordsRequest{BatchSize: 2, Latest: true}, []float32{85, 86, 83, 82, 81, 84, 80}, []int{2, 2, 2, 2}},
+				{"latest with limit", &weighttracker.ListRecordsRequest{BatchSize: 2, Latest: true, Limit: 5}, []float32{85, 86, 83, 82, 81}, []int{2, 2, 1}},
+				{"filtered", &weighttracker.ListRecordsRequest{BatchSize: 3, Filter: "weight != 83", Latest: true}, []float32{85, 86, 82, 81, 84, 80}, []int{3, 3, 3}},
+				{"one by one", &weighttracker.ListRecordsRequest{}, []float32{80, 81, 82, 83, 84, 85, 86}, []int{500}},
+			}
+
+			for _, tt := range tests {
+				ps.limits = nil
+
+				records, _, err := list(t, c, tt.req)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got := weights(records); !slices.Equal(got, tt.want) {
+					t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+				}
+
+				if !slices.Equal(ps.limits, tt.limits) {
+					t.Errorf("%v: read pages of %v, want %v", tt.name, ps.limits, tt.limits)
+				}
+			}
+		})
+	}
+}
+
+func TestListRecordsValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    *weighttracker.ListRecordsRequest
+		fields []string
+	}{
+		{"negative limit", &weighttracker.ListRecordsRequest{Limit: -1}, []string{"limit"}},
+		{"batch size too large", &weighttracker.ListRecordsRequest{BatchSize: 501}, []string{"batch_size"}},
+		{"negative weight", &weighttracker.ListRecordsRequest{MinWeight: -1}, []string{"min_weight"}},
+		{"inverted weights", &weighttracker.ListRecordsRequest{MinWeight: 90, MaxWeight: 80}, []string{"max_weight"}},
+		{"invalid local date", &weighttracker.ListRecordsRequest{LocalDateFrom: "15/06/2024"}, []string{"local_date_from"}},
+		{"unknown filter field", &weighttracker.ListRecordsRequest{Filter: "height > 2"}, []string{"filter"}},
+		{"invalid filter", &weighttracker.ListRecordsRequest{Filter: "weight >"}, []string{"filter"}},
+	}
+
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, _, err := list(t, c, tt.req)
+				checkViolations(t, err, tt.fields...)
+			})
+		}
+	})
+}
+
+func TestWatchRecordsFilters(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		for i := range 6 {
+			rec := &weighttracker.Record{Weight: float32(80 + i), Source: "scale"}
+			if i%2 == 0 {
+				rec.Tags = []string{"even"}
+			}
+			if i == 4 {
+				rec.Source = "app"
+			}
+
+			create(t, c, rec)
+		}
+
+		filters := &weighttracker.ListRecordsRequest{MinWeight: 81, Tags: []string{"even"}, Filter: `source = "scale"`}
+
+		listed, _, err := list(t, c, filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// replays every event since the server started
+		stream, err := c.WatchRecords(ctx, &weighttracker.WatchRecordsRequest{
+			ResumeAfter: uint64(start.UnixMicro()),
+			MinWeight:   filters.MinWeight,
+			Tags:        filters.Tags,
+			Filter:      filters.Filter,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the last record matches, so every event before it has been
+		// received once it arrives
+		last := create(t, c, &weighttracker.Record{Weight: 90, Tags: []string{"even"}, Source: "scale"})
+		listed = append(listed, last)
+
+		var watched []*weighttracker.Record
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			watched = append(watched, resp.GetRecord())
+			if resp.GetRecord().GetId() == last.GetId() {
+				break
+			}
+		}
+
+		if got, want := weights(watched), weights(listed); !slices.Equal(got, want) {
+			t.Errorf("watched %v, listed %v", got, want)
+		}
+
+		stream, err = c.WatchRecords(context.Background(), &weighttracker.WatchRecordsRequest{Filter: "weight >"})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		checkViolations(t, err, "filter")
+	})
+}
+
+// syncToken returns the token SyncRecords would return for the changes
+// after t.
+func syncToken(t time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("v1:" + strconv.FormatInt(t.UnixNano(), 10)))
+}
+
+func TestSyncRecordsDeleted(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		ctx := context.Background()
+
+		created := create(t, c, &weighttracker.Record{Weight: 80})
+
+		resp, err := c.SyncRecords(ctx, &weighttracker.SyncRecordsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(resp.GetChanges()) != 1 || resp.GetChanges()[0].GetDeleted() {
+			t.Fatalf("got changes %v on the first sync", resp.GetChanges())
+		}
+
+		if _, err = c.DeleteRecord(ctx, &weighttracker.DeleteRecordRequest{RecordId: created.GetId()}); err != nil {
+			t.Fatal(err)
+		}
+
+		// sync from right after the record was created, instead of the token
+		// returned, which overlaps with the last few seconds
+		resp, err = c.SyncRecords(ctx, &weighttracker.SyncRecordsRequest{SyncToken: syncToken(created.GetUpdatedAt().AsTime())})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		changes := resp.GetChanges()
+		if len(changes) != 1 || changes[0].GetRecord().GetId() != created.GetId() || !changes[0].GetDeleted() {
+			t.Fatalf("got changes %v after deleting the record, want it deleted", changes)
+		}
+	})
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	webhooks := wttest.WithWebhooks(config.WebhooksConfig{Subscriptions: []config.WebhookConfig{
+		{Name: "all", URL: "http://127.0.0.1:1/all"},
+		{Name: "deletes", URL: "http://127.0.0.1:1/deletes", Events: []string{"deleted"}},
+	}})
+
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		ctx := context.Background()
+
+		created := create(t, c, &weighttracker.Record{Weight: 80})
+
+		if _, err := c.UpdateRecord(ctx, &weighttracker.UpdateRecordRequest{Record: &weighttracker.Record{Id: created.GetId(), Weight: 81}}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := c.DeleteRecord(ctx, &weighttracker.DeleteRecordRequest{RecordId: created.GetId()}); err != nil {
+			t.Fatal(err)
+		}
+
+		// queued along with every change, newest first
+		resp, err := c.ListWebhookDeliveries(ctx, &weighttracker.ListWebhookDeliveriesRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, d := range resp.GetDeliveries() {
+			if d.GetRecordId() != created.GetId() || d.GetStatus() != weighttracker.WebhookDelivery_PENDING {
+				t.Errorf("got delivery %v", d)
+			}
+
+			got = append(got, d.GetWebhook()+" "+d.GetEvent())
+		}
+
+		want := []string{"deletes record.deleted", "all record.deleted", "all record.updated", "all record.created"}
+		if !slices.Equal(got, want) {
+			t.Errorf("got deliveries %v, want %v", got, want)
+		}
+	}, webhooks)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+
+	"github.com/0gener/go-weight-tracker/server/auth"
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"github.com/0gener/go-weight-tracker/server/rpcerror"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/0gener/go-weight-tracker/server/validation"
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) GetSettings(ctx context.Context, req *weighttracker.GetSettingsRequest) (*weighttracker.GetSettingsResponse, error) {
+	logging.FromContext(ctx).Debug("GetSettings", "request", req)
+
+	settings, err := s.store.ReadSettings(ctx, auth.PrincipalFromContext(ctx))
+	if err != nil {
+		return nil, rpcerror.FromStore(err, "settings", 0)
+	}
+
+	return &weighttracker.GetSettingsResponse{
+		Settings: settingsToPb(settings),
+	}, nil
+}
+
+func (s *Server) UpdateSettings(ctx context.Context, req *weighttracker.UpdateSettingsRequest) (*weighttracker.UpdateSettingsResponse, error) {
+	logging.FromContext(ctx).Debug("UpdateSettings", "request", req)
+
+	if req.GetSettings() == nil {
+		return nil, &validation.Error{Violations: []validation.FieldViolation{{Field: "settings", Description: "is required"}}}
+	}
+
+	if err := validation.ValidateTimeZone("settings.time_zone", req.GetSettings().GetTimeZone()); err != nil {
+		return nil, err
+	}
+
+	settings := &store.UserSettings{
+		Principal: auth.PrincipalFromContext(ctx),
+		TimeZone:  req.GetSettings().GetTimeZone(),
+	}
+
+	if err := s.store.SaveSettings(ctx, settings); err != nil {
+		return nil, rpcerror.FromStore(err, "settings", 0)
+	}
+
+	return &weighttracker.UpdateSettingsResponse{
+		Settings: settingsToPb(settings),
+	}, nil
+}
+
+// defaultTimeZone returns the zone records created by the caller default to.
+func (s *Server) defaultTimeZone(ctx context.Context) (string, error) {
+	settings, err := s.store.ReadSettings(ctx, auth.PrincipalFromContext(ctx))
+	if err != nil {
+		return "", err
+	}
+
+	if settings.TimeZone == "" {
+		return "UTC", nil
+	}
+
+	return settings.TimeZone, nil
+}
+
+// updateTimeZone applies the time_zone or utc_offset of pb, if either is
+// set, to rec and recomputes the offset of its zone at its weighted_at.
+func updateTimeZone(rec *store.Record, pb *weighttracker.Record) error {
+	zone, offset := rec.TimeZone, rec.UTCOffset
+
+	switch {
+	case pb.GetTimeZone() != "":
+		zone, offset = pb.GetTimeZone(), 0
+	case pb.GetUtcOffset() != 0:
+		zone, offset = "", pb.GetUtcOffset()
+	}
+
+	return rec.SetTimeZone(zone, offset)
+}
+
+func settingsToPb(settings *store.UserSettings) *weighttracker.Settings {
+	pb := &weighttracker.Settings{
+		TimeZone: settings.TimeZone,
+	}
+
+	if !settings.UpdatedAt.IsZero() {
+		pb.UpdatedAt = timestamppb.New(settings.UpdatedAt)
+	}
+
+	return pb
+}
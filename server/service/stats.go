@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/auth"
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"github.com/0gener/go-weight-tracker/server/rpcerror"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/0gener/go-weight-tracker/server/validation"
+	"github.com/0gener/go-weight-tracker/weighttracker"
+)
+
+func (s *Server) ListDailySummaries(ctx context.Context, req *weighttracker.ListDailySummariesRequest) (*weighttracker.ListDailySummariesResponse, error) {
+	logging.FromContext(ctx).Debug("ListDailySummaries", "request", req)
+
+	if violations := validateLocalDates(req.GetLocalDateFrom(), req.GetLocalDateTo()); len(violations) > 0 {
+		return nil, &validation.Error{Violations: violations}
+	}
+
+	type day struct {
+		summary *weighttracker.DailySummary
+		sum     float64
+		last    time.Time
+	}
+
+	// records only hold their current revision, they are read a page at a
+	// time so only the days are held in memory
+	page := store.ListOptions{
+		Owner:         auth.PrincipalFromContext(ctx),
+		LocalDateFrom: req.GetLocalDateFrom(),
+		LocalDateTo:   req.GetLocalDateTo(),
+		Limit:         maxListBatchSize,
+	}
+
+	days := map[string]*day{}
+	for {
+		records, err := s.store.ListRecords(ctx, page)
+		if err != nil {
+			return nil, rpcerror.FromStore(err, "record", 0)
+		}
+
+		for _, rec := range records {
+			date := rec.LocalDate()
+
+			d, ok := days[date]
+			if !ok {
+				d = &day{summary: &weighttracker.DailySummary{
+					LocalDate: date,
+					MinWeight: rec.Weight,
+					MaxWeight: rec.Weight,
+				}}
+				days[date] = d
+			}
+
+			d.summary.Count++
+			d.summary.MinWeight = min(d.summary.MinWeight, rec.Weight)
+			d.summary.MaxWeight = max(d.summary.MaxWeight, rec.Weight)
+			d.sum += float64(rec.Weight)
+
+			if !rec.WeightedAt.Before(d.last) {
+				d.summary.LastWeight = rec.Weight
+				d.last = rec.WeightedAt
+			}
+		}
+
+		if len(records) < page.Limit {
+			break
+		}
+
+		page.After = &records[len(records)-1]
+	}
+
+	resp := &weighttracker.ListDailySummariesResponse{}
+	for _, d := range days {
+		d.summary.AverageWeight = float32(d.sum / float64(d.summary.Count))
+		resp.Days = append(resp.Days, d.summary)
+	}
+
+	sort.Slice(resp.Days, func(i, j int) bool { return resp.Days[i].LocalDate < resp.Days[j].LocalDate })
+
+	return resp, nil
+}
+
+// validateLocalDates checks an inclusive range of local dates, either of
+// which can be empty.
+func validateLocalDates(from, to string) []validation.FieldViolation {
+	var violations []validation.FieldViolation
+
+	for _, date := range []struct{ field, value string }{{"local_date_from", from}, {"local_date_to", to}} {
+		if date.value == "" {
+			continue
+		}
+
+		if _, err := time.Parse(time.DateOnly, date.value); err != nil {
+			violations = append(violations, validation.FieldViolation{Field: date.field, Description: "must be a date formatted as YYYY-MM-DD"})
+		}
+	}
+
+	if len(violations) == 0 && from != "" && to != "" && from > to {
+		violations = append(violations, validation.FieldViolation{Field: "local_date_to", Description: "must not be before local_date_from"})
+	}
+
+	return violations
+}
@@ -0,0 +1,114 @@
+package service_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"github.com/0gener/go-weight-tracker/wttest"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestListDailySummaries(t *testing.T) {
+	run(t, func(t *testing.T, c weighttracker.WeightTrackerClient, clk *clock) {
+		ctx := context.Background()
+		day := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+		clk.Advance(72 * time.Hour)
+
+		for _, rec := range []*weighttracker.Record{
+			{Weight: 80, WeightedAt: timestamppb.New(day.Add(7 * time.Hour)), TimeZone: "UTC"},
+			// 22:00 on the 15th four hours behind UTC, and the latest of the day
+			{Weight: 81, WeightedAt: timestamppb.New(day.Add(26 * time.Hour)), UtcOffset: -4 * 3600},
+			// 00:30 on the 16th in Lisbon, and the latest of that day
+			{Weight: 82, WeightedAt: timestamppb.New(day.Add(23*time.Hour + 30*time.Minute)), TimeZone: "Europe/Lisbon"},
+			// 01:00 on the 16th in Tokyo, later than in Lisbon but weighted before
+			{Weight: 79, WeightedAt: timestamppb.New(day.Add(16 * time.Hour)), TimeZone: "Asia/Tokyo"},
+			{Weight: 83, WeightedAt: timestamppb.New(day.AddDate(0, 0, 2)), TimeZone: "UTC"},
+		} {
+			create(t, c, rec)
+		}
+
+		want := []*weighttracker.DailySummary{
+			{LocalDate: "2024-06-15", Count: 2, MinWeight: 80, MaxWeight: 81, AverageWeight: 80.5, LastWeight: 81},
+			{LocalDate: "2024-06-16", Count: 2, MinWeight: 79, MaxWeight: 82, AverageWeight: 80.5, LastWeight: 82},
+			{LocalDate: "2024-06-17", Count: 1, MinWeight: 83, MaxWeight: 83, AverageWeight: 83, LastWeight: 83},
+		}
+
+		tests := []struct {
+			name string
+			req  *weighttracker.ListDailySummariesRequest
+			want []*weighttracker.DailySummary
+		}{
+			{"every day", &weighttracker.ListDailySummariesRequest{}, want},
+			{"range", &weighttracker.ListDailySummariesRequest{LocalDateFrom: "2024-06-16", LocalDateTo: "2024-06-17"}, want[1:]},
+			{"single day", &weighttracker.ListDailySummariesRequest{LocalDateFrom: "2024-06-15", LocalDateTo: "2024-06-15"}, want[:1]},
+			{"no records", &weighttracker.ListDailySummariesRequest{LocalDateFrom: "2024-07-01"}, nil},
+		}
+
+		for _, tt := range tests {
+			resp, err := c.ListDailySummaries(ctx, tt.req)
+			if err != nil {
+				t.Fatalf("%v: %v", tt.name, err)
+			}
+
+			if !slices.EqualFunc(resp.GetDays(), tt.want, func(a, b *weighttracker.DailySummary) bool { return proto.Equal(a, b) }) {
+				t.Errorf("%v: got days %v, want %v", tt.name, resp.GetDays(), tt.want)
+			}
+		}
+	})
+}
+
+func TestListDailySummariesPages(t *testing.T) {
+	for _, st := range stores {
+		t.Run(st.name, func(t *testing.T) {
+			ps := &pagingStore{Store: st.open(t)}
+			t.Cleanup(func() { ps.Close() })
+
+			ctx := context.Background()
+
+			// one more than a page, two a day
+			const n = 501
+			for i := range n {
+				rec := store.Record{Weight: 80, WeightedAt: start.Add(-time.Duration(i) * 12 * time.Hour), TimeZone: "UTC"}
+				if i == n-1 {
+					rec.Weight = 90
+				}
+
+				if err := ps.CreateRecord(ctx, &rec); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c := weighttracker.NewWeightTrackerClient(wttest.NewServer(t, wttest.WithStore(ps), wttest.WithClock(func() time.Time { return start })).Conn())
+
+			resp, err := c.ListDailySummaries(ctx, &weighttracker.ListDailySummariesRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(ps.limits, []int{500, 500}) {
+				t.Errorf("read pages of %v, want two of 500", ps.limits)
+			}
+
+			days := resp.GetDays()
+
+			count := 0
+			for _, d := range days {
+				count += int(d.GetCount())
+			}
+
+			if len(days) != (n+1)/2 || count != n {
+				t.Errorf("got %d records over %d days, want %d over %d", count, len(days), n, (n+1)/2)
+			}
+
+			// the last record created is alone on the second page and the first day
+			if first := days[0]; first.GetCount() != 1 || first.GetLastWeight() != 90 {
+				t.Errorf("got summary %v for the first day, want the record of the second page in it", first)
+			}
+		})
+	}
+}
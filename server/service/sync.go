@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/auth"
+	"github.com/0gener/go-weight-tracker/server/events"
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"github.com/0gener/go-weight-tracker/server/rpcerror"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/0gener/go-weight-tracker/server/validation"
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxSyncChanges bounds how many local changes a single sync can push.
+const maxSyncChanges = 500
+
+// syncOverlap is how far back from the time changes are read the next sync
+// starts. It covers transactions that were still in flight while reading,
+// whose updated_at is earlier than their commit, and databases that store
+// timestamps with millisecond precision.
+const syncOverlap = 5 * time.Second
+
+const syncTokenPrefix = "v1:"
+
+// errConflict aborts an update that lost to a more recent server change.
+var errConflict = errors.New("record changed on the server after the local change")
+
+func (s *Server) SyncRecords(ctx context.Context, req *weighttracker.SyncRecordsRequest) (*weighttracker.SyncRecordsResponse, error) {
+	logging.FromContext(ctx).Debug("SyncRecords", "request", req)
+
+	since, err := decodeSyncToken(req.GetSyncToken())
+	if err != nil {
+		return nil, &validation.Error{Violations: []validation.FieldViolation{{Field: "sync_token", Description: err.Error()}}}
+	}
+
+	if len(req.GetChanges()) > maxSyncChanges {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d changes can be pushed at once", maxSyncChanges)
+	}
+
+	resp := &weighttracker.SyncRecordsResponse{}
+
+	for i, change := range req.GetChanges() {
+		result, err := s.applyChange(ctx, fmt.Sprintf("changes[%d]", i), change)
+		if err != nil {
+			return nil, rpcerror.FromStore(err, "record", change.GetRecord().GetId())
+		}
+
+		resp.Results = append(resp.Results, result)
+	}
+
+	readAt := s.now().UTC()
+
+	records, err := s.store.ListChanges(ctx, auth.PrincipalFromContext(ctx), since)
+	if err != nil {
+		return nil, rpcerror.FromStore(err, "record", 0)
+	}
+
+	for _, record := range records {
+		resp.Changes = append(resp.Changes, recordChangeToPb(record))
+	}
+
+	next := readAt.Add(-syncOverlap)
+	if next.Before(since) {
+		next = since
+	}
+
+	resp.SyncToken = encodeSyncToken(next)
+
+	return resp, nil
+}
+
+// applyChange applies a single local change. Conflicts and invalid changes
+// are reported in the result, only store failures are returned as errors.
+func (s *Server) applyChange(ctx context.Context, field string, change *weighttracker.LocalChange) (*weighttracker.ChangeResult, error) {
+	rec := change.GetRecord()
+
+	switch {
+	case rec.GetId() == 0 && change.GetDeleted():
+		return invalidChange(fmt.Sprintf("%v.record.id: required to delete a record", field)), nil
+	case rec.GetId() == 0:
+		return s.applyCreate(ctx, field, rec)
+	case change.GetModifiedAt() == nil || change.GetModifiedAt().CheckValid() != nil:
+		return invalidChange(fmt.Sprintf("%v.modified_at: required to update or delete a record", field)), nil
+	}
+
+	if !change.GetDeleted() {
+		if err := s.validator.ValidateRecordUpdate(field+".record", rec); err != nil {
+			return invalidChange(err.Error()), nil
+		}
+	}
+
+	modifiedAt := change.GetModifiedAt().AsTime()
+
+	var prev, current store.Record
+
+	record, err := s.store.UpdateRecord(s.changing(ctx), auth.PrincipalFromContext(ctx), rec.GetId(), func(r *store.Record) error {
+		prev = *r
+
+		if r.UpdatedAt.After(modifiedAt) {
+			current = *r
+			return errConflict
+		}
+
+		if change.GetDeleted() {
+			now := s.now().UTC()
+			r.DeletedAt = &now
+
+			return nil
+		}
+
+		return applyUpdate(r, rec)
+	})
+
+	switch {
+	case errors.Is(err, errConflict):
+		return &weighttracker.ChangeResult{
+			Status:  weighttracker.ChangeResult_CONFLICT,
+			Record:  recordChangeToPb(current),
+			Message: err.Error(),
+		}, nil
+	case errors.Is(err, store.ErrNotFound) && change.GetDeleted():
+		// already deleted, deleting is idempotent
+		return &weighttracker.ChangeResult{
+			Status: weighttracker.ChangeResult_APPLIED,
+			Record: &weighttracker.RecordChange{Record: &weighttracker.Record{Id: rec.GetId()}, Deleted: true},
+		}, nil
+	case errors.Is(err, store.ErrNotFound):
+		return &weighttracker.ChangeResult{
+			Status:  weighttracker.ChangeResult_CONFLICT,
+			Record:  &weighttracker.RecordChange{Record: &weighttracker.Record{Id: rec.GetId()}, Deleted: true},
+			Message: "record was deleted",
+		}, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if change.GetDeleted() {
+		s.recordChanged(events.Deleted, prev, &prev)
+	} else {
+		s.recordChanged(events.Updated, *record, &prev)
+	}
+
+	return &weighttracker.ChangeResult{
+		Status: weighttracker.ChangeResult_APPLIED,
+		Record: recordChangeToPb(*record),
+	}, nil
+}
+
+func (s *Server) applyCreate(ctx context.Context, field string, rec *weighttracker.Record) (*weighttracker.ChangeResult, error) {
+	if err := s.validator.ValidateNewRecord(field+".record", rec); err != nil {
+		return invalidChange(err.Error()), nil
+	}
+
+	record, err := s.newRecord(ctx, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.CreateRecord(s.changing(ctx), &record)
+	if errors.Is(err, store.ErrAlreadyExists) && record.ClientID != "" {
+		// created by a previous attempt of this sync
+		existing, err := s.store.ReadRecordByClientID(ctx, record.Owner, record.ClientID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &weighttracker.ChangeResult{
+			Status: weighttracker.ChangeResult_APPLIED,
+			Record: recordChangeToPb(*existing),
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordChanged(events.Created, record, nil)
+
+	return &weighttracker.ChangeResult{
+		Status: weighttracker.ChangeResult_APPLIED,
+		Record: recordChangeToPb(record),
+	}, nil
+}
+
+func invalidChange(msg string) *weighttracker.ChangeResult {
+	return &weighttracker.ChangeResult{
+		Status:  weighttracker.ChangeResult_INVALID,
+		Message: msg,
+	}
+}
+
+func recordChangeToPb(rec store.Record) *weighttracker.RecordChange {
+	return &weighttracker.RecordChange{
+		Record:  dataToRecordPb(rec),
+		Deleted: rec.DeletedAt != nil,
+	}
+}
+
+// encodeSyncToken returns an opaque token for the changes after t.
+func encodeSyncToken(t time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(syncTokenPrefix + strconv.FormatInt(t.UnixNano(), 10)))
+}
+
+// decodeSyncToken returns the time encoded by encodeSyncToken, or the zero
+// time for an empty token.
+func decodeSyncToken(token string) (time.Time, error) {
+	if token == "" {
+		return time.Time{}, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(b), syncTokenPrefix) {
+		return time.Time{}, errors.New("invalid sync token")
+	}
+
+	nanos, err := strconv.ParseInt(strings.TrimPrefix(string(b), syncTokenPrefix), 10, 64)
+	if err != nil {
+		return time.Time{}, errors.New("invalid sync token")
+	}
+
+	return time.Unix(0, nanos).UTC(), nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/0gener/go-weight-tracker/server/auth"
+	"github.com/0gener/go-weight-tracker/server/events"
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"github.com/0gener/go-weight-tracker/server/validation"
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) WatchRecords(req *weighttracker.WatchRecordsRequest, stream weighttracker.WeightTracker_WatchRecordsServer) error {
+	logging.FromContext(stream.Context()).Debug("WatchRecords", "request", req)
+
+	opts, violations := filterOptions(req)
+	if len(violations) > 0 {
+		return &validation.Error{Violations: violations}
+	}
+
+	// only the changes to the records of the caller
+	opts.Owner = auth.PrincipalFromContext(stream.Context())
+
+	sub, err := s.events.Subscribe(req.GetResumeAfter())
+	if errors.Is(err, events.ErrResumeUnavailable) {
+		return status.Errorf(codes.OutOfRange, "events after sequence %d are no longer available, list records again", req.GetResumeAfter())
+	}
+	if err != nil {
+		return watchError(err)
+	}
+	defer sub.Close()
+
+	for {
+		select {
+		case e := <-sub.Events():
+			if !opts.Matches(e.Record) && (e.Previous == nil || !opts.Matches(*e.Previous)) {
+				continue
+			}
+
+			err := stream.Send(&weighttracker.WatchRecordsResponse{
+				Sequence:   e.Sequence,
+				Type:       eventTypeToPb(e.Type),
+				Record:     dataToRecordPb(e.Record),
+				OccurredAt: timestamppb.New(e.OccurredAt),
+			})
+			if err != nil {
+				return err
+			}
+		case <-sub.Done():
+			return watchError(sub.Err())
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func watchError(err error) error {
+	switch {
+	case errors.Is(err, events.ErrSlowSubscriber):
+		return status.Error(codes.Aborted, "client fell behind, resume from the last sequence received")
+	case errors.Is(err, events.ErrClosed):
+		return status.Error(codes.Unavailable, "server is shutting down, resume from the last sequence received")
+	}
+
+	return err
+}
+
+func eventTypeToPb(t events.Type) weighttracker.WatchRecordsResponse_EventType {
+	switch t {
+	case events.Created:
+		return weighttracker.WatchRecordsResponse_CREATED
+	case events.Updated:
+		return weighttracker.WatchRecordsResponse_UPDATED
+	case events.Deleted:
+		return weighttracker.WatchRecordsResponse_DELETED
+	default:
+		return weighttracker.WatchRecordsResponse_EVENT_TYPE_UNSPECIFIED
+	}
+}
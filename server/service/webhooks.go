@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"strconv"
+
+	"github.com/0gener/go-weight-tracker/server/auth"
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"github.com/0gener/go-weight-tracker/server/rpcerror"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/0gener/go-weight-tracker/server/validation"
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+func (s *Server) ListWebhookDeliveries(ctx context.Context, req *weighttracker.ListWebhookDeliveriesRequest) (*weighttracker.ListWebhookDeliveriesResponse, error) {
+	logging.FromContext(ctx).Debug("ListWebhookDeliveries", "request", req)
+
+	var violations []validation.FieldViolation
+
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize < 0 || pageSize > maxPageSize:
+		violations = append(violations, validation.FieldViolation{Field: "page_size", Description: "must be between 0 and 500"})
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	}
+
+	beforeID, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		violations = append(violations, validation.FieldViolation{Field: "page_token", Description: "invalid page token"})
+	}
+
+	if len(violations) > 0 {
+		return nil, &validation.Error{Violations: violations}
+	}
+
+	// one more than requested tells whether there is another page
+	deliveries, err := s.store.ListDeliveries(ctx, store.DeliveryListOptions{
+		Owner:    auth.PrincipalFromContext(ctx),
+		Webhook:  req.GetWebhook(),
+		Status:   deliveryStatusFromPb(req.GetStatus()),
+		BeforeID: beforeID,
+		Limit:    pageSize + 1,
+	})
+	if err != nil {
+		return nil, rpcerror.FromStore(err, "webhook delivery", 0)
+	}
+
+	resp := &weighttracker.ListWebhookDeliveriesResponse{}
+
+	if len(deliveries) > pageSize {
+		deliveries = deliveries[:pageSize]
+		resp.NextPageToken = encodePageToken(deliveries[pageSize-1].ID)
+	}
+
+	for _, d := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, deliveryToPb(d))
+	}
+
+	return resp, nil
+}
+
+// encodePageToken returns an opaque token for the page after id.
+func encodePageToken(id uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(id, 10)))
+}
+
+func decodePageToken(token string) (uint64, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(string(b), 10, 64)
+}
+
+func deliveryStatusFromPb(status weighttracker.WebhookDelivery_Status) store.DeliveryStatus {
+	switch status {
+	case weighttracker.WebhookDelivery_PENDING:
+		return store.DeliveryPending
+	case weighttracker.WebhookDelivery_SUCCEEDED:
+		return store.DeliverySucceeded
+	case weighttracker.WebhookDelivery_FAILED:
+		return store.DeliveryFailed
+	default:
+		return ""
+	}
+}
+
+func deliveryToPb(d store.Delivery) *weighttracker.WebhookDelivery {
+	status := weighttracker.WebhookDelivery_STATUS_UNSPECIFIED
+	switch d.Status {
+	case store.DeliveryPending:
+		status = weighttracker.WebhookDelivery_PENDING
+	case store.DeliverySucceeded:
+		status = weighttracker.WebhookDelivery_SUCCEEDED
+	case store.DeliveryFailed:
+		status = weighttracker.WebhookDelivery_FAILED
+	}
+
+	return &weighttracker.WebhookDelivery{
+		Id:             d.ID,
+		Webhook:        d.Webhook,
+		Event:          d.Event,
+		RecordId:       d.RecordID,
+		Status:         status,
+		Attempts:       int32(d.Attempts),
+		NextAttemptAt:  timestamppb.New(d.NextAttemptAt),
+		LastError:      d.LastError,
+		LastStatusCode: int32(d.LastStatusCode),
+		CreatedAt:      timestamppb.New(d.CreatedAt),
+		UpdatedAt:      timestamppb.New(d.UpdatedAt),
+	}
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Account is a user that logs in with a password.
+type Account struct {
+	ID           uint64
+	Username     string // unique, lowercase
+	PasswordHash string // bcrypt
+	CreatedAt    time.Time
+}
+
+// RefreshToken is a long lived token exchanged for new access tokens. Only a
+// hash of the token is stored.
+type RefreshToken struct {
+	ID        uint64
+	AccountID uint64
+	Hash      string // hex encoded SHA-256 of the token
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// AccountStore persists accounts and their refresh tokens.
+type AccountStore interface {
+	// CreateAccount stores acc and fills in its ID and CreatedAt. It
+	// returns ErrAlreadyExists if the username is taken.
+	CreateAccount(ctx context.Context, acc *Account) error
+
+	// ReadAccount returns the account with the given id, or ErrNotFound.
+	ReadAccount(ctx context.Context, id uint64) (*Account, error)
+
+	// ReadAccountByUsername returns the account with the given username, or
+	// ErrNotFound.
+	ReadAccountByUsername(ctx context.Context, username string) (*Account, error)
+
+	// CreateRefreshToken stores t and fills in its ID and CreatedAt.
+	CreateRefreshToken(ctx context.Context, t *RefreshToken) error
+
+	// ReadRefreshToken returns the token with the given hash, even if it
+	// has expired or been revoked, or ErrNotFound.
+	ReadRefreshToken(ctx context.Context, hash string) (*RefreshToken, error)
+
+	// RevokeRefreshToken revokes the token with the given hash at the given
+	// time. It returns ErrNotFound if there is no such token or it was
+	// already revoked, so only one of concurrent callers succeeds.
+	RevokeRefreshToken(ctx context.Context, hash string, at time.Time) error
+
+	// RevokeRefreshTokens revokes every token of the account that has not
+	// been revoked yet.
+	RevokeRefreshTokens(ctx context.Context, accountID uint64, at time.Time) error
+
+	// DeleteRefreshTokens deletes the tokens that expired before the given
+	// time and returns how many there were.
+	DeleteRefreshTokens(ctx context.Context, expiredBefore time.Time) (int, error)
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Audit actions.
+const (
+	AuditCreated = "created"
+	AuditUpdated = "updated"
+	AuditDeleted = "deleted"
+)
+
+// AuditEvent is an entry in the append-only audit log. Stores add one for
+// every change to a record, along with the change itself.
+type AuditEvent struct {
+	ID        uint64
+	Action    string // AuditCreated, AuditUpdated or AuditDeleted
+	RecordID  uint64
+	Owner     string  // of the record
+	Before    *Record // nil for creates
+	After     *Record // nil for deletes
+	Principal string
+	Method    string
+	RequestID string
+	CreatedAt time.Time
+}
+
+// Actor describes who makes a change and how. It is recorded with the audit
+// event of every change made with a context carrying it.
+type Actor struct {
+	Principal string
+	Method    string
+	RequestID string
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor carried by ctx, or the zero Actor.
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
+
+// NewAuditEvent returns the audit event for a change from before to after,
+// either of which can be nil, made by the actor carried by ctx.
+func NewAuditEvent(ctx context.Context, before, after *Record) *AuditEvent {
+	actor := ActorFromContext(ctx)
+
+	e := &AuditEvent{
+		Action:    AuditUpdated,
+		Before:    before,
+		After:     after,
+		Principal: actor.Principal,
+		Method:    actor.Method,
+		RequestID: actor.RequestID,
+	}
+
+	switch {
+	case before == nil:
+		e.Action = AuditCreated
+	case after == nil || (before.DeletedAt == nil && after.DeletedAt != nil):
+		e.Action = AuditDeleted
+		e.After = nil
+	}
+
+	if after != nil {
+		e.RecordID, e.Owner = after.ID, after.Owner
+	} else {
+		e.RecordID, e.Owner = before.ID, before.Owner
+	}
+
+	return e
+}
+
+// AuditListOptions filters the events returned by ListAuditEvents. Zero
+// values are ignored, except for Owner.
+type AuditListOptions struct {
+	Owner     string // only events about the records of this owner, even if empty
+	RecordID  uint64
+	Principal string
+	Action    string
+	From      *time.Time // inclusive
+	To        *time.Time // exclusive
+	BeforeID  uint64     // only events with a lower id, for paging
+	Limit     int
+}
+
+// AuditStore reads the audit log. Events are only ever added by the stores
+// themselves.
+type AuditStore interface {
+	// ListAuditEvents returns the events matching opts, newest first.
+	ListAuditEvents(ctx context.Context, opts AuditListOptions) ([]AuditEvent, error)
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// DeliveryStatus is the state of a webhook delivery.
+type DeliveryStatus string
+
+// Delivery statuses.
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed" // gave up after the last attempt
+)
+
+// Delivery is a webhook call, queued or done.
+type Delivery struct {
+	ID             uint64
+	Webhook        string // name of the subscription
+	Event          string // e.g. record.created
+	RecordID       uint64
+	Owner          string // of the record
+	Payload        []byte
+	Status         DeliveryStatus
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastError      string
+	LastStatusCode int
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// DeliveryListOptions filters the deliveries returned by ListDeliveries.
+// Zero values are ignored, except for Owner.
+type DeliveryListOptions struct {
+	Owner    string // only deliveries about the records of this owner, even if empty
+	Webhook  string
+	Status   DeliveryStatus
+	BeforeID uint64 // only deliveries with a lower id, for paging
+	Limit    int
+}
+
+// DeliveryFunc returns the webhook deliveries to queue for the change to a
+// record described by e, whose ID and CreatedAt are already set.
+type DeliveryFunc func(e *AuditEvent) ([]*Delivery, error)
+
+type deliveryFuncKey struct{}
+
+// WithDeliveries returns a copy of ctx carrying f. The changes to records
+// made with it queue the deliveries returned by f along with the change, so
+// either both are stored or neither is.
+func WithDeliveries(ctx context.Context, f DeliveryFunc) context.Context {
+	return context.WithValue(ctx, deliveryFuncKey{}, f)
+}
+
+// DeliveriesFor returns the deliveries to queue for e, as returned by the
+// DeliveryFunc carried by ctx, if any.
+func DeliveriesFor(ctx context.Context, e *AuditEvent) ([]*Delivery, error) {
+	f, _ := ctx.Value(deliveryFuncKey{}).(DeliveryFunc)
+	if f == nil {
+		return nil, nil
+	}
+
+	return f(e)
+}
+
+// DeliveryStore persists the webhook delivery queue and log. Deliveries are
+// only ever queued by the RecordStore methods, see WithDeliveries.
+type DeliveryStore interface {
+	// ClaimDeliveries returns up to limit pending deliveries due at now,
+	// oldest first, and pushes their next attempt back to until so they are
+	// not claimed again, by this or another server, while being sent.
+	ClaimDeliveries(ctx context.Context, now, until time.Time, limit int) ([]Delivery, error)
+
+	// SaveDelivery stores the outcome of an attempt.
+	SaveDelivery(ctx context.Context, d *Delivery) error
+
+	// ListDeliveries returns the deliveries matching opts, newest first.
+	ListDeliveries(ctx context.Context, opts DeliveryListOptions) ([]Delivery, error)
+
+	// DeleteDeliveries deletes the deliveries that succeeded or failed
+	// before the given time and returns how many there were.
+	DeleteDeliveries(ctx context.Context, finishedBefore time.Time) (int, error)
+}
@@ -0,0 +1,310 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+)
+
+const (
+	kindAccount              = "account"
+	kindRefreshToken         = "refresh_token"
+	kindRefreshTokensDeleted = "refresh_tokens_deleted"
+)
+
+// accountData is the log representation of a store.Account.
+type accountData struct {
+	ID           uint64    `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// refreshTokenData is the log representation of a store.RefreshToken.
+type refreshTokenData struct {
+	ID        uint64     `json:"id"`
+	AccountID uint64     `json:"account_id"`
+	Hash      string     `json:"hash"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func encodeAccount(acc *store.Account) (entry, error) {
+	data, err := json.Marshal(accountData(*acc))
+	if err != nil {
+		return entry{}, err
+	}
+
+	return entry{Kind: kindAccount, Data: data}, nil
+}
+
+func decodeAccount(data json.RawMessage) (*store.Account, error) {
+	var d accountData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+
+	acc := store.Account(d)
+
+	return &acc, nil
+}
+
+func encodeRefreshToken(t *store.RefreshToken) (entry, error) {
+	data, err := json.Marshal(refreshTokenData(*t))
+	if err != nil {
+		return entry{}, err
+	}
+
+	return entry{Kind: kindRefreshToken, Data: data}, nil
+}
+
+func decodeRefreshToken(data json.RawMessage) (*store.RefreshToken, error) {
+	var d refreshTokenData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+
+	t := store.RefreshToken(d)
+
+	return &t, nil
+}
+
+func decodeRefreshTokensDeleted(data json.RawMessage) ([]string, error) {
+	var hashes []string
+	err := json.Unmarshal(data, &hashes)
+
+	return hashes, err
+}
+
+// CreateAccount implements store.Store.
+func (s *Store) CreateAccount(ctx context.Context, acc *store.Account) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.usernames[acc.Username]; ok {
+		return store.ErrAlreadyExists
+	}
+
+	created := *acc
+	created.ID = s.nextAccountID
+	created.CreatedAt = time.Now().UTC()
+
+	e, err := encodeAccount(&created)
+	if err != nil {
+		return err
+	}
+
+	if err = s.log.append(e); err != nil {
+		return err
+	}
+
+	s.accounts[created.ID] = &created
+	s.usernames[created.Username] = created.ID
+	s.nextAccountID++
+	*acc = created
+
+	s.maybeCompact()
+
+	return nil
+}
+
+// ReadAccount implements store.Store.
+func (s *Store) ReadAccount(ctx context.Context, id uint64) (*store.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	acc, ok := s.accounts[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	copied := *acc
+
+	return &copied, nil
+}
+
+// ReadAccountByUsername implements store.Store.
+func (s *Store) ReadAccountByUsername(ctx context.Context, username string) (*store.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.usernames[username]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	copied := *s.accounts[id]
+
+	return &copied, nil
+}
+
+// CreateRefreshToken implements store.Store.
+func (s *Store) CreateRefreshToken(ctx context.Context, t *store.RefreshToken) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.refreshTokens[t.Hash]; ok {
+		return store.ErrAlreadyExists
+	}
+
+	created := *t
+	created.ID = s.nextRefreshTokenID
+	created.ExpiresAt = created.ExpiresAt.UTC()
+	created.RevokedAt = nil
+	created.CreatedAt = time.Now().UTC()
+
+	if err := s.putRefreshToken(&created); err != nil {
+		return err
+	}
+
+	s.nextRefreshTokenID++
+	*t = created
+
+	return nil
+}
+
+// ReadRefreshToken implements store.Store.
+func (s *Store) ReadRefreshToken(ctx context.Context, hash string) (*store.RefreshToken, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.refreshTokens[hash]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	copied := *t
+
+	return &copied, nil
+}
+
+// RevokeRefreshToken implements store.Store.
+func (s *Store) RevokeRefreshToken(ctx context.Context, hash string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.refreshTokens[hash]
+	if !ok || t.RevokedAt != nil {
+		return store.ErrNotFound
+	}
+
+	return s.revokeRefreshToken(t, at)
+}
+
+// RevokeRefreshTokens implements store.Store.
+func (s *Store) RevokeRefreshTokens(ctx context.Context, accountID uint64, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.refreshTokens {
+		if t.AccountID != accountID || t.RevokedAt != nil {
+			continue
+		}
+
+		if err := s.revokeRefreshToken(t, at); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteRefreshTokens implements store.Store.
+func (s *Store) DeleteRefreshTokens(ctx context.Context, expiredBefore time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var hashes []string
+	for hash, t := range s.refreshTokens {
+		if t.ExpiresAt.Before(expiredBefore) {
+			hashes = append(hashes, hash)
+		}
+	}
+
+	if len(hashes) == 0 {
+		return 0, nil
+	}
+
+	sort.Strings(hashes)
+
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = s.log.append(entry{Kind: kindRefreshTokensDeleted, Data: data}); err != nil {
+		return 0, err
+	}
+
+	for _, hash := range hashes {
+		delete(s.refreshTokens, hash)
+	}
+
+	s.maybeCompact()
+
+	return len(hashes), nil
+}
+
+// revokeRefreshToken stores a revoked copy of t. Must be called with the
+// write lock held.
+func (s *Store) revokeRefreshToken(t *store.RefreshToken, at time.Time) error {
+	revoked := *t
+	revokedAt := at.UTC()
+	revoked.RevokedAt = &revokedAt
+
+	return s.putRefreshToken(&revoked)
+}
+
+// putRefreshToken appends t to the log and, once it is durable, makes it
+// visible in memory. Must be called with the write lock held.
+func (s *Store) putRefreshToken(t *store.RefreshToken) error {
+	e, err := encodeRefreshToken(t)
+	if err != nil {
+		return err
+	}
+
+	if err = s.log.append(e); err != nil {
+		return err
+	}
+
+	s.refreshTokens[t.Hash] = t
+	s.maybeCompact()
+
+	return nil
+}
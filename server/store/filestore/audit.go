@@ -0,0 +1,119 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+)
+
+const kindAudit = "audit"
+
+// auditData is the log representation of a store.AuditEvent.
+type auditData struct {
+	ID        uint64      `json:"id"`
+	Action    string      `json:"action"`
+	RecordID  uint64      `json:"record_id"`
+	Owner     string      `json:"owner,omitempty"`
+	Before    *recordData `json:"before,omitempty"`
+	After     *recordData `json:"after,omitempty"`
+	Principal string      `json:"principal,omitempty"`
+	Method    string      `json:"method,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func encodeAuditEvent(e *store.AuditEvent) (entry, error) {
+	d := auditData{
+		ID:        e.ID,
+		Action:    e.Action,
+		RecordID:  e.RecordID,
+		Owner:     e.Owner,
+		Principal: e.Principal,
+		Method:    e.Method,
+		RequestID: e.RequestID,
+		CreatedAt: e.CreatedAt,
+	}
+
+	if e.Before != nil {
+		before := newRecordData(e.Before)
+		d.Before = &before
+	}
+
+	if e.After != nil {
+		after := newRecordData(e.After)
+		d.After = &after
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return entry{}, err
+	}
+
+	return entry{Kind: kindAudit, Data: data}, nil
+}
+
+func decodeAuditEvent(data json.RawMessage) (*store.AuditEvent, error) {
+	var d auditData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+
+	e := &store.AuditEvent{
+		ID:        d.ID,
+		Action:    d.Action,
+		RecordID:  d.RecordID,
+		Owner:     d.Owner,
+		Principal: d.Principal,
+		Method:    d.Method,
+		RequestID: d.RequestID,
+		CreatedAt: d.CreatedAt,
+	}
+
+	if d.Before != nil {
+		e.Before = d.Before.record()
+	}
+
+	if d.After != nil {
+		e.After = d.After.record()
+	}
+
+	return e, nil
+}
+
+// ListAuditEvents implements store.Store.
+func (s *Store) ListAuditEvents(ctx context.Context, opts store.AuditListOptions) ([]store.AuditEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []store.AuditEvent
+
+	// the log is in id order, walk it backwards for newest first
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		e := s.audit[i]
+
+		switch {
+		case e.Owner != opts.Owner,
+			opts.RecordID != 0 && e.RecordID != opts.RecordID,
+			opts.Principal != "" && e.Principal != opts.Principal,
+			opts.Action != "" && e.Action != opts.Action,
+			opts.From != nil && e.CreatedAt.Before(*opts.From),
+			opts.To != nil && !e.CreatedAt.Before(*opts.To),
+			opts.BeforeID != 0 && e.ID >= opts.BeforeID:
+			continue
+		}
+
+		events = append(events, *e)
+
+		if opts.Limit > 0 && len(events) == opts.Limit {
+			break
+		}
+	}
+
+	return events, nil
+}
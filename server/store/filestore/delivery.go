@@ -0,0 +1,245 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+)
+
+const (
+	kindDelivery          = "delivery"
+	kindDeliveriesDeleted = "deliveries_deleted"
+)
+
+// deliveryData is the log representation of a store.Delivery.
+type deliveryData struct {
+	ID             uint64          `json:"id"`
+	Webhook        string          `json:"webhook"`
+	Event          string          `json:"event"`
+	RecordID       uint64          `json:"record_id"`
+	Owner          string          `json:"owner,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastError      string          `json:"last_error,omitempty"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+func encodeDelivery(d *store.Delivery) (entry, error) {
+	data, err := json.Marshal(deliveryData{
+		ID:             d.ID,
+		Webhook:        d.Webhook,
+		Event:          d.Event,
+		RecordID:       d.RecordID,
+		Owner:          d.Owner,
+		Payload:        d.Payload,
+		Status:         string(d.Status),
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		LastError:      d.LastError,
+		LastStatusCode: d.LastStatusCode,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	})
+	if err != nil {
+		return entry{}, err
+	}
+
+	return entry{Kind: kindDelivery, Data: data}, nil
+}
+
+func decodeDelivery(data json.RawMessage) (*store.Delivery, error) {
+	var d deliveryData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+
+	return &store.Delivery{
+		ID:             d.ID,
+		Webhook:        d.Webhook,
+		Event:          d.Event,
+		RecordID:       d.RecordID,
+		Owner:          d.Owner,
+		Payload:        d.Payload,
+		Status:         store.DeliveryStatus(d.Status),
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		LastError:      d.LastError,
+		LastStatusCode: d.LastStatusCode,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}, nil
+}
+
+func decodeDeliveriesDeleted(data json.RawMessage) ([]uint64, error) {
+	var ids []uint64
+	err := json.Unmarshal(data, &ids)
+
+	return ids, err
+}
+
+// ClaimDeliveries implements store.Store.
+func (s *Store) ClaimDeliveries(ctx context.Context, now, until time.Time, limit int) ([]store.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*store.Delivery
+	for _, d := range s.deliveries {
+		if d.Status == store.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+
+		return due[i].ID < due[j].ID
+	})
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]store.Delivery, 0, len(due))
+	for _, d := range due {
+		c := *d
+		c.NextAttemptAt = until.UTC()
+
+		if err := s.putDelivery(&c); err != nil {
+			return nil, err
+		}
+
+		claimed = append(claimed, c)
+	}
+
+	return claimed, nil
+}
+
+// SaveDelivery implements store.Store.
+func (s *Store) SaveDelivery(ctx context.Context, d *store.Delivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *d
+	saved.NextAttemptAt = saved.NextAttemptAt.UTC()
+	saved.UpdatedAt = time.Now().UTC()
+
+	if err := s.putDelivery(&saved); err != nil {
+		return err
+	}
+
+	*d = saved
+
+	return nil
+}
+
+// ListDeliveries implements store.Store.
+func (s *Store) ListDeliveries(ctx context.Context, opts store.DeliveryListOptions) ([]store.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var deliveries []store.Delivery
+	for _, d := range s.deliveries {
+		if d.Owner != opts.Owner {
+			continue
+		}
+
+		if opts.Webhook != "" && d.Webhook != opts.Webhook {
+			continue
+		}
+
+		if opts.Status != "" && d.Status != opts.Status {
+			continue
+		}
+
+		if opts.BeforeID != 0 && d.ID >= opts.BeforeID {
+			continue
+		}
+
+		deliveries = append(deliveries, *d)
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+
+	if opts.Limit > 0 && len(deliveries) > opts.Limit {
+		deliveries = deliveries[:opts.Limit]
+	}
+
+	return deliveries, nil
+}
+
+// DeleteDeliveries implements store.Store.
+func (s *Store) DeleteDeliveries(ctx context.Context, finishedBefore time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []uint64
+	for id, d := range s.deliveries {
+		if d.Status != store.DeliveryPending && d.UpdatedAt.Before(finishedBefore) {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = s.log.append(entry{Kind: kindDeliveriesDeleted, Data: data}); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		delete(s.deliveries, id)
+	}
+
+	s.maybeCompact()
+
+	return len(ids), nil
+}
+
+// putDelivery appends d to the log and, once it is durable, makes it
+// visible in memory. Must be called with the write lock held.
+func (s *Store) putDelivery(d *store.Delivery) error {
+	e, err := encodeDelivery(d)
+	if err != nil {
+		return err
+	}
+
+	if err = s.log.append(e); err != nil {
+		return err
+	}
+
+	s.deliveries[d.ID] = d
+	s.maybeCompact()
+
+	return nil
+}
@@ -0,0 +1,681 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+)
+
+// Compaction rewrites the log once it holds at least compactMinEntries
+// entries and more than compactRatio entries per live record.
+const (
+	compactMinEntries = 1024
+	compactRatio      = 2
+)
+
+// Store is a store.Store for single user deployments. Records are kept in
+// memory and every change is appended to a log file, which is synced before
+// the change is acknowledged and compacted once it grows too large.
+type Store struct {
+	mu      sync.RWMutex
+	log     *logFile                 // nil if only kept in memory
+	records map[uint64]*store.Record // including soft deleted ones
+	clients map[clientKey]uint64     // record ids by owner and client id
+	nextID  uint64
+
+	revisions     map[uint64][]store.Revision // by record id, in revision order
+	revisionCount int
+
+	settings map[string]*store.UserSettings // by principal
+
+	deliveries     map[uint64]*store.Delivery
+	nextDeliveryID uint64
+
+	audit []*store.AuditEvent // in id order, ids start at 1
+
+	accounts      map[uint64]*store.Account
+	usernames     map[string]uint64 // account ids by username
+	nextAccountID uint64
+
+	refreshTokens      map[string]*store.RefreshToken // by hash
+	nextRefreshTokenID uint64
+}
+
+var _ store.Store = (*Store)(nil)
+
+// clientKey identifies a record by its client id, which is unique per owner.
+type clientKey struct {
+	owner, clientID string
+}
+
+// Open opens the store at path, creating it if needed. Only one process can
+// have a store open at a time.
+func Open(path string) (*Store, error) {
+	s := newStore()
+
+	log, err := openLog(path, s.apply)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log = log
+
+	// records written before revisions were kept start at their current values
+	for _, rec := range s.records {
+		if rec.Revision == 0 {
+			rec.Revision = 1
+			s.revisions[rec.ID] = []store.Revision{store.NewRevision(rec)}
+			s.revisionCount++
+		}
+	}
+
+	return s, nil
+}
+
+// NewMemory creates a store that is only kept in memory, e.g. for tests.
+func NewMemory() *Store {
+	return newStore()
+}
+
+func newStore() *Store {
+	return &Store{
+		records: map[uint64]*store.Record{},
+		clients: map[clientKey]uint64{},
+		nextID:  1,
+
+		revisions: map[uint64][]store.Revision{},
+		settings:  map[string]*store.UserSettings{},
+
+		deliveries:     map[uint64]*store.Delivery{},
+		nextDeliveryID: 1,
+
+		accounts:      map[uint64]*store.Account{},
+		usernames:     map[string]uint64{},
+		nextAccountID: 1,
+
+		refreshTokens:      map[string]*store.RefreshToken{},
+		nextRefreshTokenID: 1,
+	}
+}
+
+// apply replays a log entry into memory.
+func (s *Store) apply(e entry) error {
+	switch e.Kind {
+	case kindRecord:
+		rec, err := decodeRecord(e.Data)
+		if err != nil {
+			return err
+		}
+
+		s.records[rec.ID] = rec
+		if rec.ClientID != "" {
+			s.clients[clientKey{rec.Owner, rec.ClientID}] = rec.ID
+		}
+
+		if rec.ID >= s.nextID {
+			s.nextID = rec.ID + 1
+		}
+
+		return nil
+	case kindRevision:
+		rev, err := decodeRevision(e.Data)
+		if err != nil {
+			return err
+		}
+
+		s.revisions[rev.RecordID] = append(s.revisions[rev.RecordID], rev)
+		s.revisionCount++
+
+		return nil
+	case kindSettings:
+		settings, err := decodeSettings(e.Data)
+		if err != nil {
+			return err
+		}
+
+		s.settings[settings.Principal] = settings
+
+		return nil
+	case kindDelivery:
+		d, err := decodeDelivery(e.Data)
+		if err != nil {
+			return err
+		}
+
+		s.deliveries[d.ID] = d
+		if d.ID >= s.nextDeliveryID {
+			s.nextDeliveryID = d.ID + 1
+		}
+
+		return nil
+	case kindAudit:
+		e, err := decodeAuditEvent(e.Data)
+		if err != nil {
+			return err
+		}
+
+		if e.ID != uint64(len(s.audit))+1 {
+			return fmt.Errorf("audit event %d out of order", e.ID)
+		}
+
+		s.audit = append(s.audit, e)
+
+		return nil
+	case kindDeliveriesDeleted:
+		ids, err := decodeDeliveriesDeleted(e.Data)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			delete(s.deliveries, id)
+		}
+
+		return nil
+	case kindAccount:
+		acc, err := decodeAccount(e.Data)
+		if err != nil {
+			return err
+		}
+
+		s.accounts[acc.ID] = acc
+		s.usernames[acc.Username] = acc.ID
+		if acc.ID >= s.nextAccountID {
+			s.nextAccountID = acc.ID + 1
+		}
+
+		return nil
+	case kindRefreshToken:
+		t, err := decodeRefreshToken(e.Data)
+		if err != nil {
+			return err
+		}
+
+		s.refreshTokens[t.Hash] = t
+		if t.ID >= s.nextRefreshTokenID {
+			s.nextRefreshTokenID = t.ID + 1
+		}
+
+		return nil
+	case kindRefreshTokensDeleted:
+		hashes, err := decodeRefreshTokensDeleted(e.Data)
+		if err != nil {
+			return err
+		}
+
+		for _, hash := range hashes {
+			delete(s.refreshTokens, hash)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown entry kind %q, the file was written by a newer version", e.Kind)
+	}
+}
+
+// CreateRecord implements store.Store.
+func (s *Store) CreateRecord(ctx context.Context, rec *store.Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[clientKey{rec.Owner, rec.ClientID}]; ok && rec.ClientID != "" {
+		return store.ErrAlreadyExists
+	}
+
+	now := time.Now().UTC()
+
+	created := *rec
+	created.ID = s.nextID
+	created.Revision = 1
+	created.WeightedAt = created.WeightedAt.UTC()
+	created.CreatedAt = now
+	created.UpdatedAt = now
+	created.DeletedAt = nil
+
+	if err := s.put(ctx, nil, &created); err != nil {
+		return err
+	}
+
+	s.nextID++
+	*rec = created
+
+	return nil
+}
+
+// live returns the record of owner with the given id unless it does not
+// exist or has been deleted. Must be called with the lock held.
+func (s *Store) live(owner string, id uint64) (*store.Record, bool) {
+	rec, ok := s.records[id]
+	if !ok || rec.Owner != owner || rec.DeletedAt != nil {
+		return nil, false
+	}
+
+	return rec, true
+}
+
+// ReadRecord implements store.Store.
+func (s *Store) ReadRecord(ctx context.Context, owner string, id uint64) (*store.Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.live(owner, id)
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	r := *rec
+
+	return &r, nil
+}
+
+// ReadRecordByClientID implements store.Store.
+func (s *Store) ReadRecordByClientID(ctx context.Context, owner, clientID string) (*store.Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.clients[clientKey{owner, clientID}]
+	if !ok || clientID == "" {
+		return nil, store.ErrNotFound
+	}
+
+	r := *s.records[id]
+
+	return &r, nil
+}
+
+// UpdateRecord implements store.Store.
+func (s *Store) UpdateRecord(ctx context.Context, owner string, id uint64, update func(*store.Record) error) (*store.Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.live(owner, id)
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	updated := *rec
+	updated.Tags = slices.Clone(rec.Tags)
+	if err := update(&updated); err != nil {
+		return nil, err
+	}
+
+	updated.ID = rec.ID
+	updated.Owner = rec.Owner
+	updated.ClientID = rec.ClientID
+	updated.Revision = rec.Revision
+	updated.WeightedAt = updated.WeightedAt.UTC()
+	updated.CreatedAt = rec.CreatedAt
+	updated.UpdatedAt = time.Now().UTC()
+
+	if store.Revised(rec, &updated) {
+		updated.Revision++
+	}
+
+	if err := s.put(ctx, rec, &updated); err != nil {
+		return nil, err
+	}
+
+	r := updated
+
+	return &r, nil
+}
+
+// DeleteRecord implements store.Store.
+func (s *Store) DeleteRecord(ctx context.Context, owner string, id uint64) (*store.Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.live(owner, id)
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	now := time.Now().UTC()
+
+	prev := *rec
+
+	deleted := *rec
+	deleted.UpdatedAt = now
+	deleted.DeletedAt = &now
+
+	if err := s.put(ctx, rec, &deleted); err != nil {
+		return nil, err
+	}
+
+	return &prev, nil
+}
+
+// ListRecords implements store.Store.
+func (s *Store) ListRecords(ctx context.Context, opts store.ListOptions) ([]store.Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	recs := make([]store.Record, 0, len(s.records))
+	for _, rec := range s.records {
+		if rec.DeletedAt != nil || !opts.Matches(*rec) || (opts.After != nil && !opts.Less(*opts.After, *rec)) {
+			continue
+		}
+
+		recs = append(recs, *rec)
+	}
+
+	sort.Slice(recs, func(i, j int) bool { return opts.Less(recs[i], recs[j]) })
+
+	if opts.Limit > 0 && len(recs) > opts.Limit {
+		recs = recs[:opts.Limit]
+	}
+
+	return recs, nil
+}
+
+// CountRecords implements store.Store.
+func (s *Store) CountRecords(ctx context.Context, opts store.ListOptions) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, rec := range s.records {
+		if rec.DeletedAt == nil && opts.Matches(*rec) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// ListChanges implements store.Store.
+func (s *Store) ListChanges(ctx context.Context, owner string, since time.Time) ([]store.Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var recs []store.Record
+	for _, rec := range s.records {
+		if rec.Owner == owner && rec.UpdatedAt.After(since) {
+			recs = append(recs, *rec)
+		}
+	}
+
+	sort.Slice(recs, func(i, j int) bool {
+		if !recs[i].UpdatedAt.Equal(recs[j].UpdatedAt) {
+			return recs[i].UpdatedAt.Before(recs[j].UpdatedAt)
+		}
+
+		return recs[i].ID < recs[j].ID
+	})
+
+	return recs, nil
+}
+
+// Compact rewrites the log so it only holds the latest version of every
+// record, delivery and settings, the revisions of every record and the
+// audit log.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.compact()
+}
+
+// Close implements store.Store.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.log.close()
+}
+
+// put appends rec, which replaces prev, together with its revision if it has
+// a new one, its audit event and its webhook deliveries to the log as a
+// single entry and, once it is durable, makes them visible in memory. Must be
+// called with the write lock held.
+func (s *Store) put(ctx context.Context, prev, rec *store.Record) error {
+	var rev *store.Revision
+	if prev == nil || rec.Revision != prev.Revision {
+		r := store.NewRevision(rec)
+		rev = &r
+	}
+
+	audit := store.NewAuditEvent(ctx, prev, rec)
+	audit.ID = uint64(len(s.audit)) + 1
+	audit.CreatedAt = time.Now().UTC()
+
+	queued, err := store.DeliveriesFor(ctx, audit)
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]*store.Delivery, 0, len(queued))
+	for i, d := range queued {
+		delivery := *d
+		delivery.ID = s.nextDeliveryID + uint64(i)
+		delivery.CreatedAt = audit.CreatedAt
+		delivery.UpdatedAt = audit.CreatedAt
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	entries, err := encodeChange(rec, rev, audit, deliveries)
+	if err != nil {
+		return err
+	}
+
+	if err = s.log.append(entries...); err != nil {
+		return err
+	}
+
+	s.records[rec.ID] = rec
+	if rec.ClientID != "" {
+		s.clients[clientKey{rec.Owner, rec.ClientID}] = rec.ID
+	}
+
+	if rev != nil {
+		s.revisions[rec.ID] = append(s.revisions[rec.ID], *rev)
+		s.revisionCount++
+	}
+
+	s.audit = append(s.audit, audit)
+
+	for _, d := range deliveries {
+		s.deliveries[d.ID] = d
+	}
+
+	s.nextDeliveryID += uint64(len(deliveries))
+
+	s.maybeCompact()
+
+	return nil
+}
+
+// encodeChange returns the entries put writes for a change.
+func encodeChange(rec *store.Record, rev *store.Revision, audit *store.AuditEvent, deliveries []*store.Delivery) ([]entry, error) {
+	e, err := encodeRecord(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []entry{e}
+
+	if rev != nil {
+		if e, err = encodeRevision(*rev); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+
+	if e, err = encodeAuditEvent(audit); err != nil {
+		return nil, err
+	}
+
+	entries = append(entries, e)
+
+	for _, d := range deliveries {
+		if e, err = encodeDelivery(d); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// maybeCompact compacts the log once it holds too many stale entries. Must
+// be called with the write lock held.
+func (s *Store) maybeCompact() {
+	if s.log == nil {
+		return
+	}
+
+	live := len(s.records) + s.revisionCount + len(s.settings) + len(s.deliveries) + len(s.audit) + len(s.accounts) + len(s.refreshTokens)
+	if s.log.entries < compactMinEntries || s.log.entries <= compactRatio*live {
+		return
+	}
+
+	// the change is already durable, a failed compaction only means the log
+	// stays larger than it needs to be
+	if err := s.compact(); err != nil {
+		slog.Warn("failed to compact store", "path", s.log.path, "error", err)
+	}
+}
+
+func (s *Store) compact() error {
+	ids := make([]uint64, 0, len(s.records))
+	for id := range s.records {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	entries := make([]entry, 0, len(ids)+s.revisionCount+len(s.settings)+len(s.deliveries)+len(s.audit)+len(s.accounts)+len(s.refreshTokens))
+	for _, id := range ids {
+		e, err := encodeRecord(s.records[id])
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, e)
+
+		for _, rev := range s.revisions[id] {
+			e, err := encodeRevision(rev)
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, e)
+		}
+	}
+
+	principals := make([]string, 0, len(s.settings))
+	for principal := range s.settings {
+		principals = append(principals, principal)
+	}
+
+	sort.Strings(principals)
+
+	for _, principal := range principals {
+		e, err := encodeSettings(s.settings[principal])
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, e)
+	}
+
+	ids = ids[:0]
+	for id := range s.deliveries {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		e, err := encodeDelivery(s.deliveries[id])
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, e)
+	}
+
+	for _, a := range s.audit {
+		e, err := encodeAuditEvent(a)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, e)
+	}
+
+	ids = ids[:0]
+	for id := range s.accounts {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		e, err := encodeAccount(s.accounts[id])
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, e)
+	}
+
+	tokens := make([]*store.RefreshToken, 0, len(s.refreshTokens))
+	for _, t := range s.refreshTokens {
+		tokens = append(tokens, t)
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+
+	for _, t := range tokens {
+		e, err := encodeRefreshToken(t)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, e)
+	}
+
+	return s.log.rewrite(entries)
+}
@@ -0,0 +1,226 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+)
+
+func openStore(t *testing.T, path string) *Store {
+	t.Helper()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	return s
+}
+
+func createRecords(t *testing.T, s *Store, weights ...float32) []uint64 {
+	t.Helper()
+
+	var ids []uint64
+	for _, w := range weights {
+		rec := &store.Record{Weight: w, WeightedAt: time.Now()}
+		if err := s.CreateRecord(context.Background(), rec); err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, rec.ID)
+	}
+
+	return ids
+}
+
+// lines returns the lines of the log at path.
+func lines(t *testing.T, path string) [][]byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bytes.SplitAfter(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+}
+
+func checkWeights(t *testing.T, s *Store, want ...float32) {
+	t.Helper()
+
+	recs, err := s.ListRecords(context.Background(), store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []float32
+	for _, rec := range recs {
+		got = append(got, rec.Weight)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got weights %v, want %v", got, want)
+	}
+
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got weights %v, want %v", got, want)
+		}
+	}
+}
+
+func TestReopenTornTail(t *testing.T) {
+	tests := []struct {
+		name string
+		tear func(last []byte) []byte // returns what is left of the last line
+	}{
+		{"truncated", func(last []byte) []byte { return last[:len(last)/2] }},
+		{"without newline", func(last []byte) []byte { return last[:len(last)-1] }},
+		{"checksum mismatch", func(last []byte) []byte {
+			torn := bytes.Clone(last)
+			torn[len(torn)-3] ^= 1
+
+			return torn
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "wt.log")
+
+			s := openStore(t, path)
+			createRecords(t, s, 80, 81)
+			s.Close()
+
+			// tear the last entry as a crash in the middle of writing it would
+			ls := lines(t, path)
+			data := bytes.Join(ls[:len(ls)-1], nil)
+			data = append(data, tt.tear(ls[len(ls)-1])...)
+
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			// the torn entry held the whole change, so nothing of it is left
+			s = openStore(t, path)
+			checkWeights(t, s, 80)
+
+			if len(s.audit) != 1 || s.revisionCount != 1 {
+				t.Fatalf("got %d audit events and %d revisions, want 1 of each", len(s.audit), s.revisionCount)
+			}
+
+			// the torn entry is dropped, so the log can be appended to again
+			createRecords(t, s, 82)
+			s.Close()
+
+			s = openStore(t, path)
+			defer s.Close()
+
+			checkWeights(t, s, 80, 82)
+		})
+	}
+}
+
+func TestReopenCorruptEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wt.log")
+
+	s := openStore(t, path)
+	createRecords(t, s, 80, 81)
+	s.Close()
+
+	// a bad entry followed by good ones is not a torn write
+	ls := lines(t, path)
+	ls[0] = bytes.Clone(ls[0])
+	ls[0][len(ls[0])-3] ^= 1
+
+	if err := os.WriteFile(path, bytes.Join(ls, nil), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := Open(path); err == nil {
+		s.Close()
+		t.Fatal("opened a log with a corrupt entry")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "wt.log")
+
+	s := openStore(t, path)
+	ids := createRecords(t, s, 80, 81, 82)
+
+	for i := range 5 {
+		_, err := s.UpdateRecord(ctx, "", ids[0], func(rec *store.Record) error {
+			rec.Weight = 90 + float32(i)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := s.DeleteRecord(ctx, "", ids[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	before := s.log.entries
+
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	// every record is left with a single entry, the audit log and the
+	// revisions are kept whole
+	if want := len(s.records) + s.revisionCount + len(s.audit); s.log.entries != want || s.log.entries >= before {
+		t.Fatalf("got %d entries after compacting %d, want %d", s.log.entries, before, want)
+	}
+
+	if n := len(lines(t, path)); n != s.log.entries {
+		t.Fatalf("got %d lines, want %d", n, s.log.entries)
+	}
+
+	s.Close()
+
+	s = openStore(t, path)
+	defer s.Close()
+
+	checkWeights(t, s, 94, 82)
+
+	if _, err := s.ReadRecord(ctx, "", ids[1]); err != store.ErrNotFound {
+		t.Fatalf("got error %v reading the deleted record, want ErrNotFound", err)
+	}
+
+	// deleted records are kept for SyncRecords
+	changes, err := s.ListChanges(ctx, "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 3 {
+		t.Fatalf("got %d changes, want 3", len(changes))
+	}
+
+	revs, err := s.ListRevisions(ctx, "", ids[0], store.RevisionListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revs) != 6 {
+		t.Fatalf("got %d revisions, want 6", len(revs))
+	}
+
+	events, err := s.ListAuditEvents(ctx, store.AuditListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 9 {
+		t.Fatalf("got %d audit events, want 9", len(events))
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package filestore
+
+// lockFile is a no-op on platforms without flock. Running two servers
+// against the same store there is not detected.
+func lockFile(path string) (func() error, error) {
+	return func() error { return nil }, nil
+}
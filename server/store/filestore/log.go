@@ -0,0 +1,287 @@
+package filestore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// The log is a sequence of lines, each holding the CRC-32C of an entry in
+// hex, a space and the entry encoded as JSON. A line that is cut short or
+// fails its checksum at the end of the file is the result of a crash during
+// a write and is discarded; anywhere else it means the file is corrupt.
+// Entries that must be stored together are written as a single batch entry,
+// so they are replayed either all or not at all.
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// kindBatch is the kind of an entry whose Data holds a list of entries.
+const kindBatch = "batch"
+
+// entry is a single change in the log. Data is interpreted according to Kind.
+type entry struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+// unbatch returns the entries held by e, or e itself if it is not a batch.
+func unbatch(e entry) ([]entry, error) {
+	if e.Kind != kindBatch {
+		return []entry{e}, nil
+	}
+
+	var entries []entry
+	err := json.Unmarshal(e.Data, &entries)
+
+	return entries, err
+}
+
+type logFile struct {
+	path    string
+	f       *os.File
+	unlock  func() error
+	size    int64 // offset of the end of the last complete entry
+	entries int   // counting those in batches one by one
+}
+
+func lockPath(path string) string {
+	return path + ".lock"
+}
+
+// openLog opens or creates the log at path and replays its entries through
+// apply.
+func openLog(path string, apply func(entry) error) (*logFile, error) {
+	unlock, err := lockFile(lockPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %v: %w", path, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+
+	l := &logFile{path: path, f: f, unlock: unlock}
+
+	if err = l.replay(apply); err != nil {
+		l.close()
+		return nil, fmt.Errorf("failed to read %v: %w", path, err)
+	}
+
+	return l, nil
+}
+
+func (l *logFile) replay(apply func(entry) error) error {
+	r := bufio.NewReader(l.f)
+
+	var good int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			break
+		}
+
+		e, decodeErr := decodeLine(line)
+		if err != nil || decodeErr != nil {
+			if _, peekErr := r.Peek(1); err == nil && peekErr == nil {
+				return fmt.Errorf("corrupt entry at offset %d", good)
+			}
+
+			// torn write at the end of the log, drop it
+			if err := l.f.Truncate(good); err != nil {
+				return err
+			}
+
+			break
+		}
+
+		entries, err := unbatch(e)
+		if err != nil {
+			return fmt.Errorf("entry at offset %d: %w", good, err)
+		}
+
+		for _, e := range entries {
+			if err := apply(e); err != nil {
+				return fmt.Errorf("entry at offset %d: %w", good, err)
+			}
+		}
+
+		good += int64(len(line))
+		l.entries += len(entries)
+	}
+
+	l.size = good
+
+	return nil
+}
+
+// append durably writes entries at the end of the log, as a batch if there
+// are several, with a single write. A nil log discards them.
+func (l *logFile) append(entries ...entry) error {
+	if l == nil {
+		return nil
+	}
+
+	e := entries[0]
+	if len(entries) > 1 {
+		data, err := json.Marshal(entries)
+		if err != nil {
+			return err
+		}
+
+		e = entry{Kind: kindBatch, Data: data}
+	}
+
+	line, err := encodeLine(e)
+	if err != nil {
+		return err
+	}
+
+	if _, err = l.f.WriteAt(line, l.size); err == nil {
+		err = l.f.Sync()
+	}
+	if err != nil {
+		// drop whatever part of the entry made it to the file so the next
+		// append does not follow a torn line
+		l.f.Truncate(l.size)
+		return err
+	}
+
+	l.size += int64(len(line))
+	l.entries += len(entries)
+
+	return nil
+}
+
+// rewrite atomically replaces the log with entries.
+func (l *logFile) rewrite(entries []entry) error {
+	tmpPath := l.path + ".compact"
+
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	var size int64
+	w := bufio.NewWriter(tmp)
+	for _, e := range entries {
+		line, err := encodeLine(e)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+
+		if _, err = w.Write(line); err != nil {
+			tmp.Close()
+			return err
+		}
+
+		size += int64(len(line))
+	}
+
+	if err = w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmpPath, l.path); err != nil {
+		return err
+	}
+
+	if err = syncDir(filepath.Dir(l.path)); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path, os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+
+	l.f.Close()
+	l.f = f
+	l.size = size
+	l.entries = len(entries)
+
+	return nil
+}
+
+func (l *logFile) close() error {
+	if l == nil {
+		return nil
+	}
+
+	err := l.f.Close()
+	if unlockErr := l.unlock(); err == nil {
+		err = unlockErr
+	}
+
+	return err
+}
+
+func encodeLine(e entry) ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := crc32.Checksum(data, crcTable)
+
+	line := make([]byte, 0, 8+1+len(data)+1)
+	line = fmt.Appendf(line, "%08x ", sum)
+	line = append(line, data...)
+	line = append(line, '\n')
+
+	return line, nil
+}
+
+func decodeLine(line []byte) (entry, error) {
+	var e entry
+
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	if len(line) < 10 || line[8] != ' ' {
+		return e, errors.New("malformed line")
+	}
+
+	var sum [4]byte
+	if _, err := hex.Decode(sum[:], line[:8]); err != nil {
+		return e, err
+	}
+
+	data := line[9:]
+	if crc32.Checksum(data, crcTable) != binary.BigEndian.Uint32(sum[:]) {
+		return e, errors.New("checksum mismatch")
+	}
+
+	err := json.Unmarshal(data, &e)
+
+	return e, err
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,82 @@
+package filestore
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+)
+
+const kindRecord = "record"
+
+// recordData is the log representation of a store.Record. It is kept
+// separate so the file format does not change by accident.
+type recordData struct {
+	ID         uint64     `json:"id"`
+	Owner      string     `json:"owner,omitempty"`
+	ClientID   string     `json:"client_id,omitempty"`
+	Revision   uint64     `json:"revision,omitempty"`
+	Weight     float32    `json:"weight"`
+	WeightedAt time.Time  `json:"weighted_at"`
+	TimeZone   string     `json:"time_zone,omitempty"`
+	UTCOffset  int32      `json:"utc_offset,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+	Source     string     `json:"source,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+}
+
+func newRecordData(rec *store.Record) recordData {
+	return recordData{
+		ID:         rec.ID,
+		Owner:      rec.Owner,
+		ClientID:   rec.ClientID,
+		Revision:   rec.Revision,
+		Weight:     rec.Weight,
+		WeightedAt: rec.WeightedAt,
+		TimeZone:   rec.TimeZone,
+		UTCOffset:  rec.UTCOffset,
+		Tags:       rec.Tags,
+		Source:     rec.Source,
+		CreatedAt:  rec.CreatedAt,
+		UpdatedAt:  rec.UpdatedAt,
+		DeletedAt:  rec.DeletedAt,
+	}
+}
+
+func (d recordData) record() *store.Record {
+	return &store.Record{
+		ID:         d.ID,
+		Owner:      d.Owner,
+		ClientID:   d.ClientID,
+		Revision:   d.Revision,
+		Weight:     d.Weight,
+		WeightedAt: d.WeightedAt,
+		TimeZone:   d.TimeZone,
+		UTCOffset:  d.UTCOffset,
+		Tags:       d.Tags,
+		Source:     d.Source,
+		CreatedAt:  d.CreatedAt,
+		UpdatedAt:  d.UpdatedAt,
+		DeletedAt:  d.DeletedAt,
+	}
+}
+
+func encodeRecord(rec *store.Record) (entry, error) {
+	data, err := json.Marshal(newRecordData(rec))
+	if err != nil {
+		return entry{}, err
+	}
+
+	return entry{Kind: kindRecord, Data: data}, nil
+}
+
+func decodeRecord(data json.RawMessage) (*store.Record, error) {
+	var d recordData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+
+	return d.record(), nil
+}
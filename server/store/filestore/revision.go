@@ -0,0 +1,75 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+)
+
+const kindRevision = "revision"
+
+// revisionData is the log representation of a store.Revision.
+type revisionData struct {
+	RecordID   uint64    `json:"record_id"`
+	Revision   uint64    `json:"revision"`
+	Weight     float32   `json:"weight"`
+	WeightedAt time.Time `json:"weighted_at"`
+	TimeZone   string    `json:"time_zone,omitempty"`
+	UTCOffset  int32     `json:"utc_offset,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func encodeRevision(rev store.Revision) (entry, error) {
+	data, err := json.Marshal(revisionData(rev))
+	if err != nil {
+		return entry{}, err
+	}
+
+	return entry{Kind: kindRevision, Data: data}, nil
+}
+
+func decodeRevision(data json.RawMessage) (store.Revision, error) {
+	var d revisionData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return store.Revision{}, err
+	}
+
+	return store.Revision(d), nil
+}
+
+// ListRevisions implements store.Store.
+func (s *Store) ListRevisions(ctx context.Context, owner string, id uint64, opts store.RevisionListOptions) ([]store.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.live(owner, id); !ok {
+		return nil, store.ErrNotFound
+	}
+
+	var revs []store.Revision
+
+	revisions := s.revisions[id]
+	for i := len(revisions) - 1; i >= 0; i-- {
+		rev := revisions[i]
+
+		switch {
+		case opts.At != nil && rev.CreatedAt.After(*opts.At),
+			opts.BeforeRevision != 0 && rev.Revision >= opts.BeforeRevision:
+			continue
+		}
+
+		revs = append(revs, rev)
+
+		if opts.Limit > 0 && len(revs) == opts.Limit {
+			break
+		}
+	}
+
+	return revs, nil
+}
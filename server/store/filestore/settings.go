@@ -0,0 +1,86 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+)
+
+const kindSettings = "settings"
+
+// settingsData is the log representation of a store.UserSettings.
+type settingsData struct {
+	Principal string    `json:"principal"`
+	TimeZone  string    `json:"time_zone,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func encodeSettings(settings *store.UserSettings) (entry, error) {
+	data, err := json.Marshal(settingsData(*settings))
+	if err != nil {
+		return entry{}, err
+	}
+
+	return entry{Kind: kindSettings, Data: data}, nil
+}
+
+func decodeSettings(data json.RawMessage) (*store.UserSettings, error) {
+	var d settingsData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+
+	settings := store.UserSettings(d)
+
+	return &settings, nil
+}
+
+// ReadSettings implements store.Store.
+func (s *Store) ReadSettings(ctx context.Context, principal string) (*store.UserSettings, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settings, ok := s.settings[principal]
+	if !ok {
+		return &store.UserSettings{Principal: principal}, nil
+	}
+
+	copied := *settings
+
+	return &copied, nil
+}
+
+// SaveSettings implements store.Store.
+func (s *Store) SaveSettings(ctx context.Context, settings *store.UserSettings) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *settings
+	saved.UpdatedAt = time.Now().UTC()
+
+	e, err := encodeSettings(&saved)
+	if err != nil {
+		return err
+	}
+
+	if err = s.log.append(e); err != nil {
+		return err
+	}
+
+	s.settings[saved.Principal] = &saved
+	settings.UpdatedAt = saved.UpdatedAt
+
+	s.maybeCompact()
+
+	return nil
+}
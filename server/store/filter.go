@@ -0,0 +1,53 @@
+package store
+
+import (
+	"math"
+
+	"github.com/0gener/go-weight-tracker/server/filter"
+)
+
+// RecordFilterSchema lists the record fields ListOptions.Filter can use.
+var RecordFilterSchema = filter.Schema{
+	"id":          filter.Number,
+	"revision":    filter.Number,
+	"weight":      filter.Number,
+	"weighted_at": filter.Timestamp,
+	"local_date":  filter.String,
+	"time_zone":   filter.String,
+	"utc_offset":  filter.Number,
+	"tags":        filter.StringList,
+	"source":      filter.String,
+	"created_at":  filter.Timestamp,
+	"updated_at":  filter.Timestamp,
+}
+
+// filterValue returns the value of a field of RecordFilterSchema.
+func (r Record) filterValue(field string) any {
+	switch field {
+	case "id":
+		return float64(r.ID)
+	case "revision":
+		return float64(r.Revision)
+	case "weight":
+		// weights are stored with two decimals, compare them as SQL does
+		return math.Round(float64(r.Weight)*100) / 100
+	case "weighted_at":
+		return r.WeightedAt
+	case "local_date":
+		return r.LocalDate()
+	case "time_zone":
+		return r.TimeZone
+	case "utc_offset":
+		return float64(r.UTCOffset)
+	case "tags":
+		return r.Tags
+	case "source":
+		return r.Source
+	case "created_at":
+		return r.CreatedAt
+	case "updated_at":
+		return r.UpdatedAt
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Revision is a version of the values of a record.
+type Revision struct {
+	RecordID   uint64
+	Revision   uint64
+	Weight     float32
+	WeightedAt time.Time
+	TimeZone   string
+	UTCOffset  int32
+	CreatedAt  time.Time // when the record was changed to these values
+}
+
+// NewRevision returns the revision holding the current values of rec.
+func NewRevision(rec *Record) Revision {
+	return Revision{
+		RecordID:   rec.ID,
+		Revision:   rec.Revision,
+		Weight:     rec.Weight,
+		WeightedAt: rec.WeightedAt,
+		TimeZone:   rec.TimeZone,
+		UTCOffset:  rec.UTCOffset,
+		CreatedAt:  rec.UpdatedAt,
+	}
+}
+
+// Revised reports whether updating prev to rec needs a new revision, i.e.
+// rec is not deleted and its values differ from those of prev.
+func Revised(prev, rec *Record) bool {
+	if rec.DeletedAt != nil {
+		return false
+	}
+
+	return rec.Weight != prev.Weight || !rec.WeightedAt.Equal(prev.WeightedAt) ||
+		rec.TimeZone != prev.TimeZone || rec.UTCOffset != prev.UTCOffset
+}
+
+// RevisionListOptions filters the revisions returned by ListRevisions. Zero
+// values are ignored.
+type RevisionListOptions struct {
+	At             *time.Time // only revisions made at or before, inclusive
+	BeforeRevision uint64     // only lower revisions, for paging
+	Limit          int
+}
+
+// RevisionStore reads the revisions of records. Revisions are added by the
+// record store itself.
+type RevisionStore interface {
+	// ListRevisions returns the revisions of the record of owner with the
+	// given id matching opts, newest first, or ErrNotFound if the record
+	// does not exist or has been deleted. The current values are the newest
+	// one.
+	ListRevisions(ctx context.Context, owner string, id uint64, opts RevisionListOptions) ([]Revision, error)
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// UserSettings holds the preferences of a principal.
+type UserSettings struct {
+	Principal string
+	TimeZone  string // IANA zone new records default to, empty means UTC
+	UpdatedAt time.Time
+}
+
+// SettingsStore persists the settings of every principal. Unless requests
+// are authenticated every caller shares the settings of the empty
+// principal.
+type SettingsStore interface {
+	// ReadSettings returns the settings of principal, or zero settings if
+	// none have been saved.
+	ReadSettings(ctx context.Context, principal string) (*UserSettings, error)
+
+	// SaveSettings stores settings, replacing the previous ones, and fills
+	// in UpdatedAt.
+	SaveSettings(ctx context.Context, settings *UserSettings) error
+}
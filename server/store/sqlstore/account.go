@@ -0,0 +1,164 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+)
+
+type account struct {
+	ID           uint64 `gorm:"primaryKey"`
+	Username     string
+	PasswordHash string
+	CreatedAt    time.Time
+}
+
+func (account) TableName() string {
+	return "accounts"
+}
+
+type refreshToken struct {
+	ID        uint64 `gorm:"primaryKey"`
+	AccountID uint64
+	TokenHash string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+func (refreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// CreateAccount implements store.Store.
+func (s *Store) CreateAccount(ctx context.Context, acc *store.Account) error {
+	r := account{
+		Username:     acc.Username,
+		PasswordHash: acc.PasswordHash,
+	}
+
+	if err := s.db.WithContext(ctx).Create(&r).Error; err != nil {
+		return translateError(err)
+	}
+
+	*acc = toAccount(r)
+
+	return nil
+}
+
+// ReadAccount implements store.Store.
+func (s *Store) ReadAccount(ctx context.Context, id uint64) (*store.Account, error) {
+	var r account
+	if err := s.db.WithContext(ctx).First(&r, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	acc := toAccount(r)
+
+	return &acc, nil
+}
+
+// ReadAccountByUsername implements store.Store.
+func (s *Store) ReadAccountByUsername(ctx context.Context, username string) (*store.Account, error) {
+	var r account
+	if err := s.db.WithContext(ctx).Where("username = ?", username).First(&r).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	acc := toAccount(r)
+
+	return &acc, nil
+}
+
+// CreateRefreshToken implements store.Store.
+func (s *Store) CreateRefreshToken(ctx context.Context, t *store.RefreshToken) error {
+	r := refreshToken{
+		AccountID: t.AccountID,
+		TokenHash: t.Hash,
+		ExpiresAt: t.ExpiresAt.UTC(),
+	}
+
+	if err := s.db.WithContext(ctx).Create(&r).Error; err != nil {
+		return translateError(err)
+	}
+
+	*t = toRefreshToken(r)
+
+	return nil
+}
+
+// ReadRefreshToken implements store.Store.
+func (s *Store) ReadRefreshToken(ctx context.Context, hash string) (*store.RefreshToken, error) {
+	var r refreshToken
+	if err := s.db.WithContext(ctx).Where("token_hash = ?", hash).First(&r).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	t := toRefreshToken(r)
+
+	return &t, nil
+}
+
+// RevokeRefreshToken implements store.Store.
+func (s *Store) RevokeRefreshToken(ctx context.Context, hash string, at time.Time) error {
+	res := s.db.WithContext(ctx).Model(&refreshToken{}).
+		Where("token_hash = ? AND revoked_at IS NULL", hash).
+		Update("revoked_at", at.UTC())
+	if res.Error != nil {
+		return translateError(res.Error)
+	}
+
+	if res.RowsAffected == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// RevokeRefreshTokens implements store.Store.
+func (s *Store) RevokeRefreshTokens(ctx context.Context, accountID uint64, at time.Time) error {
+	err := s.db.WithContext(ctx).Model(&refreshToken{}).
+		Where("account_id = ? AND revoked_at IS NULL", accountID).
+		Update("revoked_at", at.UTC()).Error
+
+	return translateError(err)
+}
+
+// DeleteRefreshTokens implements store.Store.
+func (s *Store) DeleteRefreshTokens(ctx context.Context, expiredBefore time.Time) (int, error) {
+	res := s.db.WithContext(ctx).
+		Where("expires_at < ?", expiredBefore.UTC()).
+		Delete(&refreshToken{})
+	if res.Error != nil {
+		return 0, translateError(res.Error)
+	}
+
+	return int(res.RowsAffected), nil
+}
+
+func toAccount(r account) store.Account {
+	return store.Account{
+		ID:           r.ID,
+		Username:     r.Username,
+		PasswordHash: r.PasswordHash,
+		CreatedAt:    r.CreatedAt,
+	}
+}
+
+func toRefreshToken(r refreshToken) store.RefreshToken {
+	t := store.RefreshToken{
+		ID:        r.ID,
+		AccountID: r.AccountID,
+		Hash:      r.TokenHash,
+		ExpiresAt: r.ExpiresAt.UTC(),
+		CreatedAt: r.CreatedAt,
+	}
+
+	if r.RevokedAt != nil {
+		revokedAt := r.RevokedAt.UTC()
+		t.RevokedAt = &revokedAt
+	}
+
+	return t
+}
@@ -0,0 +1,196 @@
+package sqlstore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+	"gorm.io/gorm"
+)
+
+type auditEvent struct {
+	ID          uint64 `gorm:"primaryKey"`
+	Action      string
+	RecordID    uint64
+	Owner       string
+	BeforeValue *string
+	AfterValue  *string
+	Principal   string
+	Method      string
+	RequestID   string
+	CreatedAt   time.Time
+}
+
+func (auditEvent) TableName() string {
+	return "audit_events"
+}
+
+// auditRecord is the JSON representation of a record in the audit log.
+type auditRecord struct {
+	ID         uint64     `json:"id"`
+	Owner      string     `json:"owner,omitempty"`
+	ClientID   string     `json:"client_id,omitempty"`
+	Revision   uint64     `json:"revision,omitempty"`
+	Weight     float32    `json:"weight"`
+	WeightedAt time.Time  `json:"weighted_at"`
+	TimeZone   string     `json:"time_zone,omitempty"`
+	UTCOffset  int32      `json:"utc_offset,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+	Source     string     `json:"source,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+}
+
+// audit adds the audit event for a change from before to after within tx,
+// and queues the webhook deliveries for it.
+func audit(ctx context.Context, tx *gorm.DB, before, after *store.Record) error {
+	e := store.NewAuditEvent(ctx, before, after)
+
+	r := auditEvent{
+		Action:    e.Action,
+		RecordID:  e.RecordID,
+		Owner:     e.Owner,
+		Principal: e.Principal,
+		Method:    e.Method,
+		RequestID: e.RequestID,
+	}
+
+	var err error
+	if r.BeforeValue, err = encodeAuditRecord(e.Before); err != nil {
+		return err
+	}
+
+	if r.AfterValue, err = encodeAuditRecord(e.After); err != nil {
+		return err
+	}
+
+	if err = tx.Create(&r).Error; err != nil {
+		return err
+	}
+
+	e.ID, e.CreatedAt = r.ID, r.CreatedAt
+
+	return queueDeliveries(ctx, tx, e)
+}
+
+// ListAuditEvents implements store.Store.
+func (s *Store) ListAuditEvents(ctx context.Context, opts store.AuditListOptions) ([]store.AuditEvent, error) {
+	db := s.db.WithContext(ctx).Where("owner = ?", opts.Owner)
+	if opts.RecordID != 0 {
+		db = db.Where("record_id = ?", opts.RecordID)
+	}
+
+	if opts.Principal != "" {
+		db = db.Where("principal = ?", opts.Principal)
+	}
+
+	if opts.Action != "" {
+		db = db.Where("action = ?", opts.Action)
+	}
+
+	if opts.From != nil {
+		db = db.Where("created_at >= ?", opts.From.UTC())
+	}
+
+	if opts.To != nil {
+		db = db.Where("created_at < ?", opts.To.UTC())
+	}
+
+	if opts.BeforeID != 0 {
+		db = db.Where("id < ?", opts.BeforeID)
+	}
+
+	if opts.Limit > 0 {
+		db = db.Limit(opts.Limit)
+	}
+
+	var rs []auditEvent
+	if err := db.Order("id DESC").Find(&rs).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	events := make([]store.AuditEvent, 0, len(rs))
+	for _, r := range rs {
+		e := store.AuditEvent{
+			ID:        r.ID,
+			Action:    r.Action,
+			RecordID:  r.RecordID,
+			Owner:     r.Owner,
+			Principal: r.Principal,
+			Method:    r.Method,
+			RequestID: r.RequestID,
+			CreatedAt: r.CreatedAt,
+		}
+
+		var err error
+		if e.Before, err = decodeAuditRecord(r.BeforeValue); err != nil {
+			return nil, err
+		}
+
+		if e.After, err = decodeAuditRecord(r.AfterValue); err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+func encodeAuditRecord(rec *store.Record) (*string, error) {
+	if rec == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(auditRecord{
+		ID:         rec.ID,
+		Owner:      rec.Owner,
+		ClientID:   rec.ClientID,
+		Revision:   rec.Revision,
+		Weight:     rec.Weight,
+		WeightedAt: rec.WeightedAt,
+		TimeZone:   rec.TimeZone,
+		UTCOffset:  rec.UTCOffset,
+		Tags:       rec.Tags,
+		Source:     rec.Source,
+		CreatedAt:  rec.CreatedAt,
+		UpdatedAt:  rec.UpdatedAt,
+		DeletedAt:  rec.DeletedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := string(data)
+
+	return &s, nil
+}
+
+func decodeAuditRecord(s *string) (*store.Record, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	var r auditRecord
+	if err := json.Unmarshal([]byte(*s), &r); err != nil {
+		return nil, err
+	}
+
+	return &store.Record{
+		ID:         r.ID,
+		Owner:      r.Owner,
+		ClientID:   r.ClientID,
+		Revision:   r.Revision,
+		Weight:     r.Weight,
+		WeightedAt: r.WeightedAt,
+		TimeZone:   r.TimeZone,
+		UTCOffset:  r.UTCOffset,
+		Tags:       r.Tags,
+		Source:     r.Source,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		DeletedAt:  r.DeletedAt,
+	}, nil
+}
@@ -0,0 +1,179 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+	"gorm.io/gorm"
+)
+
+type delivery struct {
+	ID             uint64 `gorm:"primaryKey"`
+	Webhook        string
+	Event          string
+	RecordID       uint64
+	Owner          string
+	Payload        string
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastError      string
+	LastStatusCode int
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (delivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// queueDeliveries queues the deliveries for the change described by e within
+// tx, see store.WithDeliveries.
+func queueDeliveries(ctx context.Context, tx *gorm.DB, e *store.AuditEvent) error {
+	deliveries, err := store.DeliveriesFor(ctx, e)
+	if err != nil || len(deliveries) == 0 {
+		return err
+	}
+
+	ds := make([]delivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		ds = append(ds, fromDelivery(d))
+	}
+
+	if err = tx.Create(&ds).Error; err != nil {
+		return err
+	}
+
+	for i := range ds {
+		*deliveries[i] = toDelivery(ds[i])
+	}
+
+	return nil
+}
+
+// ClaimDeliveries implements store.Store.
+func (s *Store) ClaimDeliveries(ctx context.Context, now, until time.Time, limit int) ([]store.Delivery, error) {
+	now, until = now.UTC(), until.UTC()
+
+	var ds []delivery
+	err := s.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", store.DeliveryPending, now).
+		Order("next_attempt_at").Order("id").
+		Limit(limit).
+		Find(&ds).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	claimed := make([]store.Delivery, 0, len(ds))
+	for _, d := range ds {
+		// only one server wins the update if several try to claim the same
+		// delivery
+		res := s.db.WithContext(ctx).Model(&delivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at <= ?", d.ID, store.DeliveryPending, now).
+			Update("next_attempt_at", until)
+		if res.Error != nil {
+			return nil, translateError(res.Error)
+		}
+
+		if res.RowsAffected == 1 {
+			d.NextAttemptAt = until
+			claimed = append(claimed, toDelivery(d))
+		}
+	}
+
+	return claimed, nil
+}
+
+// SaveDelivery implements store.Store.
+func (s *Store) SaveDelivery(ctx context.Context, d *store.Delivery) error {
+	r := fromDelivery(d)
+	if err := s.db.WithContext(ctx).Save(&r).Error; err != nil {
+		return translateError(err)
+	}
+
+	*d = toDelivery(r)
+
+	return nil
+}
+
+// ListDeliveries implements store.Store.
+func (s *Store) ListDeliveries(ctx context.Context, opts store.DeliveryListOptions) ([]store.Delivery, error) {
+	db := s.db.WithContext(ctx).Where("owner = ?", opts.Owner)
+	if opts.Webhook != "" {
+		db = db.Where("webhook = ?", opts.Webhook)
+	}
+
+	if opts.Status != "" {
+		db = db.Where("status = ?", opts.Status)
+	}
+
+	if opts.BeforeID != 0 {
+		db = db.Where("id < ?", opts.BeforeID)
+	}
+
+	if opts.Limit > 0 {
+		db = db.Limit(opts.Limit)
+	}
+
+	var ds []delivery
+	if err := db.Order("id DESC").Find(&ds).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	deliveries := make([]store.Delivery, 0, len(ds))
+	for _, d := range ds {
+		deliveries = append(deliveries, toDelivery(d))
+	}
+
+	return deliveries, nil
+}
+
+// DeleteDeliveries implements store.Store.
+func (s *Store) DeleteDeliveries(ctx context.Context, finishedBefore time.Time) (int, error) {
+	res := s.db.WithContext(ctx).
+		Where("status <> ? AND updated_at < ?", store.DeliveryPending, finishedBefore.UTC()).
+		Delete(&delivery{})
+	if res.Error != nil {
+		return 0, translateError(res.Error)
+	}
+
+	return int(res.RowsAffected), nil
+}
+
+func fromDelivery(d *store.Delivery) delivery {
+	return delivery{
+		ID:             d.ID,
+		Webhook:        d.Webhook,
+		Event:          d.Event,
+		RecordID:       d.RecordID,
+		Owner:          d.Owner,
+		Payload:        string(d.Payload),
+		Status:         string(d.Status),
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt.UTC(),
+		LastError:      d.LastError,
+		LastStatusCode: d.LastStatusCode,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+}
+
+func toDelivery(d delivery) store.Delivery {
+	return store.Delivery{
+		ID:             d.ID,
+		Webhook:        d.Webhook,
+		Event:          d.Event,
+		RecordID:       d.RecordID,
+		Owner:          d.Owner,
+		Payload:        []byte(d.Payload),
+		Status:         store.DeliveryStatus(d.Status),
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt.UTC(),
+		LastError:      d.LastError,
+		LastStatusCode: d.LastStatusCode,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+}
@@ -0,0 +1,93 @@
+package sqlstore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/filter"
+	"gorm.io/gorm/clause"
+)
+
+// filterColumns maps the fields of store.RecordFilterSchema to their column,
+// so only known columns ever end up in a query.
+var filterColumns = map[string]string{
+	"id":          "id",
+	"revision":    "revision",
+	"weight":      "weight",
+	"weighted_at": "weighted_at",
+	"local_date":  "local_date",
+	"time_zone":   "time_zone",
+	"utc_offset":  "utc_offset",
+	"source":      "source",
+	"created_at":  "created_at",
+	"updated_at":  "updated_at",
+}
+
+// filterCondition translates a parsed filter to a condition, binding every
+// value as a parameter.
+func filterCondition(expr filter.Expr) clause.Expression {
+	switch e := expr.(type) {
+	case filter.And:
+		return clause.AndConditions{Exprs: filterConditions(e.Exprs)}
+	case filter.Or:
+		return clause.OrConditions{Exprs: filterConditions(e.Exprs)}
+	case filter.Not:
+		// clause.Not distributes over AND conditions instead of negating them
+		return clause.Expr{SQL: "NOT (?)", Vars: []interface{}{filterCondition(e.Expr)}}
+	case filter.Comparison:
+		return comparisonCondition(e)
+	}
+
+	panic(fmt.Sprintf("sqlstore: unexpected filter expression %T", expr))
+}
+
+func filterConditions(exprs []filter.Expr) []clause.Expression {
+	conds := make([]clause.Expression, 0, len(exprs))
+	for _, expr := range exprs {
+		conds = append(conds, filterCondition(expr))
+	}
+
+	return conds
+}
+
+func comparisonCondition(c filter.Comparison) clause.Expression {
+	if c.Field == "tags" {
+		return clause.Expr{
+			SQL:  "id IN (SELECT record_id FROM record_tags WHERE tag = ?)",
+			Vars: []interface{}{c.Value},
+		}
+	}
+
+	column, ok := filterColumns[c.Field]
+	if !ok {
+		panic(fmt.Sprintf("sqlstore: unexpected filter field %q", c.Field))
+	}
+
+	var col interface{} = column
+	if column == "weight" {
+		// sqlite keeps weights as floats, round them as store.Record does
+		col = clause.Expr{SQL: "ROUND(weight, 2)"}
+	}
+
+	value := c.Value
+	if t, ok := value.(time.Time); ok {
+		value = t.UTC()
+	}
+
+	switch c.Op {
+	case filter.Equal:
+		return clause.Eq{Column: col, Value: value}
+	case filter.NotEqual:
+		return clause.Neq{Column: col, Value: value}
+	case filter.Less:
+		return clause.Lt{Column: col, Value: value}
+	case filter.LessEqual:
+		return clause.Lte{Column: col, Value: value}
+	case filter.Greater:
+		return clause.Gt{Column: col, Value: value}
+	case filter.GreaterEqual:
+		return clause.Gte{Column: col, Value: value}
+	}
+
+	panic(fmt.Sprintf("sqlstore: unexpected filter operator %q", c.Op))
+}
@@ -0,0 +1,133 @@
+package sqlstore_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/config"
+	"github.com/0gener/go-weight-tracker/server/database"
+	"github.com/0gener/go-weight-tracker/server/filter"
+	"github.com/0gener/go-weight-tracker/server/migrations"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/0gener/go-weight-tracker/server/store/sqlstore"
+)
+
+func openStore(t *testing.T) *sqlstore.Store {
+	t.Helper()
+
+	ctx := context.Background()
+
+	dbConfig := config.Default().Database
+	dbConfig.Driver = "sqlite"
+	dbConfig.Path = filepath.Join(t.TempDir(), "wt.db")
+
+	db, err := database.Open(ctx, dbConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := migrations.New(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	s := sqlstore.New(db)
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+// TestFilterMatchesEval checks that filters translated to SQL select the
+// same records as filter.Eval, which the other stores and WatchRecords use.
+func TestFilterMatchesEval(t *testing.T) {
+	ctx := context.Background()
+	s := openStore(t)
+
+	day := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+	lisbon := time.FixedZone("", 3600)
+	records := []store.Record{
+		{Weight: 80, WeightedAt: day.Add(7 * time.Hour), TimeZone: "UTC", Source: "scale", Tags: []string{"morning"}},
+		{Weight: 80.25, WeightedAt: day.Add(23*time.Hour + 30*time.Minute), TimeZone: "Europe/Lisbon", UTCOffset: 3600, Source: "app", Tags: []string{"evening", "travel"}},
+		{Weight: 81.5, WeightedAt: time.Date(2024, 6, 16, 0, 30, 0, 0, lisbon).UTC(), TimeZone: "Europe/Lisbon", UTCOffset: 3600, Source: "app"},
+		{Weight: 79.99, WeightedAt: day.AddDate(0, 0, 2), TimeZone: "America/New_York", UTCOffset: -4 * 3600, Source: "scale", Tags: []string{"travel"}},
+		{Weight: 82, WeightedAt: day.AddDate(0, 1, 0), TimeZone: "UTC", Source: "App"},
+	}
+
+	for i := range records {
+		if err := s.CreateRecord(ctx, &records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// read them back, so they carry what the database stored
+	all, err := s.ListRecords(ctx, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filters := []string{
+		"weight = 80",
+		"weight = 80.25",
+		"weight != 79.99",
+		"weight > 80",
+		"weight >= 80.25 AND weight < 82",
+		"weight <= 80 OR weight >= 82",
+		`weighted_at >= "2024-06-15T23:30:00Z"`,
+		"weighted_at < 2024-06-16",
+		`weighted_at > "2024-06-16T00:30:00+01:00"`,
+		"local_date = 2024-06-16",
+		"local_date >= 2024-06-16 AND local_date < 2024-07-01",
+		`time_zone = "Europe/Lisbon"`,
+		"utc_offset < 0",
+		"source = app",
+		"source > app",
+		"source != scale",
+		"tags:travel",
+		"tags:travel tags:evening",
+		"NOT tags:travel",
+		"-tags:morning AND -tags:travel",
+		"tags:missing",
+		"NOT (source = scale OR weight > 81)",
+		"source = app OR weight < 80 AND NOT tags:travel",
+		"id > 2 AND revision = 1",
+		"created_at > 2000-01-01",
+		"updated_at < 2000-01-01",
+	}
+
+	for _, input := range filters {
+		expr, err := filter.Parse(input, store.RecordFilterSchema)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", input, err)
+		}
+
+		opts := store.ListOptions{Filter: expr}
+
+		var want []uint64
+		for _, rec := range all {
+			if opts.Matches(rec) {
+				want = append(want, rec.ID)
+			}
+		}
+
+		recs, err := s.ListRecords(ctx, opts)
+		if err != nil {
+			t.Fatalf("ListRecords(%q) failed: %v", input, err)
+		}
+
+		var got []uint64
+		for _, rec := range recs {
+			got = append(got, rec.ID)
+		}
+
+		if !slices.Equal(got, want) {
+			t.Errorf("ListRecords(%q) returned records %v, Eval matches %v", input, got, want)
+		}
+	}
+}
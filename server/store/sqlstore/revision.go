@@ -0,0 +1,82 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+	"gorm.io/gorm"
+)
+
+type recordRevision struct {
+	RecordID   uint64    `gorm:"primaryKey;autoIncrement:false"`
+	Revision   uint64    `gorm:"primaryKey;autoIncrement:false"`
+	Weight     float32   `gorm:"type:decimal(5,2);not null"`
+	WeightedAt time.Time `gorm:"not null"`
+	TimeZone   string    `gorm:"size:64;not null"`
+	UTCOffset  int32     `gorm:"not null"`
+	CreatedAt  time.Time `gorm:"not null"`
+}
+
+func (recordRevision) TableName() string {
+	return "record_revisions"
+}
+
+// addRevision stores the current values of rec as a revision within tx.
+func addRevision(tx *gorm.DB, rec *store.Record) error {
+	rev := store.NewRevision(rec)
+
+	return tx.Create(&recordRevision{
+		RecordID:   rev.RecordID,
+		Revision:   rev.Revision,
+		Weight:     rev.Weight,
+		WeightedAt: rev.WeightedAt.UTC(),
+		TimeZone:   rev.TimeZone,
+		UTCOffset:  rev.UTCOffset,
+		CreatedAt:  rev.CreatedAt,
+	}).Error
+}
+
+// ListRevisions implements store.Store.
+func (s *Store) ListRevisions(ctx context.Context, owner string, id uint64, opts store.RevisionListOptions) ([]store.Revision, error) {
+	var rs []recordRevision
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id").Where("owner = ?", owner).First(&record{}, id).Error; err != nil {
+			return err
+		}
+
+		db := tx.Where("record_id = ?", id)
+		if opts.At != nil {
+			db = db.Where("created_at <= ?", opts.At.UTC())
+		}
+
+		if opts.BeforeRevision != 0 {
+			db = db.Where("revision < ?", opts.BeforeRevision)
+		}
+
+		if opts.Limit > 0 {
+			db = db.Limit(opts.Limit)
+		}
+
+		return db.Order("revision DESC").Find(&rs).Error
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	revs := make([]store.Revision, 0, len(rs))
+	for _, r := range rs {
+		revs = append(revs, store.Revision{
+			RecordID:   r.RecordID,
+			Revision:   r.Revision,
+			Weight:     r.Weight,
+			WeightedAt: r.WeightedAt.UTC(),
+			TimeZone:   r.TimeZone,
+			UTCOffset:  r.UTCOffset,
+			CreatedAt:  r.CreatedAt,
+		})
+	}
+
+	return revs, nil
+}
@@ -0,0 +1,59 @@
+package sqlstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type userSettings struct {
+	Principal string `gorm:"primaryKey;size:255"`
+	TimeZone  string `gorm:"size:64;not null"`
+	UpdatedAt time.Time
+}
+
+func (userSettings) TableName() string {
+	return "user_settings"
+}
+
+// ReadSettings implements store.Store.
+func (s *Store) ReadSettings(ctx context.Context, principal string) (*store.UserSettings, error) {
+	var r userSettings
+	err := s.db.WithContext(ctx).Where("principal = ?", principal).First(&r).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &store.UserSettings{Principal: principal}, nil
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return &store.UserSettings{
+		Principal: r.Principal,
+		TimeZone:  r.TimeZone,
+		UpdatedAt: r.UpdatedAt,
+	}, nil
+}
+
+// SaveSettings implements store.Store.
+func (s *Store) SaveSettings(ctx context.Context, settings *store.UserSettings) error {
+	r := userSettings{
+		Principal: settings.Principal,
+		TimeZone:  settings.TimeZone,
+	}
+
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "principal"}},
+		UpdateAll: true,
+	}).Create(&r).Error
+	if err != nil {
+		return translateError(err)
+	}
+
+	settings.UpdatedAt = r.UpdatedAt
+
+	return nil
+}
@@ -0,0 +1,430 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type record struct {
+	gorm.Model
+	Owner      string    `gorm:"size:255;not null;uniqueIndex:idx_records_owner_client_id"`
+	ClientID   *string   `gorm:"size:64;uniqueIndex:idx_records_owner_client_id"`
+	Revision   uint64    `gorm:"not null;default:1"`
+	Weight     float32   `gorm:"type:decimal(5,2);not null"`
+	WeightedAt time.Time `gorm:"not null"`
+	TimeZone   string    `gorm:"size:64;not null"`
+	UTCOffset  int32     `gorm:"not null"`
+	LocalDate  string    `gorm:"size:10;not null;index"` // derived from WeightedAt and UTCOffset
+	Source     string    `gorm:"size:64;not null;index"`
+}
+
+func (record) TableName() string {
+	return "records"
+}
+
+// Store is a store.Store backed by a SQL database through gorm. The schema
+// is expected to be managed by the migrations package.
+type Store struct {
+	db *gorm.DB
+}
+
+var _ store.Store = (*Store)(nil)
+
+// New creates a store using db.
+func New(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateRecord implements store.Store.
+func (s *Store) CreateRecord(ctx context.Context, rec *store.Record) error {
+	r := fromRecord(rec)
+	r.Revision = 1
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&r).Error; err != nil {
+			return err
+		}
+
+		if err := saveTags(tx, uint64(r.ID), rec.Tags); err != nil {
+			return err
+		}
+
+		created := toRecord(r)
+		created.Tags = rec.Tags
+
+		if err := addRevision(tx, &created); err != nil {
+			return err
+		}
+
+		return audit(ctx, tx, nil, &created)
+	})
+	if err != nil {
+		return translateError(err)
+	}
+
+	tags := rec.Tags
+	*rec = toRecord(r)
+	rec.Tags = tags
+
+	return nil
+}
+
+// ReadRecord implements store.Store.
+func (s *Store) ReadRecord(ctx context.Context, owner string, id uint64) (*store.Record, error) {
+	db := s.db.WithContext(ctx)
+
+	var r record
+	if err := db.Where("owner = ?", owner).First(&r, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	return withTags(db, r)
+}
+
+// ReadRecordByClientID implements store.Store.
+func (s *Store) ReadRecordByClientID(ctx context.Context, owner, clientID string) (*store.Record, error) {
+	db := s.db.WithContext(ctx)
+
+	var r record
+	if err := db.Unscoped().Where("owner = ? AND client_id = ?", owner, clientID).First(&r).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	return withTags(db, r)
+}
+
+// UpdateRecord implements store.Store.
+func (s *Store) UpdateRecord(ctx context.Context, owner string, id uint64, update func(*store.Record) error) (*store.Record, error) {
+	var rec store.Record
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the row stays locked until the transaction ends, so concurrent
+		// updates are applied one after the other instead of overwriting
+		// each other
+		var r record
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("owner = ?", owner).First(&r, id).Error; err != nil {
+			return translateError(err)
+		}
+
+		prev, err := withTags(tx, r)
+		if err != nil {
+			return err
+		}
+
+		rec = *prev
+		rec.Tags = slices.Clone(prev.Tags)
+		if err := update(&rec); err != nil {
+			return err
+		}
+
+		rec.ID, rec.Owner, rec.ClientID, rec.Revision, rec.CreatedAt = prev.ID, prev.Owner, prev.ClientID, prev.Revision, prev.CreatedAt
+
+		revised := store.Revised(prev, &rec)
+		if revised {
+			rec.Revision++
+		}
+
+		r = fromRecord(&rec)
+		if err := tx.Save(&r).Error; err != nil {
+			return translateError(err)
+		}
+
+		tags := rec.Tags
+		rec = toRecord(r)
+		rec.Tags = tags
+
+		if !slices.Equal(prev.Tags, rec.Tags) {
+			if err := saveTags(tx, id, rec.Tags); err != nil {
+				return err
+			}
+		}
+
+		if revised {
+			if err := addRevision(tx, &rec); err != nil {
+				return err
+			}
+		}
+
+		return audit(ctx, tx, prev, &rec)
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return &rec, nil
+}
+
+// DeleteRecord implements store.Store.
+func (s *Store) DeleteRecord(ctx context.Context, owner string, id uint64) (*store.Record, error) {
+	var rec store.Record
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var r record
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("owner = ?", owner).First(&r, id).Error; err != nil {
+			return translateError(err)
+		}
+
+		deleted, err := withTags(tx, r)
+		if err != nil {
+			return err
+		}
+
+		// a plain soft delete would leave updated_at alone, and ListChanges
+		// would never return the deleted record
+		now := tx.NowFunc()
+
+		res := tx.Model(&record{}).Where("id = ?", id).Updates(map[string]interface{}{"deleted_at": now, "updated_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+
+		// deleted concurrently since it was read
+		if res.RowsAffected == 0 {
+			return store.ErrNotFound
+		}
+
+		rec = *deleted
+
+		return audit(ctx, tx, &rec, nil)
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return &rec, nil
+}
+
+// ListRecords implements store.Store.
+func (s *Store) ListRecords(ctx context.Context, opts store.ListOptions) ([]store.Record, error) {
+	db := s.db.WithContext(ctx)
+
+	conds := listConditions(opts)
+	if opts.After != nil {
+		conds = append(conds, afterCondition(opts))
+	}
+
+	query := db.Clauses(clause.Where{Exprs: conds})
+	if opts.Latest {
+		query = query.Order(clause.OrderBy{Columns: []clause.OrderByColumn{
+			{Column: clause.Column{Name: "weighted_at"}, Desc: true},
+			{Column: clause.Column{Name: "id"}, Desc: true},
+		}})
+	} else {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}})
+	}
+
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit)
+	}
+
+	var rs []record
+	if err := query.Find(&rs).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	return toRecordsWithTags(db, rs)
+}
+
+// afterCondition selects the records ordered after opts.After.
+func afterCondition(opts store.ListOptions) clause.Expression {
+	if !opts.Latest {
+		return clause.Gt{Column: "id", Value: opts.After.ID}
+	}
+
+	weightedAt := opts.After.WeightedAt.UTC()
+
+	return clause.Or(
+		clause.Lt{Column: "weighted_at", Value: weightedAt},
+		clause.And(
+			clause.Eq{Column: "weighted_at", Value: weightedAt},
+			clause.Lt{Column: "id", Value: opts.After.ID},
+		),
+	)
+}
+
+// CountRecords implements store.Store.
+func (s *Store) CountRecords(ctx context.Context, opts store.ListOptions) (int, error) {
+	var n int64
+	err := s.db.WithContext(ctx).Model(&record{}).Clauses(clause.Where{Exprs: listConditions(opts)}).Count(&n).Error
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	return int(n), nil
+}
+
+// listConditions builds the conditions for opts out of clauses, so values
+// are always bound as parameters and never written into the query.
+func listConditions(opts store.ListOptions) []clause.Expression {
+	conds := []clause.Expression{clause.Eq{Column: "owner", Value: opts.Owner}}
+
+	if opts.WeightedAtFrom != nil {
+		from := opts.WeightedAtFrom.UTC()
+		if opts.WeightedAtFromInclusive {
+			conds = append(conds, clause.Gte{Column: "weighted_at", Value: from})
+		} else {
+			conds = append(conds, clause.Gt{Column: "weighted_at", Value: from})
+		}
+	}
+
+	if opts.WeightedAtTo != nil {
+		to := opts.WeightedAtTo.UTC()
+		if opts.WeightedAtToInclusive {
+			conds = append(conds, clause.Lte{Column: "weighted_at", Value: to})
+		} else {
+			conds = append(conds, clause.Lt{Column: "weighted_at", Value: to})
+		}
+	}
+
+	if opts.LocalDateFrom != "" {
+		conds = append(conds, clause.Gte{Column: "local_date", Value: opts.LocalDateFrom})
+	}
+
+	if opts.LocalDateTo != "" {
+		conds = append(conds, clause.Lte{Column: "local_date", Value: opts.LocalDateTo})
+	}
+
+	if opts.MinWeight != nil {
+		conds = append(conds, clause.Gte{Column: "weight", Value: *opts.MinWeight})
+	}
+
+	if opts.MaxWeight != nil {
+		conds = append(conds, clause.Lte{Column: "weight", Value: *opts.MaxWeight})
+	}
+
+	if opts.Source != "" {
+		conds = append(conds, clause.Eq{Column: "source", Value: opts.Source})
+	}
+
+	if len(opts.Tags) > 0 {
+		tags := slices.Clone(opts.Tags)
+		slices.Sort(tags)
+		tags = slices.Compact(tags)
+
+		// records carrying every tag
+		conds = append(conds, clause.Expr{
+			SQL:  "id IN (SELECT record_id FROM record_tags WHERE tag IN ? GROUP BY record_id HAVING COUNT(*) = ?)",
+			Vars: []interface{}{tags, len(tags)},
+		})
+	}
+
+	if opts.Filter != nil {
+		conds = append(conds, filterCondition(opts.Filter))
+	}
+
+	return conds
+}
+
+// ListChanges implements store.Store.
+func (s *Store) ListChanges(ctx context.Context, owner string, since time.Time) ([]store.Record, error) {
+	db := s.db.WithContext(ctx)
+
+	var rs []record
+	err := db.Unscoped().
+		Where("owner = ? AND updated_at > ?", owner, since.UTC()).
+		Order("updated_at").Order("id").
+		Find(&rs).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return toRecordsWithTags(db, rs)
+}
+
+// Close implements store.Store.
+func (s *Store) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
+
+// translateError converts gorm and driver errors to the errors defined by
+// the store package, keeping the original error wrapped for logging.
+func translateError(err error) error {
+	var opErr *net.OpError
+
+	switch {
+	case errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrAlreadyExists) || errors.Is(err, store.ErrUnavailable):
+		return err
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return store.ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return fmt.Errorf("%w: %w", store.ErrAlreadyExists, err)
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, mysql.ErrInvalidConn), errors.Is(err, syscall.ECONNREFUSED),
+		errors.As(err, &opErr):
+		return fmt.Errorf("%w: %w", store.ErrUnavailable, err)
+	}
+
+	return err
+}
+
+func fromRecord(rec *store.Record) record {
+	r := record{
+		Model: gorm.Model{
+			ID:        uint(rec.ID),
+			CreatedAt: rec.CreatedAt,
+			UpdatedAt: rec.UpdatedAt,
+		},
+		Owner:      rec.Owner,
+		Revision:   rec.Revision,
+		Weight:     rec.Weight,
+		WeightedAt: rec.WeightedAt.UTC(),
+		TimeZone:   rec.TimeZone,
+		UTCOffset:  rec.UTCOffset,
+		LocalDate:  rec.LocalDate(),
+		Source:     rec.Source,
+	}
+
+	if rec.ClientID != "" {
+		clientID := rec.ClientID
+		r.ClientID = &clientID
+	}
+
+	if rec.DeletedAt != nil {
+		r.DeletedAt = gorm.DeletedAt{Time: *rec.DeletedAt, Valid: true}
+	}
+
+	return r
+}
+
+func toRecord(r record) store.Record {
+	rec := store.Record{
+		ID:         uint64(r.ID),
+		Owner:      r.Owner,
+		Revision:   r.Revision,
+		Weight:     r.Weight,
+		WeightedAt: r.WeightedAt.UTC(),
+		TimeZone:   r.TimeZone,
+		UTCOffset:  r.UTCOffset,
+		Source:     r.Source,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+	}
+
+	if r.ClientID != nil {
+		rec.ClientID = *r.ClientID
+	}
+
+	if r.DeletedAt.Valid {
+		deletedAt := r.DeletedAt.Time
+		rec.DeletedAt = &deletedAt
+	}
+
+	return rec
+}
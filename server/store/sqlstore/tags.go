@@ -0,0 +1,74 @@
+package sqlstore
+
+import (
+	"github.com/0gener/go-weight-tracker/server/store"
+	"gorm.io/gorm"
+)
+
+type recordTag struct {
+	RecordID uint64 `gorm:"primaryKey;autoIncrement:false"`
+	Tag      string `gorm:"primaryKey;size:32"`
+}
+
+func (recordTag) TableName() string {
+	return "record_tags"
+}
+
+// saveTags replaces the tags of the record with the given id within tx.
+func saveTags(tx *gorm.DB, id uint64, tags []string) error {
+	if err := tx.Where("record_id = ?", id).Delete(&recordTag{}).Error; err != nil {
+		return err
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	rs := make([]recordTag, 0, len(tags))
+	for _, tag := range tags {
+		rs = append(rs, recordTag{RecordID: id, Tag: tag})
+	}
+
+	return tx.Create(&rs).Error
+}
+
+// withTags converts r and loads its tags.
+func withTags(db *gorm.DB, r record) (*store.Record, error) {
+	recs, err := toRecordsWithTags(db, []record{r})
+	if err != nil {
+		return nil, err
+	}
+
+	return &recs[0], nil
+}
+
+// toRecordsWithTags converts rs and loads their tags with a single query.
+func toRecordsWithTags(db *gorm.DB, rs []record) ([]store.Record, error) {
+	recs := make([]store.Record, 0, len(rs))
+	if len(rs) == 0 {
+		return recs, nil
+	}
+
+	ids := make([]uint64, 0, len(rs))
+	for _, r := range rs {
+		ids = append(ids, uint64(r.ID))
+	}
+
+	var tags []recordTag
+	if err := db.Where("record_id IN ?", ids).Order("record_id").Order("tag").Find(&tags).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	byRecord := map[uint64][]string{}
+	for _, t := range tags {
+		byRecord[t.RecordID] = append(byRecord[t.RecordID], t.Tag)
+	}
+
+	for _, r := range rs {
+		rec := toRecord(r)
+		rec.Tags = byRecord[rec.ID]
+		recs = append(recs, rec)
+	}
+
+	return recs, nil
+}
@@ -0,0 +1,215 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/filter"
+)
+
+var (
+	// ErrNotFound is returned when what was asked for, e.g. a record or an
+	// account, does not exist or has been deleted.
+	ErrNotFound = errors.New("not found")
+
+	// ErrAlreadyExists is returned when a change conflicts with something
+	// that is already stored.
+	ErrAlreadyExists = errors.New("already exists")
+
+	// ErrUnavailable wraps errors caused by the storage being unreachable,
+	// e.g. a lost database connection. Retrying later may succeed.
+	ErrUnavailable = errors.New("storage unavailable")
+)
+
+// Record is a weight measurement.
+type Record struct {
+	ID         uint64
+	Owner      string // principal the record belongs to, empty for unauthenticated callers
+	ClientID   string // optional, unique per owner, assigned by the client that created it
+	Revision   uint64 // starts at 1, bumped by the store whenever Weight, WeightedAt or the time zone change
+	Weight     float32
+	WeightedAt time.Time
+	TimeZone   string   // IANA zone WeightedAt was recorded in, empty if only UTCOffset is known
+	UTCOffset  int32    // seconds east of UTC at WeightedAt
+	Tags       []string // sorted, without duplicates
+	Source     string   // what produced the record, e.g. a scale or an app
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  *time.Time
+}
+
+// SetTimeZone sets the zone WeightedAt was recorded in, either an IANA zone
+// or, if zone is empty, a fixed offset in seconds east of UTC. The offset of
+// a zone depends on WeightedAt, so it must be set again whenever WeightedAt
+// changes.
+func (r *Record) SetTimeZone(zone string, offset int32) error {
+	if zone == "" {
+		r.TimeZone, r.UTCOffset = "", offset
+		return nil
+	}
+
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return err
+	}
+
+	_, off := r.WeightedAt.In(loc).Zone()
+	r.TimeZone, r.UTCOffset = zone, int32(off)
+
+	return nil
+}
+
+// LocalDate returns the date, formatted as time.DateOnly, WeightedAt fell on
+// where the record was taken.
+func (r Record) LocalDate() string {
+	return r.WeightedAt.In(time.FixedZone("", int(r.UTCOffset))).Format(time.DateOnly)
+}
+
+// ListOptions filters the records returned by ListRecords. Nil and empty
+// filters are ignored, except for Owner.
+type ListOptions struct {
+	Owner                   string     // only records of this owner, even if empty
+	WeightedAtFrom          *time.Time // exclusive unless WeightedAtFromInclusive
+	WeightedAtFromInclusive bool
+	WeightedAtTo            *time.Time // exclusive unless WeightedAtToInclusive
+	WeightedAtToInclusive   bool
+	LocalDateFrom           string   // inclusive, formatted as time.DateOnly
+	LocalDateTo             string   // inclusive, formatted as time.DateOnly
+	MinWeight               *float32 // inclusive
+	MaxWeight               *float32 // inclusive
+	Tags                    []string // records carrying all of them
+	Source                  string
+	Filter                  filter.Expr // parsed with RecordFilterSchema
+
+	// Latest orders records from the newest to the oldest weighted_at,
+	// instead of by id, so that with Limit the latest ones are returned.
+	Latest bool
+	Limit  int     // 0 means no limit
+	After  *Record // only records ordered after it, for paging
+}
+
+// Less reports whether a is ordered before b by ListRecords.
+func (o ListOptions) Less(a, b Record) bool {
+	if o.Latest && !a.WeightedAt.Equal(b.WeightedAt) {
+		return a.WeightedAt.After(b.WeightedAt)
+	}
+
+	if o.Latest {
+		return a.ID > b.ID
+	}
+
+	return a.ID < b.ID
+}
+
+// Matches reports whether rec matches the filters of o. Latest, Limit and
+// After are not taken into account.
+func (o ListOptions) Matches(rec Record) bool {
+	if rec.Owner != o.Owner {
+		return false
+	}
+
+	if o.WeightedAtFrom != nil && (rec.WeightedAt.Before(*o.WeightedAtFrom) ||
+		(!o.WeightedAtFromInclusive && rec.WeightedAt.Equal(*o.WeightedAtFrom))) {
+		return false
+	}
+
+	if o.WeightedAtTo != nil && (rec.WeightedAt.After(*o.WeightedAtTo) ||
+		(!o.WeightedAtToInclusive && rec.WeightedAt.Equal(*o.WeightedAtTo))) {
+		return false
+	}
+
+	if o.MinWeight != nil && rec.Weight < *o.MinWeight {
+		return false
+	}
+
+	if o.MaxWeight != nil && rec.Weight > *o.MaxWeight {
+		return false
+	}
+
+	if o.Source != "" && rec.Source != o.Source {
+		return false
+	}
+
+	for _, tag := range o.Tags {
+		if !slices.Contains(rec.Tags, tag) {
+			return false
+		}
+	}
+
+	if !filter.Eval(o.Filter, rec.filterValue) {
+		return false
+	}
+
+	if o.LocalDateFrom != "" && rec.LocalDate() < o.LocalDateFrom {
+		return false
+	}
+
+	if o.LocalDateTo != "" && rec.LocalDate() > o.LocalDateTo {
+		return false
+	}
+
+	return true
+}
+
+// Store persists everything the server keeps.
+type Store interface {
+	RecordStore
+	RevisionStore
+	SettingsStore
+	DeliveryStore
+	AuditStore
+	AccountStore
+
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// RecordStore persists records. Deleted records are only soft deleted and
+// are not visible through any of these operations unless stated otherwise.
+// Records only ever belong to the owner they are created with, and the
+// records of other owners are never returned, nor changed, as if they did
+// not exist.
+// Every change is recorded in the audit log together with the actor carried
+// by its context, every new revision in the record's revisions, and the
+// webhook deliveries for it are queued as set by WithDeliveries, all in the
+// same transaction.
+type RecordStore interface {
+	// CreateRecord stores rec, owned by rec.Owner, and fills in its ID and
+	// timestamps.
+	CreateRecord(ctx context.Context, rec *Record) error
+
+	// ReadRecord returns the record of owner with the given id, or
+	// ErrNotFound.
+	ReadRecord(ctx context.Context, owner string, id uint64) (*Record, error)
+
+	// ReadRecordByClientID returns the record of owner with the given client
+	// id, even if it has been deleted, or ErrNotFound.
+	ReadRecordByClientID(ctx context.Context, owner, clientID string) (*Record, error)
+
+	// UpdateRecord atomically reads the record of owner with the given id,
+	// applies update to it and stores the result, which is returned. It
+	// returns ErrNotFound if the record does not exist and the error
+	// returned by update, if any, without storing anything. Setting
+	// DeletedAt deletes the record. ID, Owner, ClientID, Revision and
+	// CreatedAt cannot be changed. Changing only Tags or Source does not add
+	// a revision.
+	UpdateRecord(ctx context.Context, owner string, id uint64, update func(*Record) error) (*Record, error)
+
+	// DeleteRecord deletes the record of owner with the given id and returns
+	// it as it was right before, or returns ErrNotFound.
+	DeleteRecord(ctx context.Context, owner string, id uint64) (*Record, error)
+
+	// ListRecords returns the records matching opts ordered by id, or by
+	// weighted_at and id descending if opts.Latest is set.
+	ListRecords(ctx context.Context, opts ListOptions) ([]Record, error)
+
+	// CountRecords returns how many records match the filters of opts,
+	// ignoring Latest, Limit and After.
+	CountRecords(ctx context.Context, opts ListOptions) (int, error)
+
+	// ListChanges returns the records of owner, including deleted ones,
+	// updated after since ordered by update time and id.
+	ListChanges(ctx context.Context, owner string, since time.Time) ([]Record, error)
+}
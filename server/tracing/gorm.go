@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "tracing:span"
+
+// GormPlugin returns a gorm plugin that wraps every query in a span. Queries
+// must be run with db.WithContext for the span to join the caller's trace.
+func GormPlugin() gorm.Plugin {
+	return gormPlugin{}
+}
+
+type gormPlugin struct{}
+
+func (gormPlugin) Name() string {
+	return "tracing"
+}
+
+func (p gormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+
+	if err := cb.Create().Before("gorm:create").Register("tracing:before_create", p.before("create")); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Register("tracing:after_create", p.after); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("tracing:before_query", p.before("query")); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register("tracing:after_query", p.after); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("tracing:before_update", p.before("update")); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("tracing:after_update", p.after); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("tracing:before_delete", p.before("delete")); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("tracing:after_delete", p.after); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("tracing:before_row", p.before("row")); err != nil {
+		return err
+	}
+	if err := cb.Row().After("gorm:row").Register("tracing:after_row", p.after); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("gorm:raw").Register("tracing:before_raw", p.before("raw")); err != nil {
+		return err
+	}
+
+	return cb.Raw().After("gorm:raw").Register("tracing:after_raw", p.after)
+}
+
+func (gormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		_, span := tracer().Start(db.Statement.Context, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemKey.String(db.Dialector.Name()),
+				semconv.DBOperationName(operation),
+			),
+		)
+
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func (gormPlugin) after(db *gorm.DB) {
+	v, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+
+	span := v.(trace.Span)
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBCollectionName(db.Statement.Table),
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(otelcodes.Error, db.Error.Error())
+	}
+}
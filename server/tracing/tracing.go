@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/0gener/go-weight-tracker/server/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName = "go-weight-tracker"
+	tracerName  = "github.com/0gener/go-weight-tracker/server/tracing"
+)
+
+// Init installs the global tracer provider and propagator described by
+// tracingConfig. The returned function flushes pending spans and must be
+// called before the process exits.
+func Init(tracingConfig config.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !tracingConfig.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	w, closeWriter, err := exporterWriter(tracingConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		closeWriter()
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		defer closeWriter()
+		return tp.Shutdown(ctx)
+	}, nil
+}
+
+func exporterWriter(tracingConfig config.TracingConfig) (io.Writer, func() error, error) {
+	switch tracingConfig.Exporter {
+	case "", "stdout":
+		return os.Stdout, func() error { return nil }, nil
+	case "file":
+		if tracingConfig.File == "" {
+			return nil, nil, fmt.Errorf("tracing file is required when exporter is file")
+		}
+
+		f, err := os.OpenFile(tracingConfig.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open tracing file: %w", err)
+		}
+
+		return f, f.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown tracing exporter %q", tracingConfig.Exporter)
+	}
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
@@ -0,0 +1,222 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/config"
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FieldViolation describes why a single request field is invalid. Field is
+// a path to the field, e.g. "record.weight".
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// Error holds every violation found in a request. It converts to an
+// INVALID_ARGUMENT status carrying a google.rpc.BadRequest detail, so
+// handlers can return it as is.
+type Error struct {
+	Violations []FieldViolation
+}
+
+func (e *Error) Error() string {
+	descriptions := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		descriptions = append(descriptions, v.Field+": "+v.Description)
+	}
+
+	return "invalid argument: " + strings.Join(descriptions, "; ")
+}
+
+// GRPCStatus implements the interface used by the status package.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(codes.InvalidArgument, e.Error())
+
+	br := &errdetails.BadRequest{}
+	for _, v := range e.Violations {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+
+	if withDetails, err := st.WithDetails(br); err == nil {
+		return withDetails
+	}
+
+	return st
+}
+
+// Validator checks records against the configured bounds. It is shared by
+// every handler that accepts records from clients.
+type Validator struct {
+	conf config.ValidationConfig
+	now  func() time.Time
+}
+
+// New creates a validator for validationConfig. now is used to check that
+// timestamps are not in the future.
+func New(validationConfig config.ValidationConfig, now func() time.Time) *Validator {
+	return &Validator{conf: validationConfig, now: now}
+}
+
+// ValidateNewRecord checks a record about to be created. A missing
+// weighted_at is allowed since it defaults to the current time.
+func (v *Validator) ValidateNewRecord(field string, rec *weighttracker.Record) error {
+	if rec == nil {
+		return &Error{Violations: []FieldViolation{{field, "is required"}}}
+	}
+
+	violations := v.weight(field+".weight", rec.GetWeight())
+
+	// client ids are stored as VARCHAR(64)
+	if len(rec.GetClientId()) > 64 {
+		violations = append(violations, FieldViolation{field + ".client_id", "must be at most 64 characters"})
+	}
+
+	if rec.GetWeightedAt() != nil {
+		violations = append(violations, v.weightedAt(field+".weighted_at", rec)...)
+	}
+
+	violations = append(violations, timeZone(field, rec)...)
+	violations = append(violations, labels(field, rec)...)
+
+	return toError(violations)
+}
+
+// ValidateRecordUpdate checks a partial record used to update an existing
+// one. Zero valued fields are left unchanged and therefore not checked.
+func (v *Validator) ValidateRecordUpdate(field string, rec *weighttracker.Record) error {
+	if rec == nil {
+		return &Error{Violations: []FieldViolation{{field, "is required"}}}
+	}
+
+	var violations []FieldViolation
+
+	if rec.GetId() == 0 {
+		violations = append(violations, FieldViolation{field + ".id", "is required"})
+	}
+
+	if rec.GetWeight() != 0 {
+		violations = append(violations, v.weight(field+".weight", rec.GetWeight())...)
+	}
+
+	if rec.GetWeightedAt() != nil {
+		violations = append(violations, v.weightedAt(field+".weighted_at", rec)...)
+	}
+
+	violations = append(violations, timeZone(field, rec)...)
+	violations = append(violations, labels(field, rec)...)
+
+	return toError(violations)
+}
+
+// ValidateTimeZone checks an IANA time zone name. An empty name is valid.
+func ValidateTimeZone(field, zone string) error {
+	return toError(zoneName(field, zone))
+}
+
+func (v *Validator) weight(field string, weight float32) []FieldViolation {
+	w := float64(weight)
+
+	switch {
+	case math.IsNaN(w) || math.IsInf(w, 0):
+		return []FieldViolation{{field, "must be a finite number"}}
+	case w < v.conf.MinWeight || w > v.conf.MaxWeight:
+		return []FieldViolation{{field, fmt.Sprintf("must be between %g and %g", v.conf.MinWeight, v.conf.MaxWeight)}}
+	}
+
+	return nil
+}
+
+func (v *Validator) weightedAt(field string, rec *weighttracker.Record) []FieldViolation {
+	if err := rec.GetWeightedAt().CheckValid(); err != nil {
+		return []FieldViolation{{field, "must be a valid timestamp"}}
+	}
+
+	t := rec.GetWeightedAt().AsTime()
+
+	if t.Before(v.conf.MinWeightedAt) {
+		return []FieldViolation{{field, fmt.Sprintf("must not be before %v", v.conf.MinWeightedAt.Format(time.RFC3339))}}
+	}
+
+	if t.After(v.now().Add(v.conf.MaxFutureSkew)) {
+		return []FieldViolation{{field, "must not be in the future"}}
+	}
+
+	return nil
+}
+
+// maxUTCOffset is the largest offset from UTC, in seconds, that is accepted.
+const maxUTCOffset = 18 * 60 * 60
+
+func timeZone(field string, rec *weighttracker.Record) []FieldViolation {
+	violations := zoneName(field+".time_zone", rec.GetTimeZone())
+
+	if rec.GetTimeZone() != "" && rec.GetUtcOffset() != 0 {
+		violations = append(violations, FieldViolation{field + ".utc_offset", "must not be set together with time_zone"})
+	}
+
+	if rec.GetUtcOffset() < -maxUTCOffset || rec.GetUtcOffset() > maxUTCOffset {
+		violations = append(violations, FieldViolation{field + ".utc_offset", "must be between -18 and 18 hours"})
+	}
+
+	return violations
+}
+
+// Tags and sources are stored as VARCHAR(32) and VARCHAR(64).
+const (
+	maxTags         = 16
+	maxTagLength    = 32
+	maxSourceLength = 64
+)
+
+func labels(field string, rec *weighttracker.Record) []FieldViolation {
+	var violations []FieldViolation
+
+	if len(rec.GetTags()) > maxTags {
+		violations = append(violations, FieldViolation{field + ".tags", fmt.Sprintf("must have at most %d tags", maxTags)})
+	}
+
+	for i, tag := range rec.GetTags() {
+		if tag == "" || len(tag) > maxTagLength {
+			violations = append(violations, FieldViolation{fmt.Sprintf("%v.tags[%d]", field, i), fmt.Sprintf("must be between 1 and %d characters", maxTagLength)})
+		}
+	}
+
+	if len(rec.GetSource()) > maxSourceLength {
+		violations = append(violations, FieldViolation{field + ".source", fmt.Sprintf("must be at most %d characters", maxSourceLength)})
+	}
+
+	return violations
+}
+
+func zoneName(field, zone string) []FieldViolation {
+	// zones are stored as VARCHAR(64)
+	if len(zone) > 64 {
+		return []FieldViolation{{field, "must be at most 64 characters"}}
+	}
+
+	// LoadLocation also accepts "Local", which depends on the server
+	if _, err := time.LoadLocation(zone); err != nil || zone == "Local" {
+		return []FieldViolation{{field, "must be an IANA time zone, e.g. Europe/Lisbon"}}
+	}
+
+	return nil
+}
+
+func toError(violations []FieldViolation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return &Error{Violations: violations}
+}
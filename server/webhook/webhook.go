@@ -0,0 +1,372 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/config"
+	"github.com/0gener/go-weight-tracker/server/store"
+)
+
+// Headers sent with every delivery. The signature is the hex encoded
+// HMAC-SHA256, keyed with the subscription's secret, of the timestamp, a dot
+// and the body, prefixed with "sha256=".
+const (
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+const (
+	pollInterval    = time.Second
+	cleanupInterval = time.Hour
+	claimBatch      = 20
+	initialBackoff  = 30 * time.Second
+	maxBackoff      = time.Hour
+	maxErrorLength  = 1024 // last_error is a VARCHAR(1024)
+)
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	Type       string         `json:"type"`     // record.created, record.updated or record.deleted
+	Sequence   uint64         `json:"sequence"` // id of the change in the audit log, increases with every change
+	OccurredAt time.Time      `json:"occurred_at"`
+	Record     RecordPayload  `json:"record"`
+	Previous   *RecordPayload `json:"previous,omitempty"` // before an update
+}
+
+// RecordPayload is a record as sent in a payload.
+type RecordPayload struct {
+	ID         uint64    `json:"id"`
+	ClientID   string    `json:"client_id,omitempty"`
+	Weight     float32   `json:"weight"`
+	WeightedAt time.Time `json:"weighted_at"`
+	TimeZone   string    `json:"time_zone,omitempty"`
+	UTCOffset  int32     `json:"utc_offset"`
+	LocalDate  string    `json:"local_date"`
+	Tags       []string  `json:"tags,omitempty"`
+	Source     string    `json:"source,omitempty"`
+}
+
+// Dispatcher decides which webhooks to deliver each change to and delivers
+// them in the background, retrying failed attempts with exponential backoff.
+// The queue lives in the store, which queues the deliveries returned by
+// Deliveries along with the change, so they survive restarts and are only
+// sent by one server at a time.
+type Dispatcher struct {
+	mu     sync.RWMutex
+	conf   config.WebhooksConfig
+	store  store.DeliveryStore
+	client *http.Client
+	now    func() time.Time
+	wake   chan struct{}
+}
+
+// New creates a dispatcher for webhooksConfig using st as its queue.
+func New(webhooksConfig config.WebhooksConfig, st store.DeliveryStore, now func() time.Time) *Dispatcher {
+	return &Dispatcher{
+		conf:   webhooksConfig,
+		store:  st,
+		client: &http.Client{},
+		now:    now,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Update replaces the subscriptions and delivery settings. Queued
+// deliveries are sent with the new settings, those for subscriptions that
+// were removed fail.
+func (d *Dispatcher) Update(webhooksConfig config.WebhooksConfig) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.conf = webhooksConfig
+}
+
+func (d *Dispatcher) config() config.WebhooksConfig {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.conf
+}
+
+// Deliveries returns a delivery of the change described by e for every
+// subscription interested in it. It is a store.DeliveryFunc.
+func (d *Dispatcher) Deliveries(e *store.AuditEvent) ([]*store.Delivery, error) {
+	conf := d.config()
+
+	var subs []config.WebhookConfig
+	for _, sub := range conf.Subscriptions {
+		if len(sub.Events) == 0 || slices.Contains(sub.Events, e.Action) {
+			subs = append(subs, sub)
+		}
+	}
+
+	if len(subs) == 0 {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(newPayload(e))
+	if err != nil {
+		return nil, err
+	}
+
+	now := d.now().UTC()
+
+	deliveries := make([]*store.Delivery, 0, len(subs))
+	for _, sub := range subs {
+		deliveries = append(deliveries, &store.Delivery{
+			Webhook:       sub.Name,
+			Event:         eventName(e.Action),
+			RecordID:      e.RecordID,
+			Owner:         e.Owner,
+			Payload:       body,
+			Status:        store.DeliveryPending,
+			NextAttemptAt: now,
+		})
+	}
+
+	return deliveries, nil
+}
+
+// Wake makes Run look for due deliveries right away, e.g. once a change
+// that queued some has been stored.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers queued webhooks and removes old deliveries until ctx is
+// done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var lastCleanup time.Time
+
+	for {
+		d.dispatch(ctx)
+
+		if now := d.now(); now.Sub(lastCleanup) >= cleanupInterval {
+			d.cleanup(ctx, now)
+			lastCleanup = now
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// dispatch sends every due delivery.
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		conf := d.config()
+		now := d.now()
+
+		// a delivery that is still claimed when its attempt should be long
+		// over belonged to a server that stopped, and is tried again
+		deliveries, err := d.store.ClaimDeliveries(ctx, now, now.Add(2*conf.Timeout), claimBatch)
+		if err != nil {
+			slog.Warn("failed to claim webhook deliveries", "error", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.attempt(ctx, conf, delivery)
+			}()
+		}
+		wg.Wait()
+
+		if len(deliveries) < claimBatch {
+			return
+		}
+	}
+}
+
+// attempt sends delivery once and stores the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, conf config.WebhooksConfig, delivery store.Delivery) {
+	idx := slices.IndexFunc(conf.Subscriptions, func(sub config.WebhookConfig) bool { return sub.Name == delivery.Webhook })
+
+	var statusCode int
+	var err error
+	if idx < 0 {
+		err = fmt.Errorf("subscription %q no longer exists", delivery.Webhook)
+		delivery.Attempts = conf.MaxAttempts
+	} else {
+		statusCode, err = d.send(ctx, conf, conf.Subscriptions[idx], delivery)
+		delivery.Attempts++
+	}
+
+	if ctx.Err() != nil {
+		// shutting down, the claim expires and the attempt is made again
+		return
+	}
+
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = ""
+
+	logger := slog.With("webhook", delivery.Webhook, "delivery_id", delivery.ID, "attempt", delivery.Attempts)
+
+	switch {
+	case err == nil:
+		delivery.Status = store.DeliverySucceeded
+		logger.Debug("delivered webhook", "status_code", statusCode)
+	case delivery.Attempts >= conf.MaxAttempts:
+		delivery.Status = store.DeliveryFailed
+		delivery.LastError = truncate(err.Error(), maxErrorLength)
+		logger.Warn("webhook delivery failed, giving up", "error", err)
+	default:
+		delivery.NextAttemptAt = d.now().Add(backoff(delivery.Attempts))
+		delivery.LastError = truncate(err.Error(), maxErrorLength)
+		logger.Info("webhook delivery failed, will retry", "error", err, "next_attempt_at", delivery.NextAttemptAt)
+	}
+
+	if err := d.store.SaveDelivery(context.WithoutCancel(ctx), &delivery); err != nil {
+		logger.Error("failed to save webhook delivery", "error", err)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, conf config.WebhooksConfig, sub config.WebhookConfig, delivery store.Delivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, conf.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(delivery.ID, 10))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(string(sub.Secret), timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// let the connection be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) cleanup(ctx context.Context, now time.Time) {
+	retention := d.config().Retention
+	if retention == 0 {
+		return
+	}
+
+	deleted, err := d.store.DeleteDeliveries(ctx, now.Add(-retention))
+	if err != nil {
+		slog.Warn("failed to delete old webhook deliveries", "error", err)
+		return
+	}
+
+	if deleted > 0 {
+		slog.Info("deleted old webhook deliveries", "count", deleted)
+	}
+}
+
+// Sign returns the signature sent in SignatureHeader for body.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff returns how long to wait after the given number of failed
+// attempts, with jitter so retries to the same endpoint spread out.
+func backoff(attempts int) time.Duration {
+	b := initialBackoff
+	for i := 1; i < attempts && b < maxBackoff; i++ {
+		b *= 2
+	}
+
+	b = min(b, maxBackoff)
+
+	return b/2 + time.Duration(rand.Int64N(int64(b/2)))
+}
+
+func newPayload(e *store.AuditEvent) Payload {
+	p := Payload{
+		Type:       eventName(e.Action),
+		Sequence:   e.ID,
+		OccurredAt: e.CreatedAt,
+	}
+
+	switch {
+	case e.Action == store.AuditDeleted:
+		p.Record = newRecordPayload(*e.Before)
+	case e.Action == store.AuditUpdated:
+		p.Record = newRecordPayload(*e.After)
+
+		prev := newRecordPayload(*e.Before)
+		p.Previous = &prev
+	default:
+		p.Record = newRecordPayload(*e.After)
+	}
+
+	return p
+}
+
+func newRecordPayload(rec store.Record) RecordPayload {
+	return RecordPayload{
+		ID:         rec.ID,
+		ClientID:   rec.ClientID,
+		Weight:     rec.Weight,
+		WeightedAt: rec.WeightedAt.UTC(),
+		TimeZone:   rec.TimeZone,
+		UTCOffset:  rec.UTCOffset,
+		LocalDate:  rec.LocalDate(),
+		Tags:       rec.Tags,
+		Source:     rec.Source,
+	}
+}
+
+func eventName(action string) string {
+	return "record." + action
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[:n]
+}
@@ -0,0 +1,207 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/config"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/0gener/go-weight-tracker/server/store/filestore"
+)
+
+// request is a delivery attempt as received by an endpoint.
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+// endpoint is a webhook endpoint answering every request with status.
+type endpoint struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	requests []request
+}
+
+func newEndpoint(t *testing.T, status int) *endpoint {
+	e := &endpoint{status: status}
+
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		e.requests = append(e.requests, request{header: r.Header.Clone(), body: body})
+		w.WriteHeader(e.status)
+	}))
+	t.Cleanup(e.Close)
+
+	return e
+}
+
+func (e *endpoint) received() []request {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.requests
+}
+
+// setup returns a dispatcher delivering to e as of now, with a record
+// created and a delivery queued for it.
+func setup(t *testing.T, e *endpoint, now *time.Time) (*Dispatcher, store.Store) {
+	t.Helper()
+
+	st := filestore.NewMemory()
+	t.Cleanup(func() { st.Close() })
+
+	d := New(config.WebhooksConfig{
+		Subscriptions: []config.WebhookConfig{{Name: "hook", URL: e.URL, Secret: "s3cret"}},
+		Timeout:       5 * time.Second,
+		MaxAttempts:   3,
+	}, st, func() time.Time { return *now })
+
+	ctx := store.WithDeliveries(context.Background(), d.Deliveries)
+	if err := st.CreateRecord(ctx, &store.Record{Weight: 80, WeightedAt: *now, TimeZone: "UTC"}); err != nil {
+		t.Fatal(err)
+	}
+
+	return d, st
+}
+
+func delivery(t *testing.T, st store.Store) store.Delivery {
+	t.Helper()
+
+	deliveries, err := st.ListDeliveries(context.Background(), store.DeliveryListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+
+	return deliveries[0]
+}
+
+func TestDeliver(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	e := newEndpoint(t, http.StatusNoContent)
+	d, st := setup(t, e, &now)
+
+	d.dispatch(context.Background())
+
+	reqs := e.received()
+	if len(reqs) != 1 {
+		t.Fatalf("endpoint received %d requests, want 1", len(reqs))
+	}
+
+	req := reqs[0]
+
+	timestamp := req.header.Get(TimestampHeader)
+	if timestamp != strconv.FormatInt(now.Unix(), 10) {
+		t.Errorf("got timestamp %q, want %d", timestamp, now.Unix())
+	}
+
+	if got, want := req.header.Get(SignatureHeader), Sign("s3cret", timestamp, req.body); got != want {
+		t.Errorf("got signature %q, want %q", got, want)
+	}
+
+	if got := req.header.Get(EventHeader); got != "record.created" {
+		t.Errorf("got event %q, want record.created", got)
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.Type != "record.created" || payload.Record.Weight != 80 || payload.Record.LocalDate != "2024-06-15" {
+		t.Errorf("got payload %+v", payload)
+	}
+
+	sent := delivery(t, st)
+	if sent.Status != store.DeliverySucceeded || sent.Attempts != 1 || sent.LastStatusCode != http.StatusNoContent {
+		t.Errorf("got delivery %+v, want it succeeded at the first attempt", sent)
+	}
+
+	if got := req.header.Get(DeliveryHeader); got != strconv.FormatUint(sent.ID, 10) {
+		t.Errorf("got delivery id %q, want %d", got, sent.ID)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	e := newEndpoint(t, http.StatusServiceUnavailable)
+	d, st := setup(t, e, &now)
+
+	ctx := context.Background()
+
+	// every failed attempt waits about twice as long as the one before
+	for attempt, wait := range []time.Duration{initialBackoff, 2 * initialBackoff} {
+		d.dispatch(ctx)
+
+		queued := delivery(t, st)
+		if queued.Status != store.DeliveryPending || queued.Attempts != attempt+1 || queued.LastStatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("got delivery %+v after attempt %d, want it pending", queued, attempt+1)
+		}
+
+		if delay := queued.NextAttemptAt.Sub(now); delay < wait/2 || delay >= wait {
+			t.Errorf("attempt %d is retried after %v, want between %v and %v", attempt+1, delay, wait/2, wait)
+		}
+
+		// not due yet
+		now = queued.NextAttemptAt.Add(-time.Second)
+		d.dispatch(ctx)
+
+		if n := len(e.received()); n != attempt+1 {
+			t.Fatalf("endpoint received %d requests before the retry was due, want %d", n, attempt+1)
+		}
+
+		now = queued.NextAttemptAt
+	}
+
+	d.dispatch(ctx)
+
+	failed := delivery(t, st)
+	if failed.Status != store.DeliveryFailed || failed.Attempts != 3 || failed.LastError != "unexpected status 503 Service Unavailable" {
+		t.Errorf("got delivery %+v after the last attempt, want it failed", failed)
+	}
+
+	// failed deliveries are not attempted again
+	now = now.Add(maxBackoff)
+	d.dispatch(ctx)
+
+	if n := len(e.received()); n != 3 {
+		t.Errorf("endpoint received %d requests, want 3", n)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		max      time.Duration
+	}{
+		{1, initialBackoff},
+		{2, 2 * initialBackoff},
+		{3, 4 * initialBackoff},
+		{8, maxBackoff},
+		{20, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		for range 100 {
+			if b := backoff(tt.attempts); b < tt.max/2 || b >= tt.max {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.attempts, b, tt.max/2, tt.max)
+			}
+		}
+	}
+}
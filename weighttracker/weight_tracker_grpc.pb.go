@@ -17,17 +17,71 @@ const _ = grpc.SupportPackageIsVersion7
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type WeightTrackerClient interface {
-	// Creates a weight record. Returns `INVALID_ARGUMENT` if weight is less or equals to 0.
-	// If weight_at is not sent, will use current datetime.
+	// Creates a weight record. Returns `INVALID_ARGUMENT`, with a `google.rpc.BadRequest`
+	// detail listing every invalid field, if weight is out of range or weighted_at is
+	// too far in the past or in the future. If weighted_at is not sent, will use current datetime.
 	CreateRecord(ctx context.Context, in *CreateRecordRequest, opts ...grpc.CallOption) (*CreateRecordResponse, error)
 	// Reads a record using a record_id. Returns `NOT_FOUND` if the record does not exist.
 	ReadRecord(ctx context.Context, in *ReadRecordRequest, opts ...grpc.CallOption) (*ReadRecordResponse, error)
-	// Updates a record. Returns `NOT_FOUND` if the record does not exist.
+	// Updates a record. Only the fields that are set are changed and they are validated
+	// as in CreateRecord. Returns `NOT_FOUND` if the record does not exist.
 	UpdateRecord(ctx context.Context, in *UpdateRecordRequest, opts ...grpc.CallOption) (*UpdateRecordResponse, error)
 	// Deletes a record using a record_id. Returns `NOT_FOUND` if the record does not exist.
 	DeleteRecord(ctx context.Context, in *DeleteRecordRequest, opts ...grpc.CallOption) (*DeleteRecordResponse, error)
-	// Lists all records.
+	// Lists the records matching the request, in batches of batch_size. Stops as
+	// soon as the client cancels or goes away.
 	ListRecords(ctx context.Context, in *ListRecordsRequest, opts ...grpc.CallOption) (WeightTracker_ListRecordsClient, error)
+	// Lists the revisions of a record, newest first. A revision is kept every time
+	// the weight, weighted_at or time zone of a record change, the current values
+	// included.
+	ListRecordRevisions(ctx context.Context, in *ListRecordRevisionsRequest, opts ...grpc.CallOption) (*ListRecordRevisionsResponse, error)
+	// Restores the values a record had at an earlier revision, or at a point in
+	// time, as a new revision. Deleted records cannot be reverted.
+	RevertRecord(ctx context.Context, in *RevertRecordRequest, opts ...grpc.CallOption) (*RevertRecordResponse, error)
+	// Summarizes records by the local date they were taken on, oldest first. Days
+	// without records are omitted.
+	ListDailySummaries(ctx context.Context, in *ListDailySummariesRequest, opts ...grpc.CallOption) (*ListDailySummariesResponse, error)
+	// Returns the settings of the caller.
+	GetSettings(ctx context.Context, in *GetSettingsRequest, opts ...grpc.CallOption) (*GetSettingsResponse, error)
+	// Replaces the settings of the caller.
+	UpdateSettings(ctx context.Context, in *UpdateSettingsRequest, opts ...grpc.CallOption) (*UpdateSettingsResponse, error)
+	// Streams record changes as they happen, filtered like ListRecords. An update
+	// matches if the record matched either before or after it. To resume after a
+	// reconnect, send the sequence of the last event received. Returns `OUT_OF_RANGE`
+	// if the events since then are no longer available, in which case records should
+	// be listed again. Returns `ABORTED` if the client falls too far behind.
+	WatchRecords(ctx context.Context, in *WatchRecordsRequest, opts ...grpc.CallOption) (WeightTracker_WatchRecordsClient, error)
+	// Reconciles an offline client. Pushed changes are applied first, in order, with
+	// last-writer-wins conflict resolution: an update or delete is a conflict if the
+	// record changed on the server after the change's modified_at, and updates to a
+	// deleted record are conflicts too. Then every record created, updated or deleted
+	// since sync_token is returned, with the token for the next sync. A change may be
+	// returned more than once, so applying them must be idempotent.
+	SyncRecords(ctx context.Context, in *SyncRecordsRequest, opts ...grpc.CallOption) (*SyncRecordsResponse, error)
+	// Lists webhook deliveries, newest first. Deliveries are queued when records are
+	// created, updated or deleted and kept for the configured retention once they
+	// succeed or fail.
+	ListWebhookDeliveries(ctx context.Context, in *ListWebhookDeliveriesRequest, opts ...grpc.CallOption) (*ListWebhookDeliveriesResponse, error)
+	// Lists audit events, newest first. An event is recorded for every record
+	// created, updated or deleted, including changes pushed by SyncRecords, and is
+	// never changed or removed.
+	ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error)
+	// Creates an account. Returns `ALREADY_EXISTS` if the username is taken and
+	// `PERMISSION_DENIED` if registration is disabled. Like Login, RefreshToken and
+	// Logout, it does not need an access token and returns `FAILED_PRECONDITION`
+	// unless the server authenticates callers with accounts.
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	// Exchanges a username and password for an access token, sent as a bearer token
+	// with every other call, and a refresh token. Returns `UNAUTHENTICATED` if they
+	// do not match an account.
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+	// Exchanges a refresh token for new tokens. The refresh token is revoked, and
+	// using it again revokes every refresh token of the account. Returns
+	// `UNAUTHENTICATED` if it is unknown, expired or revoked.
+	RefreshToken(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*RefreshTokenResponse, error)
+	// Revokes a refresh token, or every refresh token of its account. Access tokens
+	// already issued stay valid until they expire.
+	Logout(ctx context.Context, in *LogoutRequest, opts ...grpc.CallOption) (*LogoutResponse, error)
 }
 
 type weightTrackerClient struct {
@@ -106,21 +160,215 @@ func (x *weightTrackerListRecordsClient) Recv() (*ListRecordsResponse, error) {
 	return m, nil
 }
 
+func (c *weightTrackerClient) ListRecordRevisions(ctx context.Context, in *ListRecordRevisionsRequest, opts ...grpc.CallOption) (*ListRecordRevisionsResponse, error) {
+	out := new(ListRecordRevisionsResponse)
+	err := c.cc.Invoke(ctx, "/WeightTracker/ListRecordRevisions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weightTrackerClient) RevertRecord(ctx context.Context, in *RevertRecordRequest, opts ...grpc.CallOption) (*RevertRecordResponse, error) {
+	out := new(RevertRecordResponse)
+	err := c.cc.Invoke(ctx, "/WeightTracker/RevertRecord", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weightTrackerClient) ListDailySummaries(ctx context.Context, in *ListDailySummariesRequest, opts ...grpc.CallOption) (*ListDailySummariesResponse, error) {
+	out := new(ListDailySummariesResponse)
+	err := c.cc.Invoke(ctx, "/WeightTracker/ListDailySummaries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weightTrackerClient) GetSettings(ctx context.Context, in *GetSettingsRequest, opts ...grpc.CallOption) (*GetSettingsResponse, error) {
+	out := new(GetSettingsResponse)
+	err := c.cc.Invoke(ctx, "/WeightTracker/GetSettings", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weightTrackerClient) UpdateSettings(ctx context.Context, in *UpdateSettingsRequest, opts ...grpc.CallOption) (*UpdateSettingsResponse, error) {
+	out := new(UpdateSettingsResponse)
+	err := c.cc.Invoke(ctx, "/WeightTracker/UpdateSettings", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weightTrackerClient) WatchRecords(ctx context.Context, in *WatchRecordsRequest, opts ...grpc.CallOption) (WeightTracker_WatchRecordsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_WeightTracker_serviceDesc.Streams[1], "/WeightTracker/WatchRecords", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &weightTrackerWatchRecordsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WeightTracker_WatchRecordsClient interface {
+	Recv() (*WatchRecordsResponse, error)
+	grpc.ClientStream
+}
+
+type weightTrackerWatchRecordsClient struct {
+	grpc.ClientStream
+}
+
+func (x *weightTrackerWatchRecordsClient) Recv() (*WatchRecordsResponse, error) {
+	m := new(WatchRecordsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *weightTrackerClient) SyncRecords(ctx context.Context, in *SyncRecordsRequest, opts ...grpc.CallOption) (*SyncRecordsResponse, error) {
+	out := new(SyncRecordsResponse)
+	err := c.cc.Invoke(ctx, "/WeightTracker/SyncRecords", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weightTrackerClient) ListWebhookDeliveries(ctx context.Context, in *ListWebhookDeliveriesRequest, opts ...grpc.CallOption) (*ListWebhookDeliveriesResponse, error) {
+	out := new(ListWebhookDeliveriesResponse)
+	err := c.cc.Invoke(ctx, "/WeightTracker/ListWebhookDeliveries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weightTrackerClient) ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error) {
+	out := new(ListAuditEventsResponse)
+	err := c.cc.Invoke(ctx, "/WeightTracker/ListAuditEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weightTrackerClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, "/WeightTracker/Register", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weightTrackerClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error) {
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, "/WeightTracker/Login", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weightTrackerClient) RefreshToken(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*RefreshTokenResponse, error) {
+	out := new(RefreshTokenResponse)
+	err := c.cc.Invoke(ctx, "/WeightTracker/RefreshToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weightTrackerClient) Logout(ctx context.Context, in *LogoutRequest, opts ...grpc.CallOption) (*LogoutResponse, error) {
+	out := new(LogoutResponse)
+	err := c.cc.Invoke(ctx, "/WeightTracker/Logout", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // WeightTrackerServer is the server API for WeightTracker service.
 // All implementations must embed UnimplementedWeightTrackerServer
 // for forward compatibility
 type WeightTrackerServer interface {
-	// Creates a weight record. Returns `INVALID_ARGUMENT` if weight is less or equals to 0.
-	// If weight_at is not sent, will use current datetime.
+	// Creates a weight record. Returns `INVALID_ARGUMENT`, with a `google.rpc.BadRequest`
+	// detail listing every invalid field, if weight is out of range or weighted_at is
+	// too far in the past or in the future. If weighted_at is not sent, will use current datetime.
 	CreateRecord(context.Context, *CreateRecordRequest) (*CreateRecordResponse, error)
 	// Reads a record using a record_id. Returns `NOT_FOUND` if the record does not exist.
 	ReadRecord(context.Context, *ReadRecordRequest) (*ReadRecordResponse, error)
-	// Updates a record. Returns `NOT_FOUND` if the record does not exist.
+	// Updates a record. Only the fields that are set are changed and they are validated
+	// as in CreateRecord. Returns `NOT_FOUND` if the record does not exist.
 	UpdateRecord(context.Context, *UpdateRecordRequest) (*UpdateRecordResponse, error)
 	// Deletes a record using a record_id. Returns `NOT_FOUND` if the record does not exist.
 	DeleteRecord(context.Context, *DeleteRecordRequest) (*DeleteRecordResponse, error)
-	// Lists all records.
+	// Lists the records matching the request, in batches of batch_size. Stops as
+	// soon as the client cancels or goes away.
 	ListRecords(*ListRecordsRequest, WeightTracker_ListRecordsServer) error
+	// Lists the revisions of a record, newest first. A revision is kept every time
+	// the weight, weighted_at or time zone of a record change, the current values
+	// included.
+	ListRecordRevisions(context.Context, *ListRecordRevisionsRequest) (*ListRecordRevisionsResponse, error)
+	// Restores the values a record had at an earlier revision, or at a point in
+	// time, as a new revision. Deleted records cannot be reverted.
+	RevertRecord(context.Context, *RevertRecordRequest) (*RevertRecordResponse, error)
+	// Summarizes records by the local date they were taken on, oldest first. Days
+	// without records are omitted.
+	ListDailySummaries(context.Context, *ListDailySummariesRequest) (*ListDailySummariesResponse, error)
+	// Returns the settings of the caller.
+	GetSettings(context.Context, *GetSettingsRequest) (*GetSettingsResponse, error)
+	// Replaces the settings of the caller.
+	UpdateSettings(context.Context, *UpdateSettingsRequest) (*UpdateSettingsResponse, error)
+	// Streams record changes as they happen, filtered like ListRecords. An update
+	// matches if the record matched either before or after it. To resume after a
+	// reconnect, send the sequence of the last event received. Returns `OUT_OF_RANGE`
+	// if the events since then are no longer available, in which case records should
+	// be listed again. Returns `ABORTED` if the client falls too far behind.
+	WatchRecords(*WatchRecordsRequest, WeightTracker_WatchRecordsServer) error
+	// Reconciles an offline client. Pushed changes are applied first, in order, with
+	// last-writer-wins conflict resolution: an update or delete is a conflict if the
+	// record changed on the server after the change's modified_at, and updates to a
+	// deleted record are conflicts too. Then every record created, updated or deleted
+	// since sync_token is returned, with the token for the next sync. A change may be
+	// returned more than once, so applying them must be idempotent.
+	SyncRecords(context.Context, *SyncRecordsRequest) (*SyncRecordsResponse, error)
+	// Lists webhook deliveries, newest first. Deliveries are queued when records are
+	// created, updated or deleted and kept for the configured retention once they
+	// succeed or fail.
+	ListWebhookDeliveries(context.Context, *ListWebhookDeliveriesRequest) (*ListWebhookDeliveriesResponse, error)
+	// Lists audit events, newest first. An event is recorded for every record
+	// created, updated or deleted, including changes pushed by SyncRecords, and is
+	// never changed or removed.
+	ListAuditEvents(context.Context, *ListAuditEventsRequest) (*ListAuditEventsResponse, error)
+	// Creates an account. Returns `ALREADY_EXISTS` if the username is taken and
+	// `PERMISSION_DENIED` if registration is disabled. Like Login, RefreshToken and
+	// Logout, it does not need an access token and returns `FAILED_PRECONDITION`
+	// unless the server authenticates callers with accounts.
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	// Exchanges a username and password for an access token, sent as a bearer token
+	// with every other call, and a refresh token. Returns `UNAUTHENTICATED` if they
+	// do not match an account.
+	Login(context.Context, *LoginRequest) (*LoginResponse, error)
+	// Exchanges a refresh token for new tokens. The refresh token is revoked, and
+	// using it again revokes every refresh token of the account. Returns
+	// `UNAUTHENTICATED` if it is unknown, expired or revoked.
+	RefreshToken(context.Context, *RefreshTokenRequest) (*RefreshTokenResponse, error)
+	// Revokes a refresh token, or every refresh token of its account. Access tokens
+	// already issued stay valid until they expire.
+	Logout(context.Context, *LogoutRequest) (*LogoutResponse, error)
 	mustEmbedUnimplementedWeightTrackerServer()
 }
 
@@ -143,6 +391,45 @@ func (UnimplementedWeightTrackerServer) DeleteRecord(context.Context, *DeleteRec
 func (UnimplementedWeightTrackerServer) ListRecords(*ListRecordsRequest, WeightTracker_ListRecordsServer) error {
 	return status.Errorf(codes.Unimplemented, "method ListRecords not implemented")
 }
+func (UnimplementedWeightTrackerServer) ListRecordRevisions(context.Context, *ListRecordRevisionsRequest) (*ListRecordRevisionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRecordRevisions not implemented")
+}
+func (UnimplementedWeightTrackerServer) RevertRecord(context.Context, *RevertRecordRequest) (*RevertRecordResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevertRecord not implemented")
+}
+func (UnimplementedWeightTrackerServer) ListDailySummaries(context.Context, *ListDailySummariesRequest) (*ListDailySummariesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDailySummaries not implemented")
+}
+func (UnimplementedWeightTrackerServer) GetSettings(context.Context, *GetSettingsRequest) (*GetSettingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSettings not implemented")
+}
+func (UnimplementedWeightTrackerServer) UpdateSettings(context.Context, *UpdateSettingsRequest) (*UpdateSettingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateSettings not implemented")
+}
+func (UnimplementedWeightTrackerServer) WatchRecords(*WatchRecordsRequest, WeightTracker_WatchRecordsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchRecords not implemented")
+}
+func (UnimplementedWeightTrackerServer) SyncRecords(context.Context, *SyncRecordsRequest) (*SyncRecordsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SyncRecords not implemented")
+}
+func (UnimplementedWeightTrackerServer) ListWebhookDeliveries(context.Context, *ListWebhookDeliveriesRequest) (*ListWebhookDeliveriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWebhookDeliveries not implemented")
+}
+func (UnimplementedWeightTrackerServer) ListAuditEvents(context.Context, *ListAuditEventsRequest) (*ListAuditEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAuditEvents not implemented")
+}
+func (UnimplementedWeightTrackerServer) Register(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedWeightTrackerServer) Login(context.Context, *LoginRequest) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedWeightTrackerServer) RefreshToken(context.Context, *RefreshTokenRequest) (*RefreshTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RefreshToken not implemented")
+}
+func (UnimplementedWeightTrackerServer) Logout(context.Context, *LogoutRequest) (*LogoutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Logout not implemented")
+}
 func (UnimplementedWeightTrackerServer) mustEmbedUnimplementedWeightTrackerServer() {}
 
 // UnsafeWeightTrackerServer may be embedded to opt out of forward compatibility for this service.
@@ -249,6 +536,243 @@ func (x *weightTrackerListRecordsServer) Send(m *ListRecordsResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _WeightTracker_ListRecordRevisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRecordRevisionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeightTrackerServer).ListRecordRevisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/WeightTracker/ListRecordRevisions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeightTrackerServer).ListRecordRevisions(ctx, req.(*ListRecordRevisionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeightTracker_RevertRecord_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevertRecordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeightTrackerServer).RevertRecord(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/WeightTracker/RevertRecord",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeightTrackerServer).RevertRecord(ctx, req.(*RevertRecordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeightTracker_ListDailySummaries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDailySummariesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeightTrackerServer).ListDailySummaries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/WeightTracker/ListDailySummaries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeightTrackerServer).ListDailySummaries(ctx, req.(*ListDailySummariesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeightTracker_GetSettings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSettingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeightTrackerServer).GetSettings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/WeightTracker/GetSettings",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeightTrackerServer).GetSettings(ctx, req.(*GetSettingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeightTracker_UpdateSettings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateSettingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeightTrackerServer).UpdateSettings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/WeightTracker/UpdateSettings",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeightTrackerServer).UpdateSettings(ctx, req.(*UpdateSettingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeightTracker_WatchRecords_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRecordsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WeightTrackerServer).WatchRecords(m, &weightTrackerWatchRecordsServer{stream})
+}
+
+type WeightTracker_WatchRecordsServer interface {
+	Send(*WatchRecordsResponse) error
+	grpc.ServerStream
+}
+
+type weightTrackerWatchRecordsServer struct {
+	grpc.ServerStream
+}
+
+func (x *weightTrackerWatchRecordsServer) Send(m *WatchRecordsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _WeightTracker_SyncRecords_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncRecordsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeightTrackerServer).SyncRecords(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/WeightTracker/SyncRecords",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeightTrackerServer).SyncRecords(ctx, req.(*SyncRecordsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeightTracker_ListWebhookDeliveries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWebhookDeliveriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeightTrackerServer).ListWebhookDeliveries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/WeightTracker/ListWebhookDeliveries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeightTrackerServer).ListWebhookDeliveries(ctx, req.(*ListWebhookDeliveriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeightTracker_ListAuditEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAuditEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeightTrackerServer).ListAuditEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/WeightTracker/ListAuditEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeightTrackerServer).ListAuditEvents(ctx, req.(*ListAuditEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeightTracker_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeightTrackerServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/WeightTracker/Register",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeightTrackerServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeightTracker_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeightTrackerServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/WeightTracker/Login",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeightTrackerServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeightTracker_RefreshToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefreshTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeightTrackerServer).RefreshToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/WeightTracker/RefreshToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeightTrackerServer).RefreshToken(ctx, req.(*RefreshTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeightTracker_Logout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogoutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeightTrackerServer).Logout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/WeightTracker/Logout",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeightTrackerServer).Logout(ctx, req.(*LogoutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _WeightTracker_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "WeightTracker",
 	HandlerType: (*WeightTrackerServer)(nil),
@@ -269,6 +793,54 @@ var _WeightTracker_serviceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteRecord",
 			Handler:    _WeightTracker_DeleteRecord_Handler,
 		},
+		{
+			MethodName: "ListRecordRevisions",
+			Handler:    _WeightTracker_ListRecordRevisions_Handler,
+		},
+		{
+			MethodName: "RevertRecord",
+			Handler:    _WeightTracker_RevertRecord_Handler,
+		},
+		{
+			MethodName: "ListDailySummaries",
+			Handler:    _WeightTracker_ListDailySummaries_Handler,
+		},
+		{
+			MethodName: "GetSettings",
+			Handler:    _WeightTracker_GetSettings_Handler,
+		},
+		{
+			MethodName: "UpdateSettings",
+			Handler:    _WeightTracker_UpdateSettings_Handler,
+		},
+		{
+			MethodName: "SyncRecords",
+			Handler:    _WeightTracker_SyncRecords_Handler,
+		},
+		{
+			MethodName: "ListWebhookDeliveries",
+			Handler:    _WeightTracker_ListWebhookDeliveries_Handler,
+		},
+		{
+			MethodName: "ListAuditEvents",
+			Handler:    _WeightTracker_ListAuditEvents_Handler,
+		},
+		{
+			MethodName: "Register",
+			Handler:    _WeightTracker_Register_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _WeightTracker_Login_Handler,
+		},
+		{
+			MethodName: "RefreshToken",
+			Handler:    _WeightTracker_RefreshToken_Handler,
+		},
+		{
+			MethodName: "Logout",
+			Handler:    _WeightTracker_Logout_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -276,6 +848,11 @@ var _WeightTracker_serviceDesc = grpc.ServiceDesc{
 			Handler:       _WeightTracker_ListRecords_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "WatchRecords",
+			Handler:       _WeightTracker_WatchRecords_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "weighttracker/weight_tracker.proto",
 }
@@ -0,0 +1,215 @@
+// Package wtclient is a Go client for the WeightTracker service.
+//
+// It dials the server, attaches credentials to every call, retries
+// idempotent calls that fail with a transient error, iterates over listings
+// without dealing with streams or page tokens and converts failures to
+// *Error, which can be tested with errors.Is against ErrNotFound and the
+// other sentinel errors:
+//
+//	c, err := wtclient.New(wtclient.WithAddress("localhost:50051"), wtclient.WithInsecure())
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//
+//	for rec, err := range c.Records(ctx, &weighttracker.ListRecordsRequest{Latest: true, Limit: 7}) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(rec.GetWeight())
+//	}
+package wtclient
+
+import (
+	"context"
+
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"google.golang.org/grpc"
+)
+
+// Client calls a WeightTracker server. It is safe for concurrent use.
+type Client struct {
+	conn  *grpc.ClientConn // nil if the connection is owned by the caller
+	api   weighttracker.WeightTrackerClient
+	retry RetryPolicy
+}
+
+// New dials the server configured by opts. One of WithTLS, WithTLSFromFile
+// or WithInsecure is required. The connection is established lazily, so
+// New does not fail if the server is down.
+func New(opts ...Option) (*Client, error) {
+	o := newOptions(opts)
+
+	dialOpts, err := o.grpcDialOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := grpc.Dial(o.address, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		conn:  conn,
+		api:   weighttracker.NewWeightTrackerClient(o.wrap(conn)),
+		retry: o.retry,
+	}, nil
+}
+
+// NewFromConn returns a client using conn, e.g. an in-process connection in
+// tests. Transport options are ignored, except that WithInsecure allows
+// WithToken without TLS, and closing the client does not close conn.
+func NewFromConn(conn grpc.ClientConnInterface, opts ...Option) *Client {
+	o := newOptions(opts)
+
+	return &Client{
+		api:   weighttracker.NewWeightTrackerClient(o.wrap(conn)),
+		retry: o.retry,
+	}
+}
+
+// Close closes the connection dialed by New.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+
+	return c.conn.Close()
+}
+
+// API returns the generated client, for calls not wrapped by Client. Its
+// calls are retried and return *Error like the ones made by Client.
+func (c *Client) API() weighttracker.WeightTrackerClient {
+	return c.api
+}
+
+// CreateRecord creates rec and returns it as stored. It is not retried,
+// since a retry could create the record twice.
+func (c *Client) CreateRecord(ctx context.Context, rec *weighttracker.Record) (*weighttracker.Record, error) {
+	resp, err := c.api.CreateRecord(ctx, &weighttracker.CreateRecordRequest{Record: rec})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetRecord(), nil
+}
+
+// ReadRecord returns the record with the given id.
+func (c *Client) ReadRecord(ctx context.Context, id uint64) (*weighttracker.Record, error) {
+	resp, err := c.api.ReadRecord(ctx, &weighttracker.ReadRecordRequest{RecordId: id})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetRecord(), nil
+}
+
+// UpdateRecord changes the fields set in rec of the record with its id and
+// returns the result.
+func (c *Client) UpdateRecord(ctx context.Context, rec *weighttracker.Record) (*weighttracker.Record, error) {
+	resp, err := c.api.UpdateRecord(ctx, &weighttracker.UpdateRecordRequest{Record: rec})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetRecord(), nil
+}
+
+// DeleteRecord deletes the record with the given id.
+func (c *Client) DeleteRecord(ctx context.Context, id uint64) error {
+	_, err := c.api.DeleteRecord(ctx, &weighttracker.DeleteRecordRequest{RecordId: id})
+
+	return err
+}
+
+// RevertRecord restores an earlier revision of a record and returns the
+// result.
+func (c *Client) RevertRecord(ctx context.Context, req *weighttracker.RevertRecordRequest) (*weighttracker.Record, error) {
+	resp, err := c.api.RevertRecord(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetRecord(), nil
+}
+
+// DailySummaries summarizes the records taken between two local dates,
+// formatted as YYYY-MM-DD, both inclusive.
+func (c *Client) DailySummaries(ctx context.Context, from, to string) ([]*weighttracker.DailySummary, error) {
+	resp, err := c.api.ListDailySummaries(ctx, &weighttracker.ListDailySummariesRequest{LocalDateFrom: from, LocalDateTo: to})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetDays(), nil
+}
+
+// Settings returns the settings of the caller.
+func (c *Client) Settings(ctx context.Context) (*weighttracker.Settings, error) {
+	resp, err := c.api.GetSettings(ctx, &weighttracker.GetSettingsRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetSettings(), nil
+}
+
+// UpdateSettings replaces the settings of the caller and returns them.
+func (c *Client) UpdateSettings(ctx context.Context, settings *weighttracker.Settings) (*weighttracker.Settings, error) {
+	resp, err := c.api.UpdateSettings(ctx, &weighttracker.UpdateSettingsRequest{Settings: settings})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetSettings(), nil
+}
+
+// SyncRecords pushes local changes and pulls the changes since the sync
+// token of req.
+func (c *Client) SyncRecords(ctx context.Context, req *weighttracker.SyncRecordsRequest) (*weighttracker.SyncRecordsResponse, error) {
+	return c.api.SyncRecords(ctx, req)
+}
+
+// Register creates an account on a server that authenticates callers with
+// accounts.
+func (c *Client) Register(ctx context.Context, username, password string) (*weighttracker.Account, error) {
+	resp, err := c.api.Register(ctx, &weighttracker.RegisterRequest{Username: username, Password: password})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetAccount(), nil
+}
+
+// Login returns tokens for the account with the given username and
+// password. The access token is then passed to WithToken, or sent by
+// WithPerRPCCredentials.
+func (c *Client) Login(ctx context.Context, username, password string) (*weighttracker.Tokens, error) {
+	resp, err := c.api.Login(ctx, &weighttracker.LoginRequest{Username: username, Password: password})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetTokens(), nil
+}
+
+// RefreshToken exchanges a refresh token for new tokens. It is not retried,
+// since the server revokes every session of the account when a refresh
+// token is used twice.
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*weighttracker.Tokens, error) {
+	resp, err := c.api.RefreshToken(ctx, &weighttracker.RefreshTokenRequest{RefreshToken: refreshToken})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetTokens(), nil
+}
+
+// Logout revokes a refresh token, or every refresh token of its account if
+// allSessions is set.
+func (c *Client) Logout(ctx context.Context, refreshToken string, allSessions bool) error {
+	_, err := c.api.Logout(ctx, &weighttracker.LogoutRequest{RefreshToken: refreshToken, AllSessions: allSessions})
+
+	return err
+}
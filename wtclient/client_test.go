@@ -0,0 +1,408 @@
+package wtclient_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"github.com/0gener/go-weight-tracker/wtclient"
+	"github.com/0gener/go-weight-tracker/wttest"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// fastRetries retries like wtclient.DefaultRetryPolicy without waiting.
+var fastRetries = wtclient.RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: time.Microsecond,
+	MaxBackoff:     time.Microsecond,
+	Codes:          wtclient.DefaultRetryPolicy.Codes,
+}
+
+func seed(t *testing.T, srv *wttest.Server, weights ...float32) []*weighttracker.Record {
+	t.Helper()
+
+	var recs []*weighttracker.Record
+	for _, w := range weights {
+		recs = append(recs, &weighttracker.Record{Weight: w})
+	}
+
+	seeded, err := srv.Seed(recs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return seeded
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     codes.Code
+		times    int
+		requests int
+		want     error
+	}{
+		{"unavailable", codes.Unavailable, 2, 3, nil},
+		{"rate limited", codes.ResourceExhausted, 3, 4, nil},
+		{"out of attempts", codes.Unavailable, 0, 4, wtclient.ErrUnavailable},
+		{"not retryable", codes.Internal, 1, 1, wtclient.ErrInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := wttest.NewServer(t)
+			recs := seed(t, srv, 80)
+			srv.Inject("ReadRecord", wttest.Fault{Err: status.Error(tt.code, "failed"), Times: tt.times})
+
+			c := srv.Client(wtclient.WithRetryPolicy(fastRetries))
+
+			rec, err := c.ReadRecord(context.Background(), recs[0].GetId())
+			if !errors.Is(err, tt.want) || (err != nil) != (tt.want != nil) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+
+			if tt.want == nil && rec.GetId() != recs[0].GetId() {
+				t.Errorf("read record %v, want %v", rec, recs[0])
+			}
+
+			if n := len(srv.Requests("ReadRecord")); n != tt.requests {
+				t.Errorf("got %d requests, want %d", n, tt.requests)
+			}
+		})
+	}
+}
+
+func retryAfter(t *testing.T, code codes.Code, delay time.Duration) error {
+	t.Helper()
+
+	st, err := status.New(code, "slow down").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return st.Err()
+}
+
+func TestRetryDelay(t *testing.T) {
+	const delay = 100 * time.Millisecond
+
+	srv := wttest.NewServer(t)
+	c := srv.Client(wtclient.WithRetryPolicy(fastRetries))
+
+	srv.Inject("GetSettings", wttest.Fault{Err: retryAfter(t, codes.ResourceExhausted, delay), Times: 1})
+
+	started := time.Now()
+	if _, err := c.Settings(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(started); elapsed < delay {
+		t.Errorf("retried after %v, want at least %v", elapsed, delay)
+	}
+
+	if n := len(srv.Requests("GetSettings")); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+
+	// a deadline that would pass while waiting gives up right away
+	srv.ResetRequests()
+	srv.Inject("GetSettings", wttest.Fault{Err: retryAfter(t, codes.ResourceExhausted, time.Hour), Times: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := c.Settings(ctx)
+
+	var e *wtclient.Error
+	if !errors.As(err, &e) || e.RetryDelay != time.Hour || !errors.Is(err, wtclient.ErrRateLimited) {
+		t.Fatalf("got error %v, want a rate limit asking to wait an hour", err)
+	}
+
+	if n := len(srv.Requests("GetSettings")); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
+
+func TestNoRetryForNonIdempotentCalls(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		method string
+		call   func(c *wtclient.Client, rec *weighttracker.Record) error
+	}{
+		{"CreateRecord", func(c *wtclient.Client, rec *weighttracker.Record) error {
+			_, err := c.CreateRecord(ctx, &weighttracker.Record{Weight: 81})
+			return err
+		}},
+		{"UpdateRecord", func(c *wtclient.Client, rec *weighttracker.Record) error {
+			_, err := c.UpdateRecord(ctx, &weighttracker.Record{Id: rec.GetId(), Weight: 81})
+			return err
+		}},
+		{"DeleteRecord", func(c *wtclient.Client, rec *weighttracker.Record) error {
+			return c.DeleteRecord(ctx, rec.GetId())
+		}},
+		{"SyncRecords", func(c *wtclient.Client, rec *weighttracker.Record) error {
+			_, err := c.SyncRecords(ctx, &weighttracker.SyncRecordsRequest{})
+			return err
+		}},
+		{"RefreshToken", func(c *wtclient.Client, rec *weighttracker.Record) error {
+			_, err := c.RefreshToken(ctx, "token")
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			srv := wttest.NewServer(t)
+			recs := seed(t, srv, 80)
+			srv.Inject(tt.method, wttest.Fault{Err: status.Error(codes.Unavailable, "down"), Times: 1})
+
+			c := srv.Client(wtclient.WithRetryPolicy(fastRetries))
+
+			if err := tt.call(c, recs[0]); !errors.Is(err, wtclient.ErrUnavailable) {
+				t.Fatalf("got error %v, want ErrUnavailable", err)
+			}
+
+			if n := len(srv.Requests(tt.method)); n != 1 {
+				t.Errorf("got %d requests, want 1", n)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want error
+	}{
+		{codes.InvalidArgument, wtclient.ErrInvalidArgument},
+		{codes.NotFound, wtclient.ErrNotFound},
+		{codes.AlreadyExists, wtclient.ErrAlreadyExists},
+		{codes.FailedPrecondition, wtclient.ErrFailedPrecondition},
+		{codes.Aborted, wtclient.ErrAborted},
+		{codes.OutOfRange, wtclient.ErrOutOfRange},
+		{codes.ResourceExhausted, wtclient.ErrRateLimited},
+		{codes.Unauthenticated, wtclient.ErrUnauthenticated},
+		{codes.PermissionDenied, wtclient.ErrPermissionDenied},
+		{codes.Unavailable, wtclient.ErrUnavailable},
+		{codes.Internal, wtclient.ErrInternal},
+		{codes.Canceled, context.Canceled},
+		{codes.DeadlineExceeded, context.DeadlineExceeded},
+	}
+
+	srv := wttest.NewServer(t)
+	c := srv.Client()
+
+	for _, tt := range tests {
+		srv.Inject("GetSettings", wttest.Fault{Err: status.Error(tt.code, "failed"), Times: 1})
+
+		_, err := c.Settings(context.Background())
+
+		var e *wtclient.Error
+		if !errors.As(err, &e) || e.Code != tt.code || e.Message != "failed" {
+			t.Errorf("%v: got error %v, want an *Error with the code and message", tt.code, err)
+			continue
+		}
+
+		if status.Code(err) != tt.code {
+			t.Errorf("%v: got status %v from the error", tt.code, status.Code(err))
+		}
+
+		for _, target := range tests {
+			if is := errors.Is(err, target.want); is != (target.want == tt.want) {
+				t.Errorf("%v: errors.Is(err, %v) = %v", tt.code, target.want, is)
+			}
+		}
+	}
+}
+
+func TestErrorsFromHandlers(t *testing.T) {
+	ctx := context.Background()
+
+	srv := wttest.NewServer(t)
+	c := srv.Client()
+
+	_, err := c.ReadRecord(ctx, 42)
+	if !errors.Is(err, wtclient.ErrNotFound) {
+		t.Errorf("got error %v reading a missing record, want ErrNotFound", err)
+	}
+
+	_, err = c.CreateRecord(ctx, &weighttracker.Record{Weight: 0, TimeZone: "Nowhere"})
+	if !errors.Is(err, wtclient.ErrInvalidArgument) {
+		t.Fatalf("got error %v creating an invalid record, want ErrInvalidArgument", err)
+	}
+
+	var e *wtclient.Error
+	errors.As(err, &e)
+
+	var fields []string
+	for _, v := range e.Violations {
+		if v.Description == "" {
+			t.Errorf("violation of %q has no description", v.Field)
+		}
+
+		fields = append(fields, v.Field)
+	}
+
+	if want := []string{"record.weight", "record.time_zone"}; !slices.Equal(fields, want) {
+		t.Errorf("got violations of %v, want %v", fields, want)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if _, err = c.ReadRecord(canceled, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v with a canceled context, want context.Canceled", err)
+	}
+}
+
+func weightsOf(t *testing.T, c *wtclient.Client, req *weighttracker.ListRecordsRequest, stopAfter int) []float32 {
+	t.Helper()
+
+	var weights []float32
+	for rec, err := range c.Records(context.Background(), req) {
+		if err != nil {
+			t.Fatalf("got error %v after %v", err, weights)
+		}
+
+		weights = append(weights, rec.GetWeight())
+		if len(weights) == stopAfter {
+			break
+		}
+	}
+
+	return weights
+}
+
+func TestRecords(t *testing.T) {
+	srv := wttest.NewServer(t)
+	seed(t, srv, 80, 81, 82, 83, 84)
+
+	c := srv.Client(wtclient.WithRetryPolicy(fastRetries))
+
+	// every batch is read and the end of the stream is not an error
+	if got, want := weightsOf(t, c, &weighttracker.ListRecordsRequest{BatchSize: 2}, 0), []float32{80, 81, 82, 83, 84}; !slices.Equal(got, want) {
+		t.Errorf("got weights %v, want %v", got, want)
+	}
+
+	if got, want := weightsOf(t, c, nil, 0), []float32{80, 81, 82, 83, 84}; !slices.Equal(got, want) {
+		t.Errorf("got weights %v without a request, want %v", got, want)
+	}
+
+	// breaking out of the loop stops in the middle of a batch
+	if got, want := weightsOf(t, c, &weighttracker.ListRecordsRequest{BatchSize: 2}, 3), []float32{80, 81, 82}; !slices.Equal(got, want) {
+		t.Errorf("got weights %v stopping after 3, want %v", got, want)
+	}
+
+	// opening the stream is retried
+	srv.ResetRequests()
+	srv.Inject("ListRecords", wttest.Fault{Err: status.Error(codes.Unavailable, "down"), Times: 2})
+
+	if got, want := weightsOf(t, c, &weighttracker.ListRecordsRequest{Latest: true, Limit: 2}, 0), []float32{84, 83}; !slices.Equal(got, want) {
+		t.Errorf("got weights %v after retrying, want %v", got, want)
+	}
+
+	if n := len(srv.Requests("ListRecords")); n != 3 {
+		t.Errorf("got %d requests, want 3", n)
+	}
+
+	// an error that is not retried is yielded once and ends iteration
+	srv.Inject("ListRecords", wttest.Fault{Err: status.Error(codes.Internal, "failed"), Times: 1})
+
+	var errs []error
+	for rec, err := range c.Records(context.Background(), nil) {
+		if rec != nil {
+			t.Errorf("got record %v with error %v", rec, err)
+		}
+
+		errs = append(errs, err)
+	}
+
+	if len(errs) != 1 || !errors.Is(errs[0], wtclient.ErrInternal) {
+		t.Errorf("got errors %v, want a single ErrInternal", errs)
+	}
+}
+
+func TestRecordRevisions(t *testing.T) {
+	ctx := context.Background()
+
+	srv := wttest.NewServer(t)
+	recs := seed(t, srv, 80)
+	c := srv.Client()
+
+	for i := range 3 {
+		if _, err := c.UpdateRecord(ctx, &weighttracker.Record{Id: recs[0].GetId(), Weight: 90 + float32(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var revisions []uint64
+	for rev, err := range c.RecordRevisions(ctx, recs[0].GetId()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		revisions = append(revisions, rev.GetRevision())
+	}
+
+	if want := []uint64{4, 3, 2, 1}; !slices.Equal(revisions, want) {
+		t.Errorf("got revisions %v, want %v", revisions, want)
+	}
+
+	for _, err := range c.RecordRevisions(ctx, 42) {
+		if !errors.Is(err, wtclient.ErrNotFound) {
+			t.Errorf("got error %v listing the revisions of a missing record, want ErrNotFound", err)
+		}
+	}
+}
+
+func TestSeedAs(t *testing.T) {
+	ctx := context.Background()
+	srv := wttest.NewServer(t, wttest.WithAccounts())
+
+	acc, err := srv.Client().Register(ctx, "ana", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := srv.Client().Login(ctx, "ana", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = srv.Seed(&weighttracker.Record{Weight: 70}); err != nil {
+		t.Fatal(err)
+	}
+
+	seeded, err := srv.SeedAs(strconv.FormatUint(acc.GetId(), 10), &weighttracker.Record{Weight: 80}, &weighttracker.Record{Weight: 81})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := srv.Client(wtclient.WithToken(tokens.GetAccessToken()))
+
+	// records seeded without a principal belong to no one
+	var got []float32
+	for rec, err := range c.Records(ctx, &weighttracker.ListRecordsRequest{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, rec.GetWeight())
+	}
+
+	if !slices.Equal(got, []float32{80, 81}) {
+		t.Errorf("listed weights %v, want those seeded for the account", got)
+	}
+
+	if _, err = c.ReadRecord(ctx, seeded[0].GetId()); err != nil {
+		t.Errorf("reading a record seeded for the account failed: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package wtclient
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Errors matched by *Error, depending on its code, with errors.Is.
+var (
+	ErrInvalidArgument    = errors.New("invalid argument")
+	ErrNotFound           = errors.New("not found")
+	ErrAlreadyExists      = errors.New("already exists")
+	ErrFailedPrecondition = errors.New("failed precondition")
+	ErrAborted            = errors.New("aborted")
+	ErrOutOfRange         = errors.New("out of range")
+	ErrRateLimited        = errors.New("rate limited")
+	ErrUnauthenticated    = errors.New("unauthenticated")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrUnavailable        = errors.New("unavailable")
+	ErrInternal           = errors.New("internal error")
+)
+
+var codeErrors = map[codes.Code]error{
+	codes.InvalidArgument:    ErrInvalidArgument,
+	codes.NotFound:           ErrNotFound,
+	codes.AlreadyExists:      ErrAlreadyExists,
+	codes.FailedPrecondition: ErrFailedPrecondition,
+	codes.Aborted:            ErrAborted,
+	codes.OutOfRange:         ErrOutOfRange,
+	codes.ResourceExhausted:  ErrRateLimited,
+	codes.Unauthenticated:    ErrUnauthenticated,
+	codes.PermissionDenied:   ErrPermissionDenied,
+	codes.Unavailable:        ErrUnavailable,
+	codes.Internal:           ErrInternal,
+	codes.Canceled:           context.Canceled,
+	codes.DeadlineExceeded:   context.DeadlineExceeded,
+}
+
+// FieldViolation describes why a single request field is invalid. Field is
+// a path to the field, e.g. "record.weight".
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// Error is a failed call. It matches the Err variables for its code, and
+// context.Canceled or context.DeadlineExceeded, with errors.Is.
+type Error struct {
+	Code    codes.Code
+	Message string
+
+	// Violations lists every invalid field of an INVALID_ARGUMENT error.
+	Violations []FieldViolation
+
+	// RetryDelay is how long the server asked to wait before retrying, 0 if
+	// it did not.
+	RetryDelay time.Duration
+
+	status *status.Status
+}
+
+func (e *Error) Error() string {
+	return "wtclient: " + e.Code.String() + ": " + e.Message
+}
+
+// Is reports whether target is the error for the code of e.
+func (e *Error) Is(target error) bool {
+	err, ok := codeErrors[e.Code]
+
+	return ok && err == target
+}
+
+// GRPCStatus returns the status e was converted from.
+func (e *Error) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// toError converts an error carrying a status to *Error. Other errors, such
+// as io.EOF at the end of a stream, are returned unchanged.
+func toError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	e := &Error{Code: st.Code(), Message: st.Message(), status: st}
+
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				e.Violations = append(e.Violations, FieldViolation{Field: v.GetField(), Description: v.GetDescription()})
+			}
+		case *errdetails.RetryInfo:
+			if d.GetRetryDelay().IsValid() {
+				e.RetryDelay = d.GetRetryDelay().AsDuration()
+			}
+		}
+	}
+
+	return e
+}
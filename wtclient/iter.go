@@ -0,0 +1,148 @@
+package wtclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"google.golang.org/protobuf/proto"
+)
+
+// recordsBatchSize is the batch size Records asks for unless the request
+// sets one.
+const recordsBatchSize = 100
+
+// Records returns an iterator over the records matching req, which may be
+// nil to list every record. Opening the stream is retried like idempotent
+// calls, but a stream that fails midway is not resumed: the error is
+// yielded and iteration ends. Breaking out of the loop cancels the stream.
+func (c *Client) Records(ctx context.Context, req *weighttracker.ListRecordsRequest) iter.Seq2[*weighttracker.Record, error] {
+	return func(yield func(*weighttracker.Record, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		req := cloneOrNew(req)
+		if req.BatchSize == 0 {
+			req.BatchSize = recordsBatchSize
+		}
+
+		var (
+			stream weighttracker.WeightTracker_ListRecordsClient
+			resp   *weighttracker.ListRecordsResponse
+		)
+
+		// the status of the call is only known once the first message, or
+		// the end of the stream, is received
+		err := c.retry.do(ctx, func() error {
+			var err error
+			if stream, err = c.api.ListRecords(ctx, req); err != nil {
+				return err
+			}
+
+			resp, err = stream.Recv()
+
+			return err
+		})
+
+		for err == nil {
+			for _, rec := range responseRecords(resp) {
+				if !yield(rec, nil) {
+					return
+				}
+			}
+
+			resp, err = stream.Recv()
+		}
+
+		if !errors.Is(err, io.EOF) {
+			yield(nil, err)
+		}
+	}
+}
+
+// responseRecords returns the records carried by resp, including the single
+// one sent by servers that predate batches.
+func responseRecords(resp *weighttracker.ListRecordsResponse) []*weighttracker.Record {
+	if len(resp.GetRecords()) == 0 && resp.GetRecord() != nil {
+		return []*weighttracker.Record{resp.GetRecord()}
+	}
+
+	return resp.GetRecords()
+}
+
+// RecordRevisions returns an iterator over the revisions of the record with
+// the given id, newest first.
+func (c *Client) RecordRevisions(ctx context.Context, id uint64) iter.Seq2[*weighttracker.RecordRevision, error] {
+	return pages(func(token string) ([]*weighttracker.RecordRevision, string, error) {
+		resp, err := c.api.ListRecordRevisions(ctx, &weighttracker.ListRecordRevisionsRequest{RecordId: id, PageToken: token})
+
+		return resp.GetRevisions(), resp.GetNextPageToken(), err
+	})
+}
+
+// WebhookDeliveries returns an iterator over the webhook deliveries matching
+// req, newest first. The page token of req is ignored.
+func (c *Client) WebhookDeliveries(ctx context.Context, req *weighttracker.ListWebhookDeliveriesRequest) iter.Seq2[*weighttracker.WebhookDelivery, error] {
+	req = cloneOrNew(req)
+
+	return pages(func(token string) ([]*weighttracker.WebhookDelivery, string, error) {
+		req.PageToken = token
+		resp, err := c.api.ListWebhookDeliveries(ctx, req)
+
+		return resp.GetDeliveries(), resp.GetNextPageToken(), err
+	})
+}
+
+// AuditEvents returns an iterator over the audit events matching req, newest
+// first. The page token of req is ignored.
+func (c *Client) AuditEvents(ctx context.Context, req *weighttracker.ListAuditEventsRequest) iter.Seq2[*weighttracker.AuditEvent, error] {
+	req = cloneOrNew(req)
+
+	return pages(func(token string) ([]*weighttracker.AuditEvent, string, error) {
+		req.PageToken = token
+		resp, err := c.api.ListAuditEvents(ctx, req)
+
+		return resp.GetEvents(), resp.GetNextPageToken(), err
+	})
+}
+
+// pages returns an iterator over the items of every page returned by list,
+// following next page tokens until the last page.
+func pages[T any](list func(token string) ([]T, string, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		token := ""
+		for {
+			items, next, err := list(token)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			if next == "" {
+				return
+			}
+
+			token = next
+		}
+	}
+}
+
+// cloneOrNew returns a copy of req, so iterators can change it, or a new
+// request if req is nil.
+func cloneOrNew[T proto.Message](req T) T {
+	var zero T
+	if any(req) == any(zero) {
+		return zero.ProtoReflect().New().Interface().(T)
+	}
+
+	return proto.Clone(req).(T)
+}
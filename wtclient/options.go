@@ -0,0 +1,158 @@
+package wtclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// DefaultAddress is the address dialed unless WithAddress is used.
+const DefaultAddress = "localhost:50051"
+
+// Option configures a Client.
+type Option func(*options)
+
+type options struct {
+	address     string
+	tls         *tls.Config
+	caFile      string
+	serverName  string
+	insecure    bool
+	creds       credentials.PerRPCCredentials
+	token       string
+	retry       RetryPolicy
+	dialOptions []grpc.DialOption
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		address: DefaultAddress,
+		retry:   DefaultRetryPolicy,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithAddress sets the host:port of the server.
+func WithAddress(address string) Option {
+	return func(o *options) {
+		o.address = address
+	}
+}
+
+// WithTLS connects over TLS configured by config, nil uses the system
+// roots.
+func WithTLS(config *tls.Config) Option {
+	return func(o *options) {
+		if config == nil {
+			config = &tls.Config{}
+		}
+
+		o.tls = config
+	}
+}
+
+// WithTLSFromFile connects over TLS trusting the CA certificate in caFile.
+// serverName overrides the name the certificate is checked against, if not
+// empty.
+func WithTLSFromFile(caFile, serverName string) Option {
+	return func(o *options) {
+		o.caFile, o.serverName = caFile, serverName
+	}
+}
+
+// WithInsecure connects without TLS. Credentials are then sent in plain
+// text, so only use it against local or in-process servers.
+func WithInsecure() Option {
+	return func(o *options) {
+		o.insecure = true
+	}
+}
+
+// WithToken sends token as a bearer token with every call. It requires TLS
+// unless WithInsecure is used.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithPerRPCCredentials attaches creds to every call, e.g. to refresh tokens
+// as they expire. It replaces WithToken.
+func WithPerRPCCredentials(creds credentials.PerRPCCredentials) Option {
+	return func(o *options) {
+		o.creds = creds
+	}
+}
+
+// WithRetryPolicy sets how idempotent calls are retried. NoRetry disables
+// retries.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
+
+// WithDialOptions appends options passed to grpc.Dial.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	}
+}
+
+func (o *options) grpcDialOptions() ([]grpc.DialOption, error) {
+	var opts []grpc.DialOption
+
+	switch {
+	case o.tls != nil && o.caFile != "", (o.tls != nil || o.caFile != "") && o.insecure:
+		return nil, errors.New("wtclient: only one of WithTLS, WithTLSFromFile and WithInsecure can be used")
+	case o.tls != nil:
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(o.tls)))
+	case o.caFile != "":
+		creds, err := credentials.NewClientTLSFromFile(o.caFile, o.serverName)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, grpc.WithTransportCredentials(creds))
+	case o.insecure:
+		opts = append(opts, grpc.WithInsecure())
+	default:
+		return nil, errors.New("wtclient: one of WithTLS, WithTLSFromFile or WithInsecure is required")
+	}
+
+	return append(opts, o.dialOptions...), nil
+}
+
+// callOptions returns the options added to every call.
+func (o *options) callOptions() []grpc.CallOption {
+	switch {
+	case o.creds != nil:
+		return []grpc.CallOption{grpc.PerRPCCredentials(o.creds)}
+	case o.token != "":
+		return []grpc.CallOption{grpc.PerRPCCredentials(bearerToken{token: o.token, requireTLS: !o.insecure})}
+	}
+
+	return nil
+}
+
+// bearerToken sends a token in the authorization header.
+type bearerToken struct {
+	token      string
+	requireTLS bool
+}
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool {
+	return t.requireTLS
+}
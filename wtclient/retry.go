@@ -0,0 +1,166 @@
+package wtclient
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// RetryPolicy decides how idempotent calls are retried: those that read,
+// UpdateSettings, which replaces every setting, and opening the streams
+// iterated by Records. Retries wait with exponential backoff and jitter, or
+// as long as the server asks to through a google.rpc.RetryInfo detail, and
+// stop when the context of the call is done.
+type RetryPolicy struct {
+	MaxAttempts    int           // including the first one, 1 or less disables retries
+	InitialBackoff time.Duration // before the first retry, doubled for every other one
+	MaxBackoff     time.Duration
+	Codes          []codes.Code // retried status codes
+}
+
+var (
+	// DefaultRetryPolicy retries calls rejected by the rate limiter or that
+	// failed because the server or its database was unavailable.
+	DefaultRetryPolicy = RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Codes:          []codes.Code{codes.Unavailable, codes.ResourceExhausted},
+	}
+
+	// NoRetry disables retries.
+	NoRetry = RetryPolicy{MaxAttempts: 1}
+)
+
+// idempotentMethods lists the unary methods that are safe to retry.
+var idempotentMethods = map[string]bool{
+	"/WeightTracker/ReadRecord":            true,
+	"/WeightTracker/ListRecordRevisions":   true,
+	"/WeightTracker/ListDailySummaries":    true,
+	"/WeightTracker/GetSettings":           true,
+	"/WeightTracker/UpdateSettings":        true,
+	"/WeightTracker/ListWebhookDeliveries": true,
+	"/WeightTracker/ListAuditEvents":       true,
+	"/WeightTracker/Login":                 true,
+	"/WeightTracker/Logout":                true,
+}
+
+// do calls call until it succeeds, fails with an error that is not
+// retryable or runs out of attempts, and returns its last error.
+func (p RetryPolicy) do(ctx context.Context, call func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= p.MaxAttempts || !p.retryable(err) {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		if e, ok := err.(*Error); ok && e.RetryDelay > delay {
+			delay = e.RetryDelay
+		}
+
+		// give up right away instead of failing after waiting in vain
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	e, ok := err.(*Error)
+	if !ok {
+		return false
+	}
+
+	for _, code := range p.Codes {
+		if e.Code == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff returns how long to wait after the given number of failed
+// attempts.
+func (p RetryPolicy) backoff(attempts int) time.Duration {
+	b := p.InitialBackoff
+	for i := 1; i < attempts && (p.MaxBackoff <= 0 || b < p.MaxBackoff); i++ {
+		b *= 2
+	}
+
+	if p.MaxBackoff > 0 {
+		b = min(b, p.MaxBackoff)
+	}
+
+	if b <= 1 {
+		return b
+	}
+
+	return b/2 + time.Duration(rand.Int64N(int64(b/2)))
+}
+
+// conn wraps the connection used by the generated client, to convert errors
+// to *Error, retry idempotent calls and attach credentials.
+type conn struct {
+	cc       grpc.ClientConnInterface
+	retry    RetryPolicy
+	callOpts []grpc.CallOption
+}
+
+func (o *options) wrap(cc grpc.ClientConnInterface) *conn {
+	return &conn{cc: cc, retry: o.retry, callOpts: o.callOptions()}
+}
+
+func (c *conn) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	opts = append(c.callOpts[:len(c.callOpts):len(c.callOpts)], opts...)
+
+	call := func() error {
+		return toError(c.cc.Invoke(ctx, method, args, reply, opts...))
+	}
+
+	if !idempotentMethods[method] {
+		return call()
+	}
+
+	return c.retry.do(ctx, call)
+}
+
+func (c *conn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	opts = append(c.callOpts[:len(c.callOpts):len(c.callOpts)], opts...)
+
+	s, err := c.cc.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		return nil, toError(err)
+	}
+
+	return stream{s}, nil
+}
+
+// stream converts the errors of a stream to *Error.
+type stream struct {
+	grpc.ClientStream
+}
+
+func (s stream) SendMsg(m interface{}) error {
+	return toError(s.ClientStream.SendMsg(m))
+}
+
+func (s stream) RecvMsg(m interface{}) error {
+	return toError(s.ClientStream.RecvMsg(m))
+}
+
+func (s stream) CloseSend() error {
+	return toError(s.ClientStream.CloseSend())
+}
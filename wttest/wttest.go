@@ -0,0 +1,382 @@
+// Package wttest runs a WeightTracker server in process for the tests of
+// code that calls the service. It serves the real handlers over an in-memory
+// connection, backed by a store that is only kept in memory, so tests are
+// fast, isolated and need nothing running:
+//
+//	srv := wttest.NewServer(t)
+//	srv.Seed(&weighttracker.Record{Weight: 80})
+//	srv.Inject("ReadRecord", wttest.Fault{Err: status.Error(codes.Unavailable, "down"), Times: 1})
+//
+//	c := srv.Client()
+//	// exercise the code under test with c, then check what it sent
+//	reqs := srv.Requests("ReadRecord")
+package wttest
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0gener/go-weight-tracker/server/auth"
+	"github.com/0gener/go-weight-tracker/server/config"
+	"github.com/0gener/go-weight-tracker/server/events"
+	"github.com/0gener/go-weight-tracker/server/logging"
+	"github.com/0gener/go-weight-tracker/server/rpcerror"
+	"github.com/0gener/go-weight-tracker/server/service"
+	"github.com/0gener/go-weight-tracker/server/store"
+	"github.com/0gener/go-weight-tracker/server/store/filestore"
+	"github.com/0gener/go-weight-tracker/server/validation"
+	"github.com/0gener/go-weight-tracker/server/webhook"
+	"github.com/0gener/go-weight-tracker/weighttracker"
+	"github.com/0gener/go-weight-tracker/wtclient"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+const bufSize = 1 << 20
+
+// Fault changes how calls to a method are handled.
+type Fault struct {
+	// Err is returned instead of calling the handler, if not nil. It should
+	// be a status, e.g. status.Error(codes.Unavailable, "down").
+	Err error
+
+	// Latency is waited before handling the call, or until the call is
+	// canceled.
+	Latency time.Duration
+
+	// Times is how many calls the fault applies to, 0 means every call
+	// until it is cleared.
+	Times int
+}
+
+// Option configures a Server.
+type Option func(*options)
+
+type options struct {
+	store    store.Store
+	now      func() time.Time
+	accounts bool
+	webhooks config.WebhooksConfig
+}
+
+// WithStore serves records from st instead of an in-memory store, e.g. to
+// test against a SQL database. st is not closed with the server.
+func WithStore(st store.Store) Option {
+	return func(o *options) {
+		o.store = st
+	}
+}
+
+// WithClock makes the server read the time from now, so that defaults such
+// as the weighted_at of new records and the validation of future records
+// are deterministic. Timestamps set by the store are not affected.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// WithAccounts authenticates callers with accounts, as the server does with
+// the accounts auth mode, so every call but Register, Login, RefreshToken
+// and Logout needs an access token, e.g.
+//
+//	c := srv.Client(wtclient.WithToken(resp.GetTokens().GetAccessToken()))
+//
+// Anyone can register. Tokens are signed with a random key and expire after
+// the default TTLs, as measured by the clock of the server.
+func WithAccounts() Option {
+	return func(o *options) {
+		o.accounts = true
+	}
+}
+
+// WithWebhooks queues webhook deliveries for the subscriptions in
+// webhooksConfig. They are never sent, but can be listed with
+// ListWebhookDeliveries.
+func WithWebhooks(webhooksConfig config.WebhooksConfig) Option {
+	return func(o *options) {
+		o.webhooks = webhooksConfig
+	}
+}
+
+// Server is an in-process WeightTracker server.
+type Server struct {
+	store   store.Store
+	service *service.Server
+	broker  *events.Broker
+	grpc    *grpc.Server
+	lis     *bufconn.Listener
+	conn    *grpc.ClientConn
+
+	mu       sync.Mutex
+	faults   map[string]*Fault          // by method name
+	requests map[string][]proto.Message // by method name, in the order received
+}
+
+// NewServer starts a server and stops it when the test ends.
+func NewServer(tb testing.TB, opts ...Option) *Server {
+	tb.Helper()
+
+	o := &options{now: time.Now}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.store == nil {
+		o.store = filestore.NewMemory()
+	}
+
+	broker := events.NewBroker(o.now)
+	validator := validation.New(config.Default().Validation, o.now)
+	webhooks := webhook.New(o.webhooks, o.store, o.now)
+
+	var accounts *auth.Accounts
+	if o.accounts {
+		authConfig := config.Default().Auth
+		authConfig.Mode = "accounts"
+		authConfig.SigningKey = config.Secret(rand.Text())
+		authConfig.AllowRegistration = true
+		accounts = auth.NewAccounts(authConfig, o.store, o.now)
+	}
+
+	s := &Server{
+		store:    o.store,
+		service:  service.New(o.store, validator, broker, webhooks, accounts, o.now),
+		broker:   broker,
+		lis:      bufconn.Listen(bufSize),
+		faults:   map[string]*Fault{},
+		requests: map[string][]proto.Message{},
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	unary := []grpc.UnaryServerInterceptor{logging.UnaryServerInterceptor(logger), s.unaryInterceptor}
+	stream := []grpc.StreamServerInterceptor{logging.StreamServerInterceptor(logger), s.streamInterceptor}
+
+	if accounts != nil {
+		unary = append(unary, auth.UnaryServerInterceptor(accounts, service.PublicMethods...))
+		stream = append(stream, auth.StreamServerInterceptor(accounts, service.PublicMethods...))
+	}
+
+	s.grpc = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(append(unary, service.ActorInterceptor(), rpcerror.UnaryServerInterceptor())...),
+		grpc.ChainStreamInterceptor(append(stream, rpcerror.StreamServerInterceptor())...),
+	)
+
+	weighttracker.RegisterWeightTrackerServer(s.grpc, s.service)
+
+	go s.grpc.Serve(s.lis)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return s.lis.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		s.Close()
+		tb.Fatalf("wttest: failed to dial server: %v", err)
+	}
+
+	s.conn = conn
+
+	tb.Cleanup(s.Close)
+
+	return s
+}
+
+// Close stops the server. Calling it again has no effect.
+func (s *Server) Close() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+
+	// end WatchRecords streams, which would otherwise hold up the stop
+	s.broker.Close()
+	s.grpc.Stop()
+	s.lis.Close()
+}
+
+// Conn returns a connection to the server.
+func (s *Server) Conn() *grpc.ClientConn {
+	return s.conn
+}
+
+// Client returns a client connected to the server. Retries are disabled
+// unless opts set a retry policy.
+func (s *Server) Client(opts ...wtclient.Option) *wtclient.Client {
+	opts = append([]wtclient.Option{wtclient.WithRetryPolicy(wtclient.NoRetry), wtclient.WithInsecure()}, opts...)
+
+	return wtclient.NewFromConn(s.conn, opts...)
+}
+
+// Store returns the store of the server, to inspect or change its state
+// directly.
+func (s *Server) Store() store.Store {
+	return s.store
+}
+
+// Seed creates records as an unauthenticated call to CreateRecord would and
+// returns them as stored. The records have no owner, so with WithAccounts
+// only SeedAs creates records that callers can see. Seeding is not subject
+// to faults and is not recorded in Requests.
+func (s *Server) Seed(records ...*weighttracker.Record) ([]*weighttracker.Record, error) {
+	return s.SeedAs("", records...)
+}
+
+// SeedAs is like Seed but creates records owned by principal, as if it had
+// called CreateRecord. With WithAccounts, the principal of an account is its
+// id, e.g. strconv.FormatUint(account.GetId(), 10).
+func (s *Server) SeedAs(principal string, records ...*weighttracker.Record) ([]*weighttracker.Record, error) {
+	ctx := auth.WithPrincipal(context.Background(), principal)
+	ctx = store.WithActor(ctx, store.Actor{Principal: principal})
+
+	seeded := make([]*weighttracker.Record, 0, len(records))
+
+	for _, rec := range records {
+		resp, err := s.service.CreateRecord(ctx, &weighttracker.CreateRecordRequest{Record: rec})
+		if err != nil {
+			return seeded, err
+		}
+
+		seeded = append(seeded, resp.GetRecord())
+	}
+
+	return seeded, nil
+}
+
+// Inject applies f to the calls to method, e.g. "ReadRecord", replacing any
+// fault injected before. It panics if the service has no such method.
+func (s *Server) Inject(method string, f Fault) {
+	s.checkMethod(method)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults[method] = &f
+}
+
+// ClearFaults removes every injected fault.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = map[string]*Fault{}
+}
+
+// Requests returns copies of the requests received by method, e.g.
+// "ListRecords", in the order they were received, including those that
+// failed because of a fault.
+func (s *Server) Requests(method string) []proto.Message {
+	s.checkMethod(method)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reqs := make([]proto.Message, 0, len(s.requests[method]))
+	for _, req := range s.requests[method] {
+		reqs = append(reqs, proto.Clone(req))
+	}
+
+	return reqs
+}
+
+// ResetRequests forgets the requests received so far.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = map[string][]proto.Message{}
+}
+
+func (s *Server) checkMethod(method string) {
+	for _, m := range s.grpc.GetServiceInfo()["WeightTracker"].Methods {
+		if m.Name == method {
+			return
+		}
+	}
+
+	panic(fmt.Sprintf("wttest: unknown method %q", method))
+}
+
+// received records req and returns the fault to apply to it, if any.
+func (s *Server) received(method string, req interface{}) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msg, ok := req.(proto.Message); ok {
+		s.requests[method] = append(s.requests[method], proto.Clone(msg))
+	}
+
+	f := s.faults[method]
+	if f == nil {
+		return nil
+	}
+
+	applied := *f
+
+	if f.Times > 0 {
+		if f.Times--; f.Times == 0 {
+			delete(s.faults, method)
+		}
+	}
+
+	return &applied
+}
+
+// apply waits for the latency of f and returns its error.
+func (f *Fault) apply(ctx context.Context) error {
+	if f == nil {
+		return nil
+	}
+
+	if f.Latency > 0 {
+		t := time.NewTimer(f.Latency)
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	return f.Err
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.received(path.Base(info.FullMethod), req).apply(ctx); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (s *Server) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &recordingStream{ServerStream: ss, server: s, method: path.Base(info.FullMethod)})
+}
+
+// recordingStream applies faults to a server streaming call once its
+// request is received.
+type recordingStream struct {
+	grpc.ServerStream
+	server *Server
+	method string
+}
+
+func (s *recordingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	return s.server.received(s.method, m).apply(s.Context())
+}